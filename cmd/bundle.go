@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simonyos/Z-CODE/internal/bundle"
+)
+
+var importForce bool
+
+var exportConfigCmd = &cobra.Command{
+	Use:   "export-config <file>",
+	Short: "Export agents, workflows, skills and settings to a bundle",
+	Long: `Export custom agents, workflows, skills and non-secret settings into a
+single tar.gz bundle that teammates can install with import-config.
+
+Both project-local (.zcode/) and global (~/.config/zcode/) definitions are
+included. API keys are never exported.
+
+Example:
+  zcode export-config team-preset.tar.gz`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := bundle.Export(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Exported bundle to %s\n", args[0])
+		fmt.Printf("  agents:    %d\n", len(manifest.Agents))
+		fmt.Printf("  workflows: %d\n", len(manifest.Workflows))
+		fmt.Printf("  skills:    %d\n", len(manifest.Skills))
+		fmt.Printf("  settings:  %d (API keys excluded)\n", len(manifest.Settings))
+	},
+}
+
+var importConfigCmd = &cobra.Command{
+	Use:   "import-config <file>",
+	Short: "Install agents, workflows, skills and settings from a bundle",
+	Long: `Install a bundle created with export-config into ~/.config/zcode.
+
+Every definition is validated before anything is written. Existing
+definitions and settings that differ from the bundle are kept unless
+--force is given. Settings that change where requests go or relax a safety
+check (litellm_base_url, command_allow, command_deny, command_root,
+command_env, tools, secret_redaction and the like) are also only applied
+with --force, so review the bundle first.
+
+Example:
+  zcode import-config team-preset.tar.gz`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := bundle.Import(args[0], bundle.ImportOptions{Force: importForce})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, w := range result.Warnings {
+			fmt.Printf("Warning: %s\n", w)
+		}
+		for _, name := range result.Installed {
+			fmt.Printf("  installed %s\n", name)
+		}
+		fmt.Printf("Imported %d item(s), skipped %d.\n", len(result.Installed), len(result.Skipped))
+	},
+}
+
+func init() {
+	importConfigCmd.Flags().BoolVar(&importForce, "force", false, "Overwrite existing definitions and settings, and apply protected settings")
+	rootCmd.AddCommand(exportConfigCmd)
+	rootCmd.AddCommand(importConfigCmd)
+}
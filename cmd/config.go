@@ -8,16 +8,29 @@ import (
 	"github.com/simonyos/Z-CODE/internal/config"
 )
 
+// configProject writes to the project config instead of the global one
+var configProject bool
+
+// configShowSecrets shows API keys in full instead of masked
+var configShowSecrets bool
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage z-code configuration",
 	Long: `Manage z-code configuration including API keys and defaults.
 
 Examples:
-  zcode config                      # Show current config
+  zcode config                      # Show current config, API keys masked
+  zcode config --show-secrets       # Show current config with full API keys
   zcode config set openai <key>     # Set OpenAI API key
   zcode config set provider openai  # Set default provider
-  zcode config delete openai        # Remove OpenAI API key`,
+  zcode config delete openai        # Remove OpenAI API key
+  zcode config set --project model llama3  # Use a different model in this project
+
+Settings in .zcode/config.yaml, found in the current directory or a parent,
+override the global config. API keys, litellm_base_url, web_fetch_allow_private
+and command_env are only read from the global config, and a project can only
+tighten command_allow, command_deny, command_root, tools and secret_redaction.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		showConfig()
 	},
@@ -35,16 +48,41 @@ Available keys:
   litellm      - LiteLLM API key
   litellm_url  - LiteLLM base URL (default: http://localhost:4000)
   provider     - Default provider (claude, openai, openrouter, litellm)
-  model        - Default model`,
+  model        - Default model
+  web_fetch_allow_private - Let web_fetch reach private/loopback hosts (true/false)
+  command_timeout         - run_command timeout in seconds (default: 30)
+  command_root            - Run commands in this directory and refuse ones referencing paths outside it (. for the working directory)
+  command_allow           - Comma-separated commands run_command is limited to (e.g. "go,git *,npm run*")
+  command_deny            - Comma-separated commands run_command refuses (sudo and shutdown are always refused)
+  command_env.<NAME>      - Environment variable set for every run_command (e.g. command_env.CGO_ENABLED 0)
+  max_reads_per_turn      - read_file/list_dir calls allowed per turn (default: 50)
+  tools.readonly          - Only give the agent tools that cannot change files or run commands (true/false)
+  tools.disabled          - Comma-separated tools the agent never gets (e.g. run_command,write_file)
+  secret_redaction        - Redact secrets in tool output sent to the model (off, high, aggressive; default: high)
+  secret_pattern.<name>   - Extra regex to redact, reported as [REDACTED:<name>]
+  auto_continue           - Continue turns when a reply looks cut short (0-5, default: 0 = off)
+  auto_continue_pattern   - Regex for a last line that should trigger a continue
+  request_timeout         - LLM request timeout in seconds (default: 120, 300 for Anthropic)
+  prompt_git_context      - Add current branch and recent commits to the prompt (true/false)
+  prompt_token_budget     - Drop low-priority prompt sections above this many tokens (default: 0 = no limit)
+  mouse                   - Scroll the chat with the mouse wheel (true/false; blocks terminal text selection)`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
 		value := args[1]
 
-		if err := config.Set(key, value); err != nil {
+		set := config.Set
+		if configProject {
+			set = config.SetProject
+		}
+		if err := set(key, value); err != nil {
 			fmt.Printf("Error: %v\n", err)
 			return
 		}
+		if configProject {
+			fmt.Printf("Set %s in %s.\n", key, config.ProjectConfigPath())
+			return
+		}
 		fmt.Printf("Set %s successfully.\n", key)
 	},
 }
@@ -55,7 +93,7 @@ var configGetCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
-		keys := config.ListKeys()
+		keys := listConfigKeys()
 
 		if val, ok := keys[key]; ok {
 			fmt.Printf("%s: %s\n", key, val)
@@ -73,7 +111,11 @@ var configDeleteCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
 
-		if err := config.Delete(key); err != nil {
+		del := config.Delete
+		if configProject {
+			del = config.DeleteProject
+		}
+		if err := del(key); err != nil {
 			fmt.Printf("Error: %v\n", err)
 			return
 		}
@@ -90,9 +132,13 @@ var configPathCmd = &cobra.Command{
 }
 
 func showConfig() {
-	fmt.Printf("Configuration file: %s\n\n", config.ConfigPath())
+	fmt.Printf("Configuration file: %s\n", config.ConfigPath())
+	if path := config.ProjectConfigPath(); path != "" {
+		fmt.Printf("Project config: %s\n", path)
+	}
+	fmt.Println()
 
-	keys := config.ListKeys()
+	keys := listConfigKeys()
 	if len(keys) == 0 {
 		fmt.Println("No configuration set.")
 		fmt.Println("\nUse 'zcode config set <key> <value>' to configure.")
@@ -104,7 +150,20 @@ func showConfig() {
 	}
 }
 
+// listConfigKeys lists the configured keys, with API keys masked unless
+// --show-secrets is given
+func listConfigKeys() map[string]string {
+	if configShowSecrets {
+		return config.ListKeysWithSecrets()
+	}
+	return config.ListKeys()
+}
+
 func init() {
+	configCmd.PersistentFlags().BoolVar(&configShowSecrets, "show-secrets", false, "Show API keys in full instead of masked")
+	configSetCmd.Flags().BoolVar(&configProject, "project", false, "Write to the project's .zcode/config.yaml")
+	configDeleteCmd.Flags().BoolVar(&configProject, "project", false, "Remove from the project's .zcode/config.yaml")
+
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configDeleteCmd)
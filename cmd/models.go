@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/llm"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models [filter]",
+	Short: "List the models each provider offers",
+	Long: `List the models offered by each provider, asking the provider's models
+endpoint. The configured default is marked with *. Providers that do not
+list their models show a curated list instead. Lists are cached for a few
+minutes.
+
+Examples:
+  zcode models          # All models
+  zcode models claude   # Only models containing "claude"`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) == 1 {
+			filter = args[0]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+		defer cancel()
+
+		cfg := config.Get()
+		provider := cfg.DefaultProvider
+		if provider == "" {
+			provider = "litellm"
+		}
+		model := cfg.DefaultModel
+		if model == "" {
+			model = llm.DefaultModels[provider]
+		}
+
+		fmt.Println(llm.FormatModels(llm.ListProviderModels(ctx), provider, model, filter))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -11,12 +15,24 @@ import (
 	"github.com/simonyos/Z-CODE/internal/agent"
 	"github.com/simonyos/Z-CODE/internal/config"
 	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/logging"
 	"github.com/simonyos/Z-CODE/internal/tui"
 )
 
 var (
-	providerFlag string
-	modelFlag    string
+	providerFlag   string
+	modelFlag      string
+	noValidateFlag bool
+	mouseFlag      bool
+)
+
+const (
+	// validateTimeout bounds the startup check of the provider and model
+	validateTimeout = 15 * time.Second
+
+	// maxListedModels caps how many valid models are printed when the
+	// model is not recognized
+	maxListedModels = 40
 )
 
 var rootCmd = &cobra.Command{
@@ -31,6 +47,9 @@ Supported providers:
   openrouter - OpenRouter API (requires OPENROUTER_API_KEY)
   litellm    - LiteLLM Proxy (unified interface to 100+ LLMs) [default]
 
+With no provider configured, the first interactive run asks for a provider
+and model and saves the choice.
+
 Note: 'claude' and 'gemini' CLI providers were removed in v2.0.
 Use 'litellm' or 'openrouter' with Claude/Gemini models instead:
   zcode -p litellm -m anthropic/claude-3.5-sonnet
@@ -47,41 +66,32 @@ func runChat(cmd *cobra.Command, args []string) {
 	if selectedProvider == "" && cfg.DefaultProvider != "" {
 		selectedProvider = cfg.DefaultProvider
 	}
-	if selectedProvider == "" {
-		selectedProvider = "litellm"
-	}
 
 	selectedModel := modelFlag
 	if selectedModel == "" && cfg.DefaultModel != "" {
 		selectedModel = cfg.DefaultModel
 	}
 
-	// Create LLM provider based on selection
-	var provider llm.Provider
-	var modelName string
-
-	switch strings.ToLower(selectedProvider) {
-	case "openai":
-		model := selectedModel
-		if model == "" {
-			model = "gpt-4o" // Default OpenAI model
+	// On the first run, let the user choose instead of guessing
+	if selectedProvider == "" && modelFlag == "" && isInteractive() {
+		choice, ok, err := tui.RunModelPicker()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
-		provider = llm.NewOpenAI(model)
-		modelName = model
-	case "openrouter":
-		model := selectedModel
-		if model == "" {
-			model = "anthropic/claude-sonnet-4" // Default OpenRouter model
+		if !ok {
+			fmt.Println("No model chosen. Pick one with -p <provider> -m <model> or 'zcode config set provider <provider>'.")
+			os.Exit(1)
 		}
-		provider = llm.NewOpenRouter(model)
-		modelName = model
-	case "litellm":
-		model := selectedModel
-		if model == "" {
-			model = "gpt-4o" // Default LiteLLM model
-		}
-		provider = llm.NewLiteLLM(model)
-		modelName = model
+		fmt.Printf("Saved %s with %s as the default; change it with 'zcode config set'.\n", choice.Model, choice.Provider)
+		selectedProvider, selectedModel = choice.Provider, choice.Model
+	}
+	if selectedProvider == "" {
+		selectedProvider = "litellm"
+	}
+
+	// Create LLM provider based on selection
+	switch strings.ToLower(selectedProvider) {
 	case "claude", "gemini":
 		fmt.Printf("Provider '%s' was removed in v2.0\n", selectedProvider)
 		fmt.Println("")
@@ -90,30 +100,95 @@ func runChat(cmd *cobra.Command, args []string) {
 		fmt.Println("  zcode -p litellm -m google/gemini-flash-1.5")
 		fmt.Println("  zcode -p openrouter -m anthropic/claude-3.5-sonnet")
 		os.Exit(1)
-	default:
+	}
+	provider, err := llm.NewProvider(selectedProvider, selectedModel)
+	if err != nil {
 		fmt.Printf("Unknown provider: %s\n", selectedProvider)
-		fmt.Println("Supported providers: openai, openrouter, litellm")
+		fmt.Printf("Supported providers: %s\n", strings.Join(llm.Providers, ", "))
 		os.Exit(1)
 	}
+	_, modelName := llm.Describe(provider)
+
+	if !noValidateFlag {
+		if err := validateProvider(provider); err != nil {
+			printValidationError(selectedProvider, modelName, err)
+			os.Exit(1)
+		}
+	}
+
+	// From here the TUI owns the terminal; warnings are shown in the chat
+	logging.HoldStderr()
 
 	// Create agent with confirmation function
 	ag := agent.New(provider, tui.ConfirmAction)
 
 	// Start TUI with options to prevent terminal query responses from appearing
-	p := tea.NewProgram(
-		tui.New(ag, modelName),
+	opts := []tea.ProgramOption{
 		tea.WithAltScreen(),
 		tea.WithoutBracketedPaste(), // Disable bracketed paste to avoid escape sequence issues
-	)
+	}
+	if mouseFlag || cfg.Mouse {
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(tui.New(ag, modelName), opts...)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running TUI: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// isInteractive reports whether zcode is attached to a terminal it can
+// prompt on
+func isInteractive() bool {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validateProvider checks the API key and model before the session starts,
+// so a typo fails here instead of on the first request
+func validateProvider(provider llm.Provider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	return provider.Validate(ctx)
+}
+
+// printValidationError explains why the provider cannot be used and, for
+// an unknown model, which models are available
+func printValidationError(providerName, modelName string, err error) {
+	fmt.Printf("Cannot use model '%s' with provider '%s': %s\n", modelName, providerName, llm.ErrorMessage(err))
+
+	var modelErr *llm.ModelError
+	if errors.As(err, &modelErr) {
+		fmt.Println("")
+		fmt.Println("Available models:")
+		for i, id := range modelErr.Available {
+			if i == maxListedModels {
+				fmt.Printf("  ... and %d more\n", len(modelErr.Available)-maxListedModels)
+				break
+			}
+			fmt.Printf("  %s\n", id)
+		}
+		fmt.Println("")
+		fmt.Println("Pick one with -m <model> or 'zcode config set model <model>'.")
+	}
+	fmt.Println("Use --no-validate to skip this check, e.g. for offline or proxy setups.")
+}
+
 // Execute runs the root command
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	closeLog, err := logging.Init(filepath.Join(config.ConfigDir(), "logs"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: logging disabled: %v\n", err)
+	}
+
+	err = rootCmd.Execute()
+	closeLog()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -121,4 +196,6 @@ func Execute() {
 func init() {
 	rootCmd.Flags().StringVarP(&providerFlag, "provider", "p", "", "LLM provider (claude, gemini, openai, openrouter, litellm)")
 	rootCmd.Flags().StringVarP(&modelFlag, "model", "m", "", "Model to use (provider-specific)")
+	rootCmd.Flags().BoolVar(&noValidateFlag, "no-validate", false, "Skip checking the API key and model before starting")
+	rootCmd.Flags().BoolVar(&mouseFlag, "mouse", false, "Scroll with the mouse wheel (also 'zcode config set mouse true')")
 }
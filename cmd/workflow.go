@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simonyos/Z-CODE/internal/agents"
+	"github.com/simonyos/Z-CODE/internal/workflows"
+)
+
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Inspect workflows",
+	Long: `Inspect workflows defined in .zcode/workflows/ and ~/.config/zcode/workflows/.
+
+Examples:
+  zcode workflow plan review-and-fix                   # Show the steps
+  zcode workflow plan review-and-fix "Fix src/api"    # Resolve prompts too`,
+}
+
+var workflowPlanCmd = &cobra.Command{
+	Use:   "plan <name> [prompt]",
+	Short: "Show what a workflow would do without running it",
+	Long: `Show the steps of a workflow in order, with their agents, resolved
+prompts and how their conditions evaluate before the run, without calling
+the LLM.
+
+Missing agents, sub-workflows and on_success/on_failure targets are
+reported, and the command exits with status 1 if there are any.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt := ""
+		if len(args) == 2 {
+			prompt = args[1]
+		}
+
+		agentReg := agents.NewRegistry()
+		_ = agentReg.Refresh()
+		workflowReg := workflows.NewRegistry()
+		_ = workflowReg.Refresh()
+
+		engine := workflows.NewEngine(agentReg, workflowReg, nil, nil)
+		plan, err := engine.Plan(args[0], prompt)
+		if err != nil {
+			fmt.Printf("Error: %v: %s\n", err, args[0])
+			os.Exit(1)
+		}
+
+		printPlan(plan)
+		if len(plan.Problems) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// printPlan writes a workflow plan to stdout
+func printPlan(plan *workflows.WorkflowPlan) {
+	fmt.Printf("Workflow: %s\n", plan.WorkflowName)
+	if plan.Description != "" {
+		fmt.Printf("  %s\n", plan.Description)
+	}
+
+	for i, step := range plan.Steps {
+		target := step.Agent
+		switch step.Type {
+		case workflows.StepTypeHuman:
+			target = "human input"
+		case workflows.StepTypeWorkflow:
+			target = "workflow " + step.Workflow
+		}
+		fmt.Printf("\n%d. %s (%s)\n", i+1, step.Name, target)
+
+		switch step.ConditionResult {
+		case workflows.PlanConditionTrue:
+			fmt.Printf("   if:         %s (true, will run)\n", step.Condition)
+		case workflows.PlanConditionFalse:
+			fmt.Printf("   if:         %s (false, will be skipped)\n", step.Condition)
+		case workflows.PlanConditionRuntime:
+			fmt.Printf("   if:         %s (decided at run time)\n", step.Condition)
+		}
+		if step.LoopUntil != "" {
+			fmt.Printf("   loop until: %s\n", step.LoopUntil)
+		}
+		if step.OnSuccess != "" {
+			fmt.Printf("   on success: %s\n", step.OnSuccess)
+		}
+		if step.OnFailure != "" {
+			fmt.Printf("   on failure: %s\n", step.OnFailure)
+		} else if step.ContinueOnError {
+			fmt.Printf("   on failure: continue\n")
+		}
+		if step.Timeout > 0 {
+			fmt.Printf("   timeout:    %s\n", step.Timeout)
+		}
+		if step.Retries > 0 {
+			fmt.Printf("   retries:    %d\n", step.Retries)
+		}
+		if step.Prompt != "" {
+			fmt.Printf("   prompt:     %s\n", strings.ReplaceAll(step.Prompt, "\n", "\n               "))
+		}
+	}
+
+	if len(plan.Problems) > 0 {
+		fmt.Println("\nProblems:")
+		for _, problem := range plan.Problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+	}
+}
+
+func init() {
+	workflowCmd.AddCommand(workflowPlanCmd)
+	rootCmd.AddCommand(workflowCmd)
+}
@@ -3,11 +3,18 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/simonyos/Z-CODE/internal/config"
 	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/logging"
 	"github.com/simonyos/Z-CODE/internal/tools"
 )
 
@@ -36,9 +43,9 @@ type ChatResult struct {
 
 // StreamEvent represents events during streaming chat
 type StreamEvent struct {
-	Type string // "start", "chunk", "tool_start", "tool_result", "tool_batch_start", "tool_batch_end", "done", "error"
+	Type string // "start", "chunk", "tool_start", "tool_output", "tool_result", "tool_batch_start", "tool_batch_end", "done", "error"
 
-	// For chunk events
+	// For chunk and tool_output events
 	Text string
 
 	// For tool events
@@ -55,7 +62,8 @@ type StreamEvent struct {
 	FinalResponse string
 
 	// For error event
-	Error error
+	Error   error
+	Partial string // Text streamed before the failure, kept in the history
 
 	// For handoff event
 	Handoff *HandoffInstruction
@@ -75,11 +83,34 @@ type Agent struct {
 	provider       llm.Provider
 	registry       *tools.Registry
 	messages       []llm.Message
+	turns          []int // Index in messages of each turn's user message
 	handler        EventHandler
 	maxIterations  int
 	maxToolRetries int
+	todos          *tools.TodoList // Plan kept by the todo tool
+	customPrompt   bool            // System prompt was given in AgentConfig, not built
+
+	// reads caps read tool calls per turn and is reset when a turn begins
+	reads *tools.ReadLimit
+
+	// maxNudges is how many automatic continue turns a turn may get when
+	// the final response looks cut short (0 disables it)
+	maxNudges    int
+	nudgePattern *regexp.Regexp
+
+	// turnMu guards inTurn and cancelTurn; only one Chat or ChatStream may
+	// run at a time because turns append to the shared message history
+	turnMu     sync.Mutex
+	inTurn     bool
+	cancelTurn context.CancelFunc // Cancels the context of the turn in flight
 }
 
+// ErrTurnInProgress is returned when a turn starts while another is running
+var ErrTurnInProgress = errors.New("agent is already processing a message")
+
+// ErrNoTurn is returned when rewinding to a turn the history does not have
+var ErrNoTurn = errors.New("no such message in the conversation")
+
 // AgentConfig holds configuration for creating a custom agent
 type AgentConfig struct {
 	Provider       llm.Provider
@@ -88,28 +119,45 @@ type AgentConfig struct {
 	MaxIterations  int      // Max LLM calls per conversation (0 = default 10)
 	AllowedTools   []string // Tool names to enable (empty = all tools)
 	MaxToolRetries int      // Max retries for failed tool calls (0 = default 3)
+	MaxReads       int      // Max read_file/list_dir calls per turn (0 = config default)
+	MaxNudges      int      // Automatic continue turns when a response looks cut short (0 = config default)
 }
 
-// New creates a new agent with the given provider
+// New creates a new agent with the given provider. Tools disabled by the
+// tools config are not registered.
 func New(provider llm.Provider, confirmFn tools.ConfirmFunc) *Agent {
 	reg := tools.NewRegistry()
+	reg.SetPolicy(tools.ConfigPolicy())
+	todos := tools.NewTodoList()
 
 	// Register default tools
 	reg.Register(tools.NewReadFileTool())
 	reg.Register(tools.NewListDirTool())
 	reg.Register(tools.NewWriteFileTool(confirmFn))
 	reg.Register(tools.NewEditTool(confirmFn))
+	reg.Register(tools.NewLineEditTool(confirmFn))
+	reg.Register(tools.NewMoveFileTool(confirmFn))
+	reg.Register(tools.NewDeleteFileTool(confirmFn))
+	reg.Register(tools.NewUndoTool(confirmFn))
 	reg.Register(tools.NewBashTool(confirmFn))
+	reg.Register(tools.NewSandboxTool(confirmFn))
 	reg.Register(tools.NewGlobTool())
 	reg.Register(tools.NewGrepTool())
+	reg.Register(tools.NewWebFetchTool())
+	reg.Register(tools.NewEnvInfoTool())
+	reg.Register(tools.NewTodoTool(todos))
 
 	return &Agent{
 		provider:       provider,
 		registry:       reg,
 		maxIterations:  10,
 		maxToolRetries: 3,
+		todos:          todos,
+		reads:          tools.NewReadLimit(config.GetMaxReadsPerTurn()),
+		maxNudges:      config.GetAutoContinue(),
+		nudgePattern:   compileNudgePattern(config.GetAutoContinuePattern()),
 		messages: []llm.Message{
-			{Role: "system", Content: reg.BuildSystemPrompt()},
+			{Role: "system", Content: buildSystemPrompt(reg)},
 		},
 	}
 }
@@ -117,16 +165,26 @@ func New(provider llm.Provider, confirmFn tools.ConfirmFunc) *Agent {
 // NewWithConfig creates a new agent with custom configuration
 func NewWithConfig(cfg AgentConfig) *Agent {
 	reg := tools.NewRegistry()
+	reg.SetPolicy(tools.ConfigPolicy())
+	todos := tools.NewTodoList()
 
 	// Build map of all available tools
 	allTools := map[string]tools.Tool{
-		"read_file":  tools.NewReadFileTool(),
-		"list_dir":   tools.NewListDirTool(),
-		"write_file": tools.NewWriteFileTool(cfg.ConfirmFn),
-		"edit_file":  tools.NewEditTool(cfg.ConfirmFn),
-		"run_command": tools.NewBashTool(cfg.ConfirmFn),
-		"glob":       tools.NewGlobTool(),
-		"grep":       tools.NewGrepTool(),
+		"read_file":      tools.NewReadFileTool(),
+		"list_dir":       tools.NewListDirTool(),
+		"write_file":     tools.NewWriteFileTool(cfg.ConfirmFn),
+		"edit_file":      tools.NewEditTool(cfg.ConfirmFn),
+		"line_edit":      tools.NewLineEditTool(cfg.ConfirmFn),
+		"move_file":      tools.NewMoveFileTool(cfg.ConfirmFn),
+		"delete_file":    tools.NewDeleteFileTool(cfg.ConfirmFn),
+		"undo_last_edit": tools.NewUndoTool(cfg.ConfirmFn),
+		"run_command":    tools.NewBashTool(cfg.ConfirmFn),
+		"run_in_sandbox": tools.NewSandboxTool(cfg.ConfirmFn),
+		"glob":           tools.NewGlobTool(),
+		"grep":           tools.NewGrepTool(),
+		"web_fetch":      tools.NewWebFetchTool(),
+		"env_info":       tools.NewEnvInfoTool(),
+		"todo":           tools.NewTodoTool(todos),
 	}
 
 	// Register tools based on config
@@ -147,7 +205,7 @@ func NewWithConfig(cfg AgentConfig) *Agent {
 	// Determine system prompt
 	systemPrompt := cfg.SystemPrompt
 	if systemPrompt == "" {
-		systemPrompt = reg.BuildSystemPrompt()
+		systemPrompt = buildSystemPrompt(reg)
 	}
 
 	// Determine max iterations
@@ -162,11 +220,28 @@ func NewWithConfig(cfg AgentConfig) *Agent {
 		maxRetries = 3
 	}
 
+	// Determine the per-turn read cap
+	maxReads := cfg.MaxReads
+	if maxReads <= 0 {
+		maxReads = config.GetMaxReadsPerTurn()
+	}
+
+	// Determine automatic continuation
+	maxNudges := cfg.MaxNudges
+	if maxNudges <= 0 {
+		maxNudges = config.GetAutoContinue()
+	}
+
 	return &Agent{
 		provider:       cfg.Provider,
 		registry:       reg,
 		maxIterations:  maxIter,
 		maxToolRetries: maxRetries,
+		todos:          todos,
+		customPrompt:   cfg.SystemPrompt != "",
+		reads:          tools.NewReadLimit(maxReads),
+		maxNudges:      maxNudges,
+		nudgePattern:   compileNudgePattern(config.GetAutoContinuePattern()),
 		messages: []llm.Message{
 			{Role: "system", Content: systemPrompt},
 		},
@@ -178,30 +253,106 @@ func (a *Agent) Provider() llm.Provider {
 	return a.provider
 }
 
+// SetProvider switches the agent to another provider or model, keeping the
+// conversation. It fails while a turn is in flight.
+func (a *Agent) SetProvider(provider llm.Provider) error {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.inTurn {
+		return ErrTurnInProgress
+	}
+	a.provider = provider
+	return nil
+}
+
+// Todos returns the task list maintained by the todo tool
+func (a *Agent) Todos() *tools.TodoList {
+	return a.todos
+}
+
 // SetEventHandler sets the callback handler for agent events
 func (a *Agent) SetEventHandler(h EventHandler) {
 	a.handler = h
 }
 
+// Tools returns the definitions of the tools the agent can call, sorted by
+// name
+func (a *Agent) Tools() []tools.ToolDefinition {
+	defs := a.registry.List()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
 // AddTool dynamically registers a new tool
 func (a *Agent) AddTool(tool tools.Tool) {
 	a.registry.Register(tool)
 	// Rebuild system prompt with new tool
-	a.messages[0].Content = a.registry.BuildSystemPrompt()
+	a.messages[0].Content, _ = a.registry.BuildSystemPrompt()
 }
 
 // Chat sends a message and returns the response with tool execution info.
-// All providers must implement ToolProvider for native tool calling support.
+// Providers without native tool calling are given the tools in the system
+// prompt instead.
 func (a *Agent) Chat(ctx context.Context, userMessage string) (*ChatResult, error) {
-	toolProvider, ok := a.provider.(llm.ToolProvider)
-	if !ok {
-		return nil, fmt.Errorf("provider does not support native tool calling (must implement ToolProvider interface)")
+	toolProvider := tools.AsToolProvider(a.provider)
+	ctx, err := a.beginTurn(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer a.endTurn()
 	return a.chatWithNativeTools(ctx, userMessage, toolProvider)
 }
 
+// beginTurn claims the agent for one turn, failing if a turn is in flight.
+// The returned context is canceled by Cancel or when the turn ends.
+func (a *Agent) beginTurn(ctx context.Context) (context.Context, error) {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.inTurn {
+		return nil, ErrTurnInProgress
+	}
+	a.inTurn = true
+	a.reads.Reset()
+	ctx, a.cancelTurn = context.WithCancel(ctx)
+	return ctx, nil
+}
+
+// endTurn releases the agent after a turn finishes
+func (a *Agent) endTurn() {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	a.inTurn = false
+	if a.cancelTurn != nil {
+		a.cancelTurn()
+		a.cancelTurn = nil
+	}
+}
+
+// Cancel interrupts the turn in flight, aborting its LLM request and any
+// running tool. Tools that have not started are skipped. A streaming turn
+// still ends with an error event wrapping llm.ErrInterrupted and closes its
+// channel, which the caller should drain. It reports whether a turn was
+// running.
+func (a *Agent) Cancel() bool {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.cancelTurn == nil {
+		return false
+	}
+	a.cancelTurn()
+	return true
+}
+
+// Busy reports whether a turn is currently in flight
+func (a *Agent) Busy() bool {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	return a.inTurn
+}
+
 // chatWithNativeTools uses the provider's native tool calling API
 func (a *Agent) chatWithNativeTools(ctx context.Context, userMessage string, toolProvider llm.ToolProvider) (*ChatResult, error) {
+	a.turns = append(a.turns, len(a.messages))
 	a.messages = append(a.messages, llm.Message{Role: "user", Content: userMessage})
 
 	result := &ChatResult{
@@ -212,62 +363,37 @@ func (a *Agent) chatWithNativeTools(ctx context.Context, userMessage string, too
 	llmTools := a.registry.GetOpenAIToolDefinitions()
 
 	retryCount := 0 // Total retries allowed per Chat() call
+	nudges := 0     // Automatic continue turns used
+	var preface string
 
 	for {
 		if a.handler != nil {
 			a.handler.OnThinking()
 		}
 
-		response, err := toolProvider.GenerateWithTools(ctx, a.messages, llmTools)
+		start := time.Now()
+		response, err := toolProvider.GenerateWithTools(ctx, a.requestMessages(), llmTools)
+		a.logRequest(start, false, err)
 		if err != nil {
-			return nil, err
+			return nil, llm.ClassifyError(ctx, err)
 		}
 
 		// Check if model returned tool calls
 		if len(response.ToolCalls) > 0 {
-			// Convert OpenAI tool calls to our ToolCall format with retry on parse failure
-			var toolCalls []tools.ToolCall
-			var parseErrors []string
-
-			for _, tc := range response.ToolCalls {
-				// Parse arguments JSON
-				var args map[string]any
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-					parseErrors = append(parseErrors, fmt.Sprintf(
-						"Tool '%s' (id: %s): failed to parse arguments: %v. Raw: %s",
-						tc.Function.Name, tc.ID, err, tc.Function.Arguments,
-					))
-					continue
-				}
-				toolCalls = append(toolCalls, tools.ToolCall{
-					ID:        tc.ID,
-					Name:      tc.Function.Name,
-					Arguments: args,
-				})
-			}
-
-			// If there were parse errors, inject error message and retry
-			if len(parseErrors) > 0 && len(toolCalls) == 0 {
+			// Convert OpenAI tool calls to our ToolCall format. Calls with
+			// arguments that cannot be parsed get an error result; if none
+			// could be parsed the model has only so many retries.
+			toolCalls, invalid := parseToolCalls(response.ToolCalls)
+			if len(invalid) == len(toolCalls) {
 				retryCount++
 				if retryCount > a.maxToolRetries {
 					return nil, fmt.Errorf("max tool retries exceeded. Last errors:\n%s",
-						strings.Join(parseErrors, "\n"))
+						strings.Join(invalidMessages(toolCalls, invalid), "\n"))
 				}
-
-				// Inject error message for the model to fix
-				errorMsg := fmt.Sprintf(
-					"Tool call failed due to malformed arguments. Please fix and try again:\n%s",
-					strings.Join(parseErrors, "\n"),
-				)
-				a.messages = append(a.messages,
-					llm.Message{Role: "assistant", Content: response.Content},
-					llm.Message{Role: "user", Content: errorMsg},
-				)
-				continue
 			}
 
 			// Execute tool calls (parallel if multiple)
-			execResults := a.executeToolCalls(ctx, toolCalls)
+			execResults := a.executeToolCalls(ctx, toolCalls, invalid)
 
 			// Record all tool executions
 			for _, exec := range execResults {
@@ -300,74 +426,135 @@ func (a *Agent) chatWithNativeTools(ctx context.Context, userMessage string, too
 			continue
 		}
 
-		// No tool calls - final response
+		// No tool calls - final response, unless it looks cut short
 		a.messages = append(a.messages, llm.Message{Role: "assistant", Content: response.Content})
-		result.Response = response.Content
+		if a.shouldNudge(response.Content, nudges) {
+			nudges++
+			preface += response.Content + "\n\n"
+			a.messages = append(a.messages, llm.Message{Role: "user", Content: nudgePrompt})
+			continue
+		}
+		result.Response = preface + response.Content
 		return result, nil
 	}
 }
 
-// executeToolCalls executes multiple tool calls, in parallel if more than one
-func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []tools.ToolCall) []ToolExecution {
-	if len(toolCalls) == 1 {
-		// Single tool call - execute directly
-		tc := toolCalls[0]
-		if a.handler != nil {
-			a.handler.OnToolUse(tc.Name, tc.Arguments)
-		}
-
-		toolResult := a.registry.Execute(ctx, tc)
-
-		if a.handler != nil {
-			a.handler.OnToolResult(tc.Name, toolResult)
-		}
+// streamResponse streams a single model response to events as "chunk"
+// events, resuming a dropped stream, and returns the full text and any
+// tool calls
+func (a *Agent) streamResponse(ctx context.Context, toolProvider llm.ToolProvider, llmTools []llm.OpenAITool, events chan<- StreamEvent) (string, []llm.OpenAIToolCall, error) {
+	return llm.StreamWithResume(ctx, toolProvider, a.requestMessages(), llmTools, func(text string) {
+		events <- StreamEvent{Type: "chunk", Text: text}
+	})
+}
 
-		return []ToolExecution{{
-			ID:     tc.ID,
-			Name:   tc.Name,
-			Args:   formatArgs(tc.Name, tc.Arguments),
-			Result: toolResult.Output,
-			Error:  toolResult.Error,
-		}}
+// logRequest records a model request and how long it took
+func (a *Agent) logRequest(start time.Time, stream bool, err error) {
+	provider, model := llm.Describe(a.provider)
+	attrs := []any{"provider", provider, "model", model, "stream", stream, "messages", len(a.messages), "latency", time.Since(start)}
+	if err != nil {
+		slog.Warn("llm request failed", append(attrs, "error", err)...)
+		return
 	}
+	slog.Info("llm request", attrs...)
+}
 
-	// Multiple tool calls - execute in parallel
-	results := make([]ToolExecution, len(toolCalls))
-	var wg sync.WaitGroup
+// buildSystemPrompt builds the default system prompt for the registered
+// tools, warning when sections were dropped to fit prompt_token_budget
+func buildSystemPrompt(reg *tools.Registry) string {
+	prompt, dropped := reg.BuildSystemPrompt()
+	if len(dropped) > 0 {
+		logging.Warnf("the system prompt is over prompt_token_budget (%d tokens); left out: %s",
+			config.GetPromptTokenBudget(), strings.Join(dropped, ", "))
+	}
+	return prompt
+}
 
-	for i, tc := range toolCalls {
-		wg.Add(1)
-		go func(idx int, call tools.ToolCall) {
-			defer wg.Done()
+// requestMessages returns the history to send to the provider, with the
+// open todo items appended to the last message
+func (a *Agent) requestMessages() []llm.Message {
+	return a.todos.WithReminder(a.messages)
+}
 
-			if a.handler != nil {
-				a.handler.OnToolUse(call.Name, call.Arguments)
-			}
+// parseToolCalls converts the model's tool calls, repairing slightly
+// malformed argument JSON. Calls whose arguments still cannot be parsed
+// are kept so they get a tool result; their errors are returned by ID.
+func parseToolCalls(calls []llm.OpenAIToolCall) ([]tools.ToolCall, map[string]string) {
+	parsed := make([]tools.ToolCall, len(calls))
+	invalid := make(map[string]string)
+	for i, tc := range calls {
+		args, repaired, err := tools.ParseArguments(tc.Function.Name, tc.Function.Arguments)
+		if err != nil {
+			invalid[tc.ID] = fmt.Sprintf("invalid arguments for %s: %v. The tool was not run; call it again with valid JSON arguments. Raw: %s",
+				tc.Function.Name, err, tc.Function.Arguments)
+		}
+		parsed[i] = tools.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args, Repaired: repaired}
+	}
+	return parsed, invalid
+}
 
-			toolResult := a.registry.Execute(ctx, call)
+// invalidMessages lists the argument errors of calls in call order
+func invalidMessages(calls []tools.ToolCall, invalid map[string]string) []string {
+	var msgs []string
+	for _, call := range calls {
+		if msg, ok := invalid[call.ID]; ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
 
-			if a.handler != nil {
-				a.handler.OnToolResult(call.Name, toolResult)
-			}
+// executeToolCalls executes a batch of tool calls, running read-only
+// tools in parallel, and returns the executions in call order. Calls in
+// invalid are not run; their error becomes the result.
+func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []tools.ToolCall, invalid map[string]string) []ToolExecution {
+	var onStart func(int)
+	var onResult func(int, tools.ToolResult)
+	if a.handler != nil {
+		onStart = func(i int) { a.handler.OnToolUse(toolCalls[i].Name, toolCalls[i].Arguments) }
+		onResult = func(i int, result tools.ToolResult) { a.handler.OnToolResult(toolCalls[i].Name, result) }
+	}
 
-			results[idx] = ToolExecution{
-				ID:     call.ID,
-				Name:   call.Name,
-				Args:   formatArgs(call.Name, call.Arguments),
-				Result: toolResult.Output,
-				Error:  toolResult.Error,
-			}
-		}(i, tc)
+	exec := func(call tools.ToolCall) tools.ToolResult {
+		if msg, ok := invalid[call.ID]; ok {
+			return tools.ToolResult{Success: false, Error: msg}
+		}
+		return a.executeTool(ctx, call)
 	}
+	toolResults := runToolBatch(toolCalls, exec, onStart, onResult)
 
-	wg.Wait()
+	results := make([]ToolExecution, len(toolCalls))
+	for i, tc := range toolCalls {
+		results[i] = ToolExecution{
+			ID:     tc.ID,
+			Name:   tc.Name,
+			Args:   formatArgs(tc.Name, tc.Arguments),
+			Result: toolResults[i].Output,
+			Error:  toolResults[i].Error,
+		}
+	}
 	return results
 }
 
+// executeTool runs one tool call, refusing reads once the turn's read cap
+// is used up so the model narrows its search instead of opening every file
+func (a *Agent) executeTool(ctx context.Context, call tools.ToolCall) tools.ToolResult {
+	if ctx.Err() != nil {
+		return tools.ToolResult{Success: false, Error: fmt.Sprintf("%s was not run: %v", call.Name, llm.ErrInterrupted)}
+	}
+	if err := a.reads.Take(call.Name); err != nil {
+		return tools.ToolResult{Success: false, Error: err.Error()}
+	}
+	start := time.Now()
+	result := a.registry.Execute(ctx, call)
+	slog.Debug("tool call", "tool", call.Name, "id", call.ID, "success", result.Success, "latency", time.Since(start))
+	return result
+}
+
 // formatArgs creates a display string for tool arguments
 func formatArgs(toolName string, args map[string]any) string {
 	switch toolName {
-	case "run_command":
+	case "run_command", "run_in_sandbox":
 		if cmd, ok := args["command"].(string); ok {
 			return cmd
 		}
@@ -379,7 +566,17 @@ func formatArgs(toolName string, args map[string]any) string {
 		if path, ok := args["path"].(string); ok {
 			return path
 		}
-	case "edit_file":
+	case "edit_file", "line_edit":
+		if path, ok := args["path"].(string); ok {
+			return path
+		}
+	case "move_file":
+		source, _ := args["source"].(string)
+		destination, _ := args["destination"].(string)
+		if source != "" || destination != "" {
+			return source + " -> " + destination
+		}
+	case "delete_file":
 		if path, ok := args["path"].(string); ok {
 			return path
 		}
@@ -396,6 +593,14 @@ func formatArgs(toolName string, args map[string]any) string {
 		if pattern, ok := args["pattern"].(string); ok {
 			return pattern
 		}
+	case "web_fetch":
+		if url, ok := args["url"].(string); ok {
+			return url
+		}
+	case "todo":
+		if action, ok := args["action"].(string); ok {
+			return action
+		}
 	}
 	// Fallback: JSON representation
 	bytes, _ := json.Marshal(args)
@@ -407,42 +612,99 @@ func (a *Agent) History() []llm.Message {
 	return a.messages
 }
 
-// Reset clears the conversation history (keeps system prompt)
+// Turns returns how many user messages the history holds
+func (a *Agent) Turns() int {
+	return len(a.turns)
+}
+
+// RewindTo truncates the history to just before turn n, counted from 0,
+// dropping that turn's user message and everything after it. It returns
+// the dropped user message so it can be edited or sent again.
+func (a *Agent) RewindTo(n int) (string, error) {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	return a.rewindTo(n)
+}
+
+// Retry removes the last turn from the history, including its responses
+// and tool results, and returns its user message so it can be sent again
+func (a *Agent) Retry() (string, error) {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	return a.rewindTo(len(a.turns) - 1)
+}
+
+// rewindTo implements RewindTo; the caller holds turnMu
+func (a *Agent) rewindTo(n int) (string, error) {
+	if a.inTurn {
+		return "", ErrTurnInProgress
+	}
+	if n < 0 || n >= len(a.turns) {
+		return "", ErrNoTurn
+	}
+
+	start := a.turns[n]
+	message := a.messages[start].Content
+	a.messages = a.messages[:start]
+	a.turns = a.turns[:n]
+	return message, nil
+}
+
+// Reset clears the conversation history and the todo list. A default
+// system prompt is rebuilt so edits to ZCODE.md take effect.
 func (a *Agent) Reset() {
 	a.messages = a.messages[:1] // Keep only system prompt
+	a.turns = nil
+	if !a.customPrompt {
+		// Pick up edits to the project's ZCODE.md
+		a.messages[0].Content = buildSystemPrompt(a.registry)
+	}
+	if a.todos != nil {
+		a.todos.Clear()
+	}
 }
 
 // ChatStream sends a message and streams the response through a channel.
-// Unlike Chat(), tool calls are executed sequentially rather than in parallel.
-// This is intentional to ensure proper event ordering for streaming UI updates:
-// each tool_start event is followed by its corresponding tool_result before
-// the next tool begins, making the output easier to follow in real-time.
+// As in Chat(), consecutive read-only tool calls run in parallel while
+// other tools run one at a time. Events keep call order regardless: each
+// tool_start event is followed by its corresponding tool_result before the
+// next tool is reported, making the output easier to follow in real-time.
 //
 // When multiple tools are requested, tool_batch_start and tool_batch_end events
-// are emitted to indicate the grouping, but tools within the batch still execute
-// sequentially (not in parallel) for predictable streaming output.
+// are emitted to indicate the grouping.
 //
-// All providers must implement ToolProvider for native tool calling support.
+// Providers without native tool calling are given the tools in the system
+// prompt instead. Starting a stream while another turn is in flight yields
+// a single error event with ErrTurnInProgress.
 func (a *Agent) ChatStream(ctx context.Context, userMessage string) <-chan StreamEvent {
-	toolProvider, ok := a.provider.(llm.ToolProvider)
-	if !ok {
-		events := make(chan StreamEvent)
-		go func() {
-			events <- StreamEvent{Type: "error", Error: fmt.Errorf("provider does not support native tool calling (must implement ToolProvider interface)")}
-			close(events)
-		}()
-		return events
+	toolProvider := tools.AsToolProvider(a.provider)
+	ctx, err := a.beginTurn(ctx)
+	if err != nil {
+		return errorStream(err)
 	}
 	return a.chatStreamWithNativeTools(ctx, userMessage, toolProvider)
 }
 
-// chatStreamWithNativeTools uses the provider's native streaming tool calling API
+// errorStream returns a channel carrying a single error event
+func errorStream(err error) <-chan StreamEvent {
+	events := make(chan StreamEvent)
+	go func() {
+		events <- StreamEvent{Type: "error", Error: err}
+		close(events)
+	}()
+	return events
+}
+
+// chatStreamWithNativeTools uses the provider's native streaming tool calling API.
+// The caller must have claimed the turn; it is released when the stream ends.
 func (a *Agent) chatStreamWithNativeTools(ctx context.Context, userMessage string, toolProvider llm.ToolProvider) <-chan StreamEvent {
 	events := make(chan StreamEvent)
 
 	go func() {
 		defer close(events)
+		defer a.endTurn() // Release before close so the consumer can start the next turn
 
+		a.turns = append(a.turns, len(a.messages))
 		a.messages = append(a.messages, llm.Message{Role: "user", Content: userMessage})
 
 		events <- StreamEvent{Type: "start"}
@@ -451,76 +713,39 @@ func (a *Agent) chatStreamWithNativeTools(ctx context.Context, userMessage strin
 		llmTools := a.registry.GetOpenAIToolDefinitions()
 
 		retryCount := 0 // Total retries allowed per ChatStream() call
+		nudges := 0     // Automatic continue turns used
+		var preface string
 
 		for {
 			// Use streaming generation with tools
-			chunks, err := toolProvider.GenerateStreamWithTools(ctx, a.messages, llmTools)
+			start := time.Now()
+			fullResponse, toolCalls, err := a.streamResponse(ctx, toolProvider, llmTools, events)
+			a.logRequest(start, true, err)
 			if err != nil {
-				events <- StreamEvent{Type: "error", Error: err}
-				return
-			}
-
-			var fullResponse string
-			var toolCalls []llm.OpenAIToolCall
-
-			for chunk := range chunks {
-				if chunk.Error != nil {
-					events <- StreamEvent{Type: "error", Error: chunk.Error}
-					return
-				}
-
-				if chunk.Done {
-					fullResponse = chunk.Text
-					toolCalls = chunk.ToolCalls
-				} else if chunk.Text != "" {
-					// Stream the chunk to UI
-					events <- StreamEvent{Type: "chunk", Text: chunk.Text}
+				// Keep what was already shown rather than losing it; any
+				// tool calls still streaming are incomplete and dropped
+				if fullResponse != "" {
+					a.messages = append(a.messages, llm.Message{Role: "assistant", Content: fullResponse})
 				}
+				events <- StreamEvent{Type: "error", Error: llm.ClassifyError(ctx, err), Partial: fullResponse}
+				return
 			}
 
 			// Check if model returned tool calls
 			if len(toolCalls) > 0 {
-				// Parse and validate tool calls with retry on failure
-				var parsedToolCalls []tools.ToolCall
-				var parseErrors []string
-
-				for _, tc := range toolCalls {
-					var args map[string]any
-					if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-						parseErrors = append(parseErrors, fmt.Sprintf(
-							"Tool '%s' (id: %s): failed to parse arguments: %v",
-							tc.Function.Name, tc.ID, err,
-						))
-						continue
-					}
-					parsedToolCalls = append(parsedToolCalls, tools.ToolCall{
-						ID:        tc.ID,
-						Name:      tc.Function.Name,
-						Arguments: args,
-					})
-				}
-
-				// If all tool calls failed to parse, retry
-				if len(parseErrors) > 0 && len(parsedToolCalls) == 0 {
+				// Parse tool calls; ones with arguments that cannot be
+				// parsed get an error result, and if none could be parsed
+				// the model has only so many retries
+				parsedToolCalls, invalid := parseToolCalls(toolCalls)
+				if len(invalid) == len(parsedToolCalls) {
 					retryCount++
 					if retryCount > a.maxToolRetries {
 						events <- StreamEvent{
 							Type:  "error",
-							Error: fmt.Errorf("max tool retries exceeded: %s", strings.Join(parseErrors, "; ")),
+							Error: fmt.Errorf("max tool retries exceeded: %s", strings.Join(invalidMessages(parsedToolCalls, invalid), "; ")),
 						}
 						return
 					}
-
-					// Inject error message for retry
-					errorMsg := fmt.Sprintf(
-						"Tool call failed due to malformed arguments. Please fix and try again:\n%s",
-						strings.Join(parseErrors, "\n"),
-					)
-					a.messages = append(a.messages,
-						llm.Message{Role: "assistant", Content: fullResponse},
-						llm.Message{Role: "user", Content: errorMsg},
-					)
-					continue
 				}
 
 				// Add assistant message with tool calls to history FIRST
@@ -538,23 +763,33 @@ func (a *Agent) chatStreamWithNativeTools(ctx context.Context, userMessage strin
 					}
 				}
 
-				// Execute tool calls and stream results
-				for _, toolCall := range parsedToolCalls {
-					// Format args for display
-					argsStr := formatArgs(toolCall.Name, toolCall.Arguments)
-
-					// Notify about tool start
+				// Execute tool calls and stream results. Read-only tools run
+				// in parallel, but each tool_start is followed by its
+				// tool_result before the next tool is reported.
+				exec := func(toolCall tools.ToolCall) tools.ToolResult {
+					if msg, ok := invalid[toolCall.ID]; ok {
+						return tools.ToolResult{Success: false, Error: msg}
+					}
+					// Forward any live output (e.g. long-running commands)
+					toolCtx := tools.WithOutputFunc(ctx, func(chunk string) {
+						select {
+						case events <- StreamEvent{Type: "tool_output", ToolID: toolCall.ID, ToolName: toolCall.Name, Text: chunk}:
+						case <-ctx.Done():
+						}
+					})
+					return a.executeTool(toolCtx, toolCall)
+				}
+				onStart := func(i int) {
+					toolCall := parsedToolCalls[i]
 					events <- StreamEvent{
 						Type:     "tool_start",
 						ToolID:   toolCall.ID,
 						ToolName: toolCall.Name,
-						ToolArgs: argsStr,
+						ToolArgs: formatArgs(toolCall.Name, toolCall.Arguments),
 					}
-
-					// Execute tool
-					toolResult := a.registry.Execute(ctx, toolCall)
-
-					// Notify about tool result
+				}
+				onResult := func(i int, toolResult tools.ToolResult) {
+					toolCall := parsedToolCalls[i]
 					events <- StreamEvent{
 						Type:       "tool_result",
 						ToolID:     toolCall.ID,
@@ -575,6 +810,7 @@ func (a *Agent) chatStreamWithNativeTools(ctx context.Context, userMessage strin
 						ToolCallID: toolCall.ID,
 					})
 				}
+				runToolBatch(parsedToolCalls, exec, onStart, onResult)
 
 				// Notify about batch end if multiple tools
 				if len(parsedToolCalls) > 1 {
@@ -587,9 +823,16 @@ func (a *Agent) chatStreamWithNativeTools(ctx context.Context, userMessage strin
 				continue
 			}
 
-			// Not a tool call - final response
+			// Not a tool call - final response, unless it looks cut short
 			a.messages = append(a.messages, llm.Message{Role: "assistant", Content: fullResponse})
-			events <- StreamEvent{Type: "done", FinalResponse: fullResponse}
+			if a.shouldNudge(fullResponse, nudges) {
+				nudges++
+				preface += fullResponse + "\n\n"
+				a.messages = append(a.messages, llm.Message{Role: "user", Content: nudgePrompt})
+				events <- StreamEvent{Type: "chunk", Text: "\n\n"}
+				continue
+			}
+			events <- StreamEvent{Type: "done", FinalResponse: preface + fullResponse}
 			return
 		}
 
@@ -2,9 +2,16 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/simonyos/Z-CODE/internal/config"
 	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/logging"
 	"github.com/simonyos/Z-CODE/internal/tools"
 )
 
@@ -47,6 +54,10 @@ func (m *MockToolProvider) GenerateStream(ctx context.Context, messages []llm.Me
 	return ch, nil
 }
 
+func (m *MockToolProvider) Validate(ctx context.Context) error {
+	return nil
+}
+
 func (m *MockToolProvider) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (*llm.ToolCallResponse, error) {
 	if m.callCount >= len(m.responses) {
 		return &llm.ToolCallResponse{Content: "final response", Done: true}, nil
@@ -85,7 +96,7 @@ func (h *MockEventHandler) OnToolResult(name string, result tools.ToolResult) {
 	h.ToolResultLogs = append(h.ToolResultLogs, name)
 }
 
-func alwaysConfirm(prompt string) bool {
+func alwaysConfirm(req tools.ConfirmRequest) bool {
 	return true
 }
 
@@ -331,6 +342,187 @@ func TestAgent_Reset(t *testing.T) {
 	}
 }
 
+func TestAgent_PromptBudgetWarning(t *testing.T) {
+	old := config.ConfigDir()
+	config.UseDir(t.TempDir())
+	t.Cleanup(func() { config.UseDir(old) })
+	t.Chdir(t.TempDir())
+	if err := config.Set("prompt_token_budget", "10"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	logging.HoldStderr()
+	logging.TakeWarnings()
+
+	agent := New(NewMockToolProvider(), alwaysConfirm)
+	warnings := strings.Join(logging.TakeWarnings(), "\n")
+	if !strings.Contains(warnings, "left out: objective") {
+		t.Errorf("warnings = %q, want the dropped sections named", warnings)
+	}
+
+	// Reset rebuilds the prompt and warns again
+	agent.Reset()
+	if warnings := logging.TakeWarnings(); len(warnings) != 1 {
+		t.Errorf("warnings after Reset() = %q, want one", warnings)
+	}
+}
+
+// RecordingToolProvider records the messages of each request
+type RecordingToolProvider struct {
+	MockToolProvider
+	requests [][]llm.Message
+}
+
+func (p *RecordingToolProvider) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (*llm.ToolCallResponse, error) {
+	p.requests = append(p.requests, append([]llm.Message(nil), messages...))
+	return p.MockToolProvider.GenerateWithTools(ctx, messages, tools)
+}
+
+func TestAgent_TodoReminder(t *testing.T) {
+	provider := &RecordingToolProvider{MockToolProvider: *NewMockToolProvider(
+		ToolCallResponse("", llm.OpenAIToolCall{
+			ID:   "call_1",
+			Type: "function",
+			Function: struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			}{
+				Name:      "todo",
+				Arguments: `{"action":"add","items":["Write parser","Add tests"]}`,
+			},
+		}),
+		TextResponse("Planned."),
+	)}
+	agent := New(provider, alwaysConfirm)
+
+	if _, err := agent.Chat(context.Background(), "Build a parser"); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if len(provider.requests) != 2 {
+		t.Fatalf("provider saw %d requests, want 2", len(provider.requests))
+	}
+	first := provider.requests[0]
+	if strings.Contains(first[len(first)-1].Content, "Remaining tasks") {
+		t.Error("first request should have no reminder before any tasks exist")
+	}
+	second := provider.requests[1]
+	last := second[len(second)-1].Content
+	if !strings.Contains(last, "Remaining tasks:\n1. Write parser\n2. Add tests") {
+		t.Errorf("second request last message = %q, want task reminder", last)
+	}
+
+	// The reminder is added per request, never stored in history
+	for _, msg := range agent.History() {
+		if strings.Contains(msg.Content, "<todo>") {
+			t.Errorf("history message contains reminder: %q", msg.Content)
+		}
+	}
+
+	agent.Reset()
+	if len(agent.Todos().Items()) != 0 {
+		t.Error("Reset() should clear the todo list")
+	}
+}
+
+func TestAgent_ReadLimitPerTurn(t *testing.T) {
+	listCall := func(id string) llm.OpenAIToolCall {
+		return llm.OpenAIToolCall{
+			ID:   id,
+			Type: "function",
+			Function: struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			}{
+				Name:      "list_dir",
+				Arguments: `{"path":"."}`,
+			},
+		}
+	}
+	provider := NewMockToolProvider(
+		ToolCallResponse("", listCall("call_1"), listCall("call_2"), listCall("call_3")),
+		TextResponse("Done."),
+		ToolCallResponse("", listCall("call_4")),
+		TextResponse("Done again."),
+	)
+	agent := NewWithConfig(AgentConfig{Provider: provider, MaxReads: 2})
+
+	result, err := agent.Chat(context.Background(), "Look around")
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	var limited int
+	for _, tc := range result.ToolCalls {
+		if strings.Contains(tc.Error, "read limit reached") {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("%d of 3 reads hit the limit, want 1", limited)
+	}
+
+	// The count resets with the next user message
+	result, err = agent.Chat(context.Background(), "Look again")
+	if err != nil {
+		t.Fatalf("second Chat() error = %v", err)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Error != "" {
+		t.Errorf("second turn tool calls = %+v, want one successful read", result.ToolCalls)
+	}
+}
+
+func TestLooksIncomplete(t *testing.T) {
+	pattern := compileNudgePattern("")
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"I've updated the handler and the tests pass.", false},
+		{"Changed files:\n- main.go\n- util.go", false},
+		{"Done", false},
+		{"", false},
+		{"Here is the fix:\n```go\nfunc main() {", true},
+		{"The config loader now validates keys, and", true},
+		{"I updated the parser and moved the helpers into the", true},
+		{"The parser is fixed.\nNext, I'll update the tests.", true},
+		{"First part done;", true},
+	}
+
+	for _, tt := range tests {
+		if got := looksIncomplete(tt.text, pattern); got != tt.want {
+			t.Errorf("looksIncomplete(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAgent_Chat_NudgesIncompleteResponse(t *testing.T) {
+	provider := NewMockToolProvider(
+		TextResponse("I fixed the parser. Now I'll update the tests."),
+		TextResponse("Updated the tests."),
+		TextResponse("This should never be requested."),
+	)
+	agent := NewWithConfig(AgentConfig{Provider: provider, MaxNudges: 1})
+
+	result, err := agent.Chat(context.Background(), "Fix the parser")
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	want := "I fixed the parser. Now I'll update the tests.\n\nUpdated the tests."
+	if result.Response != want {
+		t.Errorf("Response = %q, want %q", result.Response, want)
+	}
+	if provider.callCount != 2 {
+		t.Errorf("provider called %d times, want 2", provider.callCount)
+	}
+
+	// With nudging off, the first reply ends the turn
+	provider = NewMockToolProvider(TextResponse("Now I'll update the tests."))
+	agent = NewWithConfig(AgentConfig{Provider: provider})
+	agent.maxNudges = 0
+	if result, _ := agent.Chat(context.Background(), "Fix it"); result.Response != "Now I'll update the tests." {
+		t.Errorf("Response without nudging = %q", result.Response)
+	}
+}
+
 func TestAgent_AddTool(t *testing.T) {
 	provider := NewMockToolProvider()
 	agent := New(provider, alwaysConfirm)
@@ -355,6 +547,21 @@ func TestAgent_AddTool(t *testing.T) {
 	if tool == nil {
 		t.Error("AddTool() tool is nil")
 	}
+
+	// Tools lists the new tool alongside the built-in ones, sorted by name
+	defs := agent.Tools()
+	found := false
+	for i, def := range defs {
+		if i > 0 && defs[i-1].Name > def.Name {
+			t.Errorf("Tools() not sorted: %q before %q", defs[i-1].Name, def.Name)
+		}
+		if def.Name == "custom_tool" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Tools() does not list custom_tool")
+	}
 }
 
 func TestAgent_ChatStream(t *testing.T) {
@@ -555,3 +762,569 @@ func TestAgent_Chat_ContextCancellation(t *testing.T) {
 	// The key is that it shouldn't panic
 	_ = err // Acknowledge we're intentionally ignoring the error
 }
+
+// ScriptedStreamProvider replays fixed chunk sequences, one per stream call
+type ScriptedStreamProvider struct {
+	MockToolProvider
+	streams  [][]llm.ToolStreamChunk
+	requests [][]llm.Message
+}
+
+func (p *ScriptedStreamProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	p.requests = append(p.requests, append([]llm.Message(nil), messages...))
+	var script []llm.ToolStreamChunk
+	if len(p.requests) <= len(p.streams) {
+		script = p.streams[len(p.requests)-1]
+	}
+	ch := make(chan llm.ToolStreamChunk, len(script))
+	for _, chunk := range script {
+		ch <- chunk
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestAgent_ChatStream_ResumesDroppedStream(t *testing.T) {
+	provider := &ScriptedStreamProvider{
+		streams: [][]llm.ToolStreamChunk{
+			{
+				{Text: "The quick brown "},
+				{Text: "fox jumps"},
+				{Error: errors.New("connection reset")},
+			},
+			{
+				{Text: "fox jumps over"},
+				{Text: " the lazy dog."},
+				{Text: "fox jumps over the lazy dog.", Done: true},
+			},
+		},
+	}
+	agent := New(provider, alwaysConfirm)
+
+	var streamed strings.Builder
+	var final string
+	for event := range agent.ChatStream(context.Background(), "Tell me") {
+		switch event.Type {
+		case "chunk":
+			streamed.WriteString(event.Text)
+		case "done":
+			final = event.FinalResponse
+		case "error":
+			t.Fatalf("unexpected error event: %v", event.Error)
+		}
+	}
+
+	want := "The quick brown fox jumps over the lazy dog."
+	if streamed.String() != want {
+		t.Errorf("streamed text = %q, want %q", streamed.String(), want)
+	}
+	if final != want {
+		t.Errorf("final response = %q, want %q", final, want)
+	}
+
+	// The resume request carries the partial answer and a continue prompt
+	if len(provider.requests) != 2 {
+		t.Fatalf("expected 2 stream requests, got %d", len(provider.requests))
+	}
+	resumeReq := provider.requests[1]
+	if resumeReq[len(resumeReq)-2].Content != "The quick brown fox jumps" {
+		t.Errorf("resume request partial = %q", resumeReq[len(resumeReq)-2].Content)
+	}
+
+	// History keeps only the merged answer, not the resume scaffolding
+	history := agent.History()
+	if len(history) != 3 {
+		t.Fatalf("history length = %d, want 3", len(history))
+	}
+	if history[2].Content != want {
+		t.Errorf("history answer = %q, want %q", history[2].Content, want)
+	}
+}
+
+func TestAgent_ChatStream_ErrorBeforeAnyText(t *testing.T) {
+	provider := &ScriptedStreamProvider{
+		streams: [][]llm.ToolStreamChunk{
+			{{Error: errors.New("connection refused")}},
+		},
+	}
+	agent := New(provider, alwaysConfirm)
+
+	var gotErr error
+	for event := range agent.ChatStream(context.Background(), "Tell me") {
+		if event.Type == "error" {
+			gotErr = event.Error
+		}
+	}
+
+	if gotErr == nil {
+		t.Error("expected error event when stream fails before any text")
+	}
+	if len(provider.requests) != 1 {
+		t.Errorf("should not resume without delivered text, got %d requests", len(provider.requests))
+	}
+}
+
+func TestAgent_ChatStream_KeepsPartialResponse(t *testing.T) {
+	drop := []llm.ToolStreamChunk{{Error: errors.New("connection reset")}}
+	provider := &ScriptedStreamProvider{
+		streams: [][]llm.ToolStreamChunk{
+			{{Text: "Here is the first half"}, {Error: errors.New("connection reset")}},
+			drop,
+			drop,
+		},
+	}
+	agent := New(provider, alwaysConfirm)
+
+	var errEvent StreamEvent
+	for event := range agent.ChatStream(context.Background(), "Tell me") {
+		if event.Type == "error" {
+			errEvent = event
+		}
+	}
+
+	if errEvent.Error == nil {
+		t.Fatal("expected an error event once resuming gave up")
+	}
+	if errEvent.Partial != "Here is the first half" {
+		t.Errorf("Partial = %q, want the streamed text", errEvent.Partial)
+	}
+	history := agent.History()
+	last := history[len(history)-1]
+	if last.Role != "assistant" || last.Content != "Here is the first half" {
+		t.Errorf("last history message = %+v, want the partial answer", last)
+	}
+}
+
+// BlockingStreamProvider holds each stream open until release is closed
+type BlockingStreamProvider struct {
+	MockToolProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *BlockingStreamProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	p.started <- struct{}{}
+	ch := make(chan llm.ToolStreamChunk, 1)
+	go func() {
+		defer close(ch)
+		<-p.release
+		ch <- llm.ToolStreamChunk{Text: "done", Done: true}
+	}()
+	return ch, nil
+}
+
+func TestAgent_ChatStream_RejectsOverlappingTurns(t *testing.T) {
+	provider := &BlockingStreamProvider{started: make(chan struct{}, 2), release: make(chan struct{})}
+	ag := New(provider, alwaysConfirm)
+	ctx := context.Background()
+
+	// Start two turns at the same moment
+	var wg sync.WaitGroup
+	results := make([][]StreamEvent, 2)
+	finished := make(chan int, 2)
+	gate := make(chan struct{})
+	for i := range results {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			<-gate
+			for event := range ag.ChatStream(ctx, fmt.Sprintf("message %d", idx)) {
+				results[idx] = append(results[idx], event)
+			}
+			finished <- idx
+		}(i)
+	}
+	close(gate)
+
+	// One turn reaches the provider and blocks there, so the other must
+	// finish first by being rejected; then let the first one complete
+	<-provider.started
+	if !ag.Busy() {
+		t.Fatal("agent should be busy while a turn is in flight")
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second turn was not rejected while the first was in flight")
+	}
+	close(provider.release)
+	wg.Wait()
+
+	var completed, rejected int
+	for _, events := range results {
+		last := events[len(events)-1]
+		switch {
+		case last.Type == "done":
+			completed++
+		case last.Type == "error" && errors.Is(last.Error, ErrTurnInProgress):
+			rejected++
+		default:
+			t.Errorf("unexpected final event %+v", last)
+		}
+	}
+	if completed != 1 || rejected != 1 {
+		t.Fatalf("completed = %d, rejected = %d; want exactly one of each", completed, rejected)
+	}
+
+	// Only the accepted turn touched the history
+	userMessages := 0
+	for _, msg := range ag.History() {
+		if msg.Role == "user" {
+			userMessages++
+		}
+	}
+	if userMessages != 1 {
+		t.Errorf("history has %d user messages, want 1", userMessages)
+	}
+
+	// The guard is released once the stream closes
+	if ag.Busy() {
+		t.Error("agent should not be busy after the turn ends")
+	}
+	if _, err := ag.Chat(ctx, "next"); err != nil {
+		t.Errorf("Chat() after the turn ended error = %v", err)
+	}
+}
+
+// CancelAwareStreamProvider holds each stream open until its context ends
+type CancelAwareStreamProvider struct {
+	MockToolProvider
+	started chan struct{}
+}
+
+func (p *CancelAwareStreamProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	p.started <- struct{}{}
+	ch := make(chan llm.ToolStreamChunk, 1)
+	go func() {
+		defer close(ch)
+		<-ctx.Done()
+		ch <- llm.ToolStreamChunk{Error: ctx.Err()}
+	}()
+	return ch, nil
+}
+
+func TestAgent_Cancel(t *testing.T) {
+	provider := &CancelAwareStreamProvider{started: make(chan struct{}, 1)}
+	ag := New(provider, alwaysConfirm)
+
+	if ag.Cancel() {
+		t.Error("Cancel() should report false when no turn is running")
+	}
+
+	events := ag.ChatStream(context.Background(), "hello")
+	var last StreamEvent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			last = event
+		}
+	}()
+
+	<-provider.started
+	if !ag.Cancel() {
+		t.Error("Cancel() should report true while a turn is running")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after Cancel()")
+	}
+	if last.Type != "error" || !errors.Is(last.Error, llm.ErrInterrupted) {
+		t.Errorf("last event = %+v, want an error wrapping llm.ErrInterrupted", last)
+	}
+	if ag.Busy() {
+		t.Error("agent should not be busy after a canceled turn")
+	}
+	if ag.Cancel() {
+		t.Error("Cancel() should report false after the turn ended")
+	}
+}
+
+func TestAgent_SetProvider(t *testing.T) {
+	first := NewMockToolProvider(TextResponse("first"))
+	ag := New(first, alwaysConfirm)
+	if _, err := ag.Chat(context.Background(), "hello"); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	historyLen := len(ag.History())
+
+	second := NewMockToolProvider(TextResponse("second"))
+	if err := ag.SetProvider(second); err != nil {
+		t.Fatalf("SetProvider() error = %v", err)
+	}
+	if ag.Provider() != second {
+		t.Error("Provider() should return the new provider")
+	}
+	if len(ag.History()) != historyLen {
+		t.Errorf("history length = %d after switching, want %d", len(ag.History()), historyLen)
+	}
+
+	result, err := ag.Chat(context.Background(), "again")
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if result.Response != "second" || first.callCount != 1 {
+		t.Errorf("Chat() after switching = %q with %d calls to the old provider", result.Response, first.callCount)
+	}
+
+	// Switching is refused mid-turn
+	blocking := &CancelAwareStreamProvider{started: make(chan struct{}, 1)}
+	ag = New(blocking, alwaysConfirm)
+	events := ag.ChatStream(context.Background(), "hello")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range events {
+		}
+	}()
+	<-blocking.started
+	if err := ag.SetProvider(second); !errors.Is(err, ErrTurnInProgress) {
+		t.Errorf("SetProvider() during a turn error = %v, want ErrTurnInProgress", err)
+	}
+	ag.Cancel()
+	<-done
+}
+
+func TestAgent_Retry(t *testing.T) {
+	provider := NewMockToolProvider(
+		TextResponse("first answer"),
+		ToolCallResponse("", llm.OpenAIToolCall{
+			ID:   "call_1",
+			Type: "function",
+			Function: struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			}{
+				Name:      "todo",
+				Arguments: `{"action": "list"}`,
+			},
+		}),
+		TextResponse("second answer"),
+		TextResponse("second answer, again"),
+	)
+	ag := New(provider, alwaysConfirm)
+
+	if _, err := ag.Retry(); !errors.Is(err, ErrNoTurn) {
+		t.Errorf("Retry() before any message error = %v, want ErrNoTurn", err)
+	}
+
+	ctx := context.Background()
+	if _, err := ag.Chat(ctx, "one"); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	afterFirst := len(ag.History())
+	if _, err := ag.Chat(ctx, "two"); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	// The second turn, with its tool call and result, is rewound
+	prompt, err := ag.Retry()
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if prompt != "two" {
+		t.Errorf("Retry() = %q, want %q", prompt, "two")
+	}
+	if len(ag.History()) != afterFirst {
+		t.Errorf("history length after Retry() = %d, want %d", len(ag.History()), afterFirst)
+	}
+
+	result, err := ag.Chat(ctx, prompt)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if result.Response != "second answer, again" {
+		t.Errorf("retried response = %q", result.Response)
+	}
+}
+
+func TestAgent_RewindTo(t *testing.T) {
+	ag := New(NewMockToolProvider(), alwaysConfirm)
+	ctx := context.Background()
+	base := len(ag.History())
+
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := ag.Chat(ctx, msg); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if ag.Turns() != 3 {
+		t.Fatalf("Turns() = %d, want 3", ag.Turns())
+	}
+
+	prompt, err := ag.RewindTo(1)
+	if err != nil {
+		t.Fatalf("RewindTo(1) error = %v", err)
+	}
+	if prompt != "two" {
+		t.Errorf("RewindTo(1) = %q, want %q", prompt, "two")
+	}
+	if ag.Turns() != 1 {
+		t.Errorf("Turns() after RewindTo(1) = %d, want 1", ag.Turns())
+	}
+	history := ag.History()
+	if len(history) != base+2 || history[base].Content != "one" {
+		t.Errorf("history after RewindTo(1) should end with the first turn, got %d messages", len(history))
+	}
+
+	for _, n := range []int{-1, 1, 5} {
+		if _, err := ag.RewindTo(n); !errors.Is(err, ErrNoTurn) {
+			t.Errorf("RewindTo(%d) error = %v, want ErrNoTurn", n, err)
+		}
+	}
+}
+
+// TextOnlyProvider is a provider without native tool calling
+type TextOnlyProvider struct {
+	replies []string
+	calls   int
+}
+
+func (p *TextOnlyProvider) Generate(ctx context.Context, messages []llm.Message) (string, error) {
+	reply := "final response"
+	if p.calls < len(p.replies) {
+		reply = p.replies[p.calls]
+	}
+	p.calls++
+	return reply, nil
+}
+
+func (p *TextOnlyProvider) GenerateStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	reply, _ := p.Generate(ctx, messages)
+	ch := make(chan llm.StreamChunk, 1)
+	ch <- llm.StreamChunk{Text: reply, Done: true}
+	close(ch)
+	return ch, nil
+}
+
+func (p *TextOnlyProvider) Validate(ctx context.Context) error {
+	return nil
+}
+
+func TestAgent_Chat_PromptToolFallback(t *testing.T) {
+	provider := &TextOnlyProvider{replies: []string{
+		"```tool_call\n{\"name\": \"list_dir\", \"arguments\": {\"path\": \".\"}}\n```",
+		"The directory has files.",
+	}}
+	ag := New(provider, alwaysConfirm)
+
+	result, err := ag.Chat(context.Background(), "What files are here?")
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Name != "list_dir" || result.ToolCalls[0].Error != "" {
+		t.Errorf("ToolCalls = %+v", result.ToolCalls)
+	}
+	if result.Response != "The directory has files." {
+		t.Errorf("Response = %q", result.Response)
+	}
+
+	provider = &TextOnlyProvider{replies: []string{"Plain answer."}}
+	ag = New(provider, alwaysConfirm)
+	var final string
+	for event := range ag.ChatStream(context.Background(), "hi") {
+		if event.Type == "error" {
+			t.Fatalf("ChatStream() error = %v", event.Error)
+		}
+		if event.Type == "done" {
+			final = event.FinalResponse
+		}
+	}
+	if final != "Plain answer." {
+		t.Errorf("FinalResponse = %q", final)
+	}
+}
+
+func TestRunToolBatch(t *testing.T) {
+	names := []string{"read_file", "grep", "glob", "list_dir", "web_fetch", "read_file", "write_file", "read_file", "todo", "run_command"}
+	calls := make([]tools.ToolCall, len(names))
+	for i, name := range names {
+		calls[i] = tools.ToolCall{ID: fmt.Sprintf("call_%d", i), Name: name}
+	}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	var overlapped []string // Non-read tools that ran alongside another call
+	exec := func(call tools.ToolCall) tools.ToolResult {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		if !runsInParallel(call.Name) && inFlight > 1 {
+			overlapped = append(overlapped, call.Name)
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return tools.ToolResult{Success: true, Output: call.ID}
+	}
+
+	var order []string
+	onStart := func(i int) { order = append(order, "start "+calls[i].ID) }
+	onResult := func(i int, result tools.ToolResult) {
+		order = append(order, "result "+result.Output)
+	}
+
+	results := runToolBatch(calls, exec, onStart, onResult)
+
+	for i, result := range results {
+		if result.Output != calls[i].ID {
+			t.Errorf("results[%d] = %q, want %q", i, result.Output, calls[i].ID)
+		}
+	}
+	for i, call := range calls {
+		if order[2*i] != "start "+call.ID || order[2*i+1] != "result "+call.ID {
+			t.Fatalf("callbacks out of order: %v", order)
+		}
+	}
+	if maxInFlight < 2 || maxInFlight > maxParallelTools {
+		t.Errorf("max concurrent calls = %d, want between 2 and %d", maxInFlight, maxParallelTools)
+	}
+	if len(overlapped) > 0 {
+		t.Errorf("write tools ran alongside other calls: %v", overlapped)
+	}
+}
+
+func TestAgent_Chat_InvalidToolArguments(t *testing.T) {
+	call := func(id, args string) llm.OpenAIToolCall {
+		tc := llm.OpenAIToolCall{ID: id, Type: "function"}
+		tc.Function.Name = "list_dir"
+		tc.Function.Arguments = args
+		return tc
+	}
+	provider := NewMockToolProvider(
+		ToolCallResponse("", call("call_1", `{"path": ".",}`), call("call_2", `{"path" "."}`)),
+		TextResponse("done"),
+	)
+	ag := New(provider, alwaysConfirm)
+
+	result, err := ag.Chat(context.Background(), "List it")
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(result.ToolCalls) != 2 {
+		t.Fatalf("got %d tool calls, want 2", len(result.ToolCalls))
+	}
+	if repaired := result.ToolCalls[0]; repaired.Error != "" || !strings.Contains(repaired.Result, "were repaired") {
+		t.Errorf("repairable arguments should run the tool and say they were repaired, got %+v", repaired)
+	}
+	if invalid := result.ToolCalls[1]; !strings.Contains(invalid.Error, "invalid arguments for list_dir") {
+		t.Errorf("invalid arguments should be reported to the model, got %+v", invalid)
+	}
+
+	// Every tool call gets a result in the history
+	var toolResults int
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			toolResults++
+		}
+	}
+	if toolResults != 2 {
+		t.Errorf("history has %d tool results, want 2", toolResults)
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nudgePrompt asks a model that stopped early to keep going
+const nudgePrompt = "Continue with the task. If it is already complete, reply with a one-sentence summary of what was done."
+
+// DefaultNudgePattern matches a last line announcing work the model then
+// did not do, e.g. "Now I'll continue with the tests."
+const DefaultNudgePattern = `(?i)\b(I'll|I will|let me|I'm going to|next,? I)\s+(now\s+)?(continue|proceed|start|begin|move on|update|implement|fix|add|create|write|run)\b`
+
+// danglingWords are words a finished sentence rarely ends with
+var danglingWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "to": true,
+	"of": true, "with": true, "for": true, "in": true, "that": true,
+}
+
+// looksIncomplete reports whether a final response appears cut short. It is
+// deliberately conservative: only an unclosed code block, a last line that
+// breaks off mid-sentence, or a match of pattern on the last line count.
+func looksIncomplete(text string, pattern *regexp.Regexp) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false
+	}
+
+	// An odd number of fences leaves a code block open
+	if strings.Count(text, "```")%2 == 1 {
+		return true
+	}
+
+	lastLine := text[strings.LastIndex(text, "\n")+1:]
+	if pattern != nil && pattern.MatchString(lastLine) {
+		return true
+	}
+
+	switch text[len(text)-1] {
+	case ',', ';', '(':
+		return true
+	}
+
+	words := strings.Fields(lastLine)
+	return len(words) >= 4 && danglingWords[strings.ToLower(words[len(words)-1])]
+}
+
+// shouldNudge reports whether the final response should get a continue
+// turn, given how many nudges this turn has already used
+func (a *Agent) shouldNudge(response string, nudges int) bool {
+	return nudges < a.maxNudges && looksIncomplete(response, a.nudgePattern)
+}
+
+// compileNudgePattern compiles a configured pattern, falling back to the
+// default when it is empty or invalid
+func compileNudgePattern(pattern string) *regexp.Regexp {
+	if pattern != "" {
+		if re, err := regexp.Compile(pattern); err == nil {
+			return re
+		}
+	}
+	return regexp.MustCompile(DefaultNudgePattern)
+}
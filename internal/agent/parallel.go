@@ -0,0 +1,71 @@
+package agent
+
+import "github.com/simonyos/Z-CODE/internal/tools"
+
+// maxParallelTools caps how many tool calls of a batch run at once
+const maxParallelTools = 4
+
+// runsInParallel reports whether a tool may run alongside others: the
+// read-only tools, except todo, whose calls in a batch build on each
+// other. Everything else may write files or run commands and runs alone.
+func runsInParallel(name string) bool {
+	return tools.ReadOnlyTools[name] && name != "todo"
+}
+
+// runToolBatch executes a batch of tool calls and returns the results in
+// call order. Consecutive read-only calls run concurrently, at most
+// maxParallelTools at a time; any other call waits for the calls before it
+// to finish and runs alone, so edits and commands never overlap with reads
+// of the same files. onStart and onResult, when set, are called in call
+// order from the calling goroutine, so each call's start is followed by
+// its result before the next call is reported.
+func runToolBatch(calls []tools.ToolCall, exec func(tools.ToolCall) tools.ToolResult, onStart func(int), onResult func(int, tools.ToolResult)) []tools.ToolResult {
+	results := make([]tools.ToolResult, len(calls))
+	start := func(i int) {
+		if onStart != nil {
+			onStart(i)
+		}
+	}
+	finish := func(i int) {
+		if onResult != nil {
+			onResult(i, results[i])
+		}
+	}
+
+	for i := 0; i < len(calls); {
+		// Extend the group over the following read-only calls
+		end := i + 1
+		if runsInParallel(calls[i].Name) {
+			for end < len(calls) && runsInParallel(calls[end].Name) {
+				end++
+			}
+		}
+
+		if end-i == 1 {
+			start(i)
+			results[i] = exec(calls[i])
+			finish(i)
+			i = end
+			continue
+		}
+
+		sem := make(chan struct{}, maxParallelTools)
+		done := make([]chan struct{}, end-i)
+		for k := range done {
+			done[k] = make(chan struct{})
+			go func(idx int, finished chan struct{}) {
+				defer close(finished)
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				results[idx] = exec(calls[idx])
+			}(i+k, done[k])
+		}
+		for k := range done {
+			start(i + k)
+			<-done[k]
+			finish(i + k)
+		}
+		i = end
+	}
+	return results
+}
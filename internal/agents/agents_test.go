@@ -0,0 +1,253 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/tools"
+)
+
+// scriptedResponse is one model response. When dropAfter is set, the
+// first stream of it fails after sending that text.
+type scriptedResponse struct {
+	content   string
+	toolCalls []llm.OpenAIToolCall
+	dropAfter string
+}
+
+// fakeProvider replays scripted responses through both the blocking and
+// the streaming API, and records the messages of each request
+type fakeProvider struct {
+	script   []scriptedResponse
+	next     int
+	dropped  bool
+	requests [][]llm.Message
+}
+
+func (f *fakeProvider) Generate(ctx context.Context, messages []llm.Message) (string, error) {
+	return "", errors.New("not used")
+}
+
+func (f *fakeProvider) GenerateStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeProvider) Validate(ctx context.Context) error {
+	return nil
+}
+
+// take returns the next scripted response
+func (f *fakeProvider) take(messages []llm.Message) scriptedResponse {
+	f.requests = append(f.requests, messages)
+	if f.next >= len(f.script) {
+		return scriptedResponse{content: "done"}
+	}
+	return f.script[f.next]
+}
+
+func (f *fakeProvider) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (*llm.ToolCallResponse, error) {
+	resp := f.take(messages)
+	f.next++
+	return &llm.ToolCallResponse{Content: resp.content, ToolCalls: resp.toolCalls, Done: len(resp.toolCalls) == 0}, nil
+}
+
+func (f *fakeProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	resp := f.take(messages)
+	drop := resp.dropAfter != "" && !f.dropped
+	if drop {
+		f.dropped = true
+	} else {
+		f.next++
+	}
+
+	ch := make(chan llm.ToolStreamChunk)
+	go func() {
+		defer close(ch)
+		if drop {
+			ch <- llm.ToolStreamChunk{Text: resp.dropAfter}
+			ch <- llm.ToolStreamChunk{Error: errors.New("connection reset")}
+			return
+		}
+		for _, word := range strings.SplitAfter(resp.content, " ") {
+			ch <- llm.ToolStreamChunk{Text: word}
+		}
+		ch <- llm.ToolStreamChunk{Text: resp.content, ToolCalls: resp.toolCalls, Done: true}
+	}()
+	return ch, nil
+}
+
+func toolCall(id, name, args string) llm.OpenAIToolCall {
+	tc := llm.OpenAIToolCall{ID: id, Type: "function"}
+	tc.Function.Name = name
+	tc.Function.Arguments = args
+	return tc
+}
+
+func todoCall(id, args string) llm.OpenAIToolCall {
+	return toolCall(id, "todo", args)
+}
+
+func TestExecute_StreamingMatchesBlocking(t *testing.T) {
+	def := &AgentDefinition{Name: "planner", SystemPrompt: "Plan the work.", Tools: []string{"todo"}, HandoffTo: "coder"}
+
+	tests := []struct {
+		name   string
+		script []scriptedResponse
+	}{
+		{
+			name:   "text only",
+			script: []scriptedResponse{{content: "Nothing to do here."}},
+		},
+		{
+			name: "tool calls",
+			script: []scriptedResponse{
+				{content: "Planning first.", toolCalls: []llm.OpenAIToolCall{
+					todoCall("call_1", `{"action": "add", "items": ["Write parser", "Add tests"]}`),
+					todoCall("call_2", `{"action": "complete", "id": 1}`),
+				}},
+				{toolCalls: []llm.OpenAIToolCall{todoCall("call_3", `{"action": "complete", "id": 7}`)}},
+				{content: "The plan is ready."},
+			},
+		},
+		{
+			name:   "handoff",
+			script: []scriptedResponse{{content: `Over to you. <handoff agent="coder" reason="needs code"><context key="file">main.go</context></handoff>`}},
+		},
+		{
+			name:   "dropped stream",
+			script: []scriptedResponse{{content: "The plan is ready to go.", dropAfter: "The plan is re"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecutor(&fakeProvider{script: tt.script}, func(tools.ConfirmRequest) bool { return true })
+			want, err := e.Execute(context.Background(), def, "Plan a parser")
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			e.SetProvider(&fakeProvider{script: tt.script})
+			var streamed strings.Builder
+			got, err := e.ExecuteWithEvents(context.Background(), def, "Plan a parser", func(event StreamEvent) {
+				if event.Type == "chunk" {
+					streamed.WriteString(event.Text)
+				}
+			})
+			if err != nil {
+				t.Fatalf("ExecuteWithEvents() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ExecuteWithEvents() = %+v, want %+v", got, want)
+			}
+			if !strings.HasSuffix(streamed.String(), want.Response) {
+				t.Errorf("streamed text = %q, want it to end with %q", streamed.String(), want.Response)
+			}
+
+			e.SetProvider(&fakeProvider{script: tt.script})
+			var final string
+			var handoff *HandoffInstruction
+			var executions []ToolExecution
+			for event := range e.ExecuteStream(context.Background(), def, "Plan a parser") {
+				switch event.Type {
+				case "tool_start":
+					executions = append(executions, ToolExecution{ID: event.ToolID, Name: event.ToolName, Args: event.ToolArgs})
+				case "tool_result":
+					executions[len(executions)-1].Result = event.ToolResult
+				case "handoff":
+					handoff = event.Handoff
+				case "done":
+					final = event.FinalResponse
+				case "error":
+					t.Fatalf("ExecuteStream() error = %v", event.Error)
+				}
+			}
+			if final != want.Response {
+				t.Errorf("ExecuteStream() response = %q, want %q", final, want.Response)
+			}
+			if !reflect.DeepEqual(handoff, want.Handoff) {
+				t.Errorf("ExecuteStream() handoff = %+v, want %+v", handoff, want.Handoff)
+			}
+			if len(executions) != len(want.ToolCalls) {
+				t.Fatalf("ExecuteStream() ran %d tools, want %d", len(executions), len(want.ToolCalls))
+			}
+			for i, exec := range executions {
+				w := want.ToolCalls[i]
+				if exec.ID != w.ID || exec.Name != w.Name || exec.Args != w.Args || exec.Result != w.Result {
+					t.Errorf("ExecuteStream() tool %d = %+v, want %+v", i, exec, w)
+				}
+			}
+		})
+	}
+}
+
+func TestExecute_TodoReminder(t *testing.T) {
+	provider := &fakeProvider{script: []scriptedResponse{
+		{toolCalls: []llm.OpenAIToolCall{todoCall("call_1", `{"action": "add", "items": ["Write parser"]}`)}},
+		{content: "Done."},
+	}}
+	e := NewExecutor(provider, func(tools.ConfirmRequest) bool { return true })
+
+	if _, err := e.Execute(context.Background(), &AgentDefinition{Name: "planner"}, "Plan a parser"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(provider.requests))
+	}
+	last := provider.requests[1][len(provider.requests[1])-1]
+	if !strings.Contains(last.Content, "<todo>\nRemaining tasks:\n1. Write parser") {
+		t.Errorf("last message = %q, want the open tasks appended", last.Content)
+	}
+
+	// A new execution starts with an empty list
+	provider.script, provider.next, provider.requests = []scriptedResponse{{content: "Hi."}}, 0, nil
+	if _, err := e.Execute(context.Background(), &AgentDefinition{Name: "planner"}, "Hello"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if strings.Contains(provider.requests[0][1].Content, "<todo>") {
+		t.Errorf("the todo list leaked into the next execution: %q", provider.requests[0][1].Content)
+	}
+}
+
+func TestExecute_ReadLimit(t *testing.T) {
+	old := config.ConfigDir()
+	config.UseDir(t.TempDir())
+	t.Cleanup(func() { config.UseDir(old) })
+	t.Chdir(t.TempDir())
+	if err := config.Set("max_reads_per_turn", "2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	script := []scriptedResponse{
+		{toolCalls: []llm.OpenAIToolCall{
+			toolCall("call_1", "list_dir", `{"path": "."}`),
+			toolCall("call_2", "list_dir", `{"path": "."}`),
+			toolCall("call_3", "list_dir", `{"path": "."}`),
+		}},
+		{content: "Done."},
+	}
+	def := &AgentDefinition{Name: "explorer", Tools: []string{"list_dir"}}
+	e := NewExecutor(&fakeProvider{script: script}, nil)
+
+	for i := range 2 {
+		result, err := e.Execute(context.Background(), def, "Look around")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		var limited int
+		for _, exec := range result.ToolCalls {
+			if strings.Contains(exec.Error, "read limit reached") {
+				limited++
+			}
+		}
+		if limited != 1 {
+			t.Errorf("execution %d: %d of 3 reads hit the limit, want 1", i+1, limited)
+		}
+		e.SetProvider(&fakeProvider{script: script})
+	}
+}
@@ -2,11 +2,12 @@ package agents
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 
+	"github.com/simonyos/Z-CODE/internal/config"
 	"github.com/simonyos/Z-CODE/internal/llm"
 	"github.com/simonyos/Z-CODE/internal/tools"
 )
@@ -29,9 +30,16 @@ func NewExecutor(provider llm.Provider, confirmFn tools.ConfirmFunc) *Executor {
 		tools.NewListDirTool(),
 		tools.NewWriteFileTool(confirmFn),
 		tools.NewEditTool(confirmFn),
+		tools.NewLineEditTool(confirmFn),
+		tools.NewMoveFileTool(confirmFn),
+		tools.NewDeleteFileTool(confirmFn),
+		tools.NewUndoTool(confirmFn),
 		tools.NewBashTool(confirmFn),
+		tools.NewSandboxTool(confirmFn),
 		tools.NewGlobTool(),
 		tools.NewGrepTool(),
+		tools.NewWebFetchTool(),
+		tools.NewEnvInfoTool(),
 	}
 
 	for _, t := range toolList {
@@ -61,14 +69,34 @@ type ToolExecution struct {
 	Error  string
 }
 
-// Execute runs a custom agent with the given prompt
+// SetProvider switches the provider used by later executions
+func (e *Executor) SetProvider(provider llm.Provider) {
+	e.provider = provider
+}
+
+// Execute runs a custom agent with the given prompt, waiting for each
+// complete model response
 func (e *Executor) Execute(ctx context.Context, def *AgentDefinition, userPrompt string) (*ExecuteResult, error) {
-	toolProvider, ok := e.provider.(llm.ToolProvider)
-	if !ok {
-		return nil, fmt.Errorf("provider does not support native tool calling")
+	return e.ExecuteWithEvents(ctx, def, userPrompt, nil)
+}
+
+// ExecuteWithEvents runs a custom agent like Execute. When onEvent is not
+// nil the model responses are streamed and progress is reported to it:
+// "chunk" events for text, and "tool_batch_start", "tool_start",
+// "tool_result" and "tool_batch_end" events around tool calls. The
+// terminal "done", "handoff" and "error" events are not reported; they are
+// the return values. Either way the result is the same.
+func (e *Executor) ExecuteWithEvents(ctx context.Context, def *AgentDefinition, userPrompt string, onEvent func(StreamEvent)) (*ExecuteResult, error) {
+	emit := func(event StreamEvent) {
+		if onEvent != nil {
+			onEvent(event)
+		}
 	}
 
-	registry := e.buildRegistry(def)
+	toolProvider := tools.AsToolProvider(e.provider)
+	todos := tools.NewTodoList()
+	reads := tools.NewReadLimit(config.GetMaxReadsPerTurn()) // An execution is one turn
+	registry := e.buildRegistry(def, todos)
 	systemPrompt := e.buildSystemPrompt(def, registry)
 	openAITools := registry.GetOpenAIToolDefinitions()
 
@@ -82,50 +110,95 @@ func (e *Executor) Execute(ctx context.Context, def *AgentDefinition, userPrompt
 	}
 
 	for {
-		resp, err := toolProvider.GenerateWithTools(ctx, messages, openAITools)
-		if err != nil {
-			return nil, err
+		request := todos.WithReminder(messages)
+		var fullContent string
+		var toolCalls []llm.OpenAIToolCall
+		if onEvent == nil {
+			resp, err := toolProvider.GenerateWithTools(ctx, request, openAITools)
+			if err != nil {
+				return nil, err
+			}
+			fullContent, toolCalls = resp.Content, resp.ToolCalls
+		} else {
+			var err error
+			fullContent, toolCalls, err = llm.StreamWithResume(ctx, toolProvider, request, openAITools, func(text string) {
+				onEvent(StreamEvent{Type: "chunk", Text: text})
+			})
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		// Check for handoff instruction
-		if handoff := ParseHandoff(resp.Content); handoff != nil {
+		if handoff := ParseHandoff(fullContent); handoff != nil {
 			result.Handoff = handoff
-			result.Response = resp.Content
+			result.Response = fullContent
 			return result, nil
 		}
 
-		// Check for tool calls
-		if len(resp.ToolCalls) > 0 {
-			// Execute tool calls
-			execResults := e.executeNativeToolCalls(ctx, registry, resp.ToolCalls)
-			result.ToolCalls = append(result.ToolCalls, execResults...)
+		// No tool calls - final response
+		if len(toolCalls) == 0 {
+			result.Response = fullContent
+			return result, nil
+		}
 
-			// Add assistant message with tool calls
-			messages = append(messages, llm.Message{
-				Role:      "assistant",
-				Content:   resp.Content,
-				ToolCalls: resp.ToolCalls,
+		if len(toolCalls) > 1 {
+			emit(StreamEvent{Type: "tool_batch_start", BatchSize: len(toolCalls)})
+		}
+
+		var execResults []ToolExecution
+		for _, tc := range toolCalls {
+			emit(StreamEvent{
+				Type:     "tool_start",
+				ToolID:   tc.ID,
+				ToolName: tc.Function.Name,
+				ToolArgs: tc.Function.Arguments,
 			})
 
-			// Add tool result messages with name
-			for _, exec := range execResults {
-				resultContent := exec.Result
-				if exec.Error != "" {
-					resultContent = "Error: " + exec.Error
-				}
-				messages = append(messages, llm.Message{
-					Role:       "tool",
-					Content:    resultContent,
-					Name:       exec.Name,
-					ToolCallID: exec.ID,
-				})
-			}
-			continue
+			toolResult := executeToolCall(ctx, registry, reads, tc)
+
+			emit(StreamEvent{
+				Type:       "tool_result",
+				ToolID:     tc.ID,
+				ToolName:   tc.Function.Name,
+				ToolResult: toolResult.Output,
+				ToolError:  !toolResult.Success,
+			})
+
+			execResults = append(execResults, ToolExecution{
+				ID:     tc.ID,
+				Name:   tc.Function.Name,
+				Args:   tc.Function.Arguments,
+				Result: toolResult.Output,
+				Error:  toolResult.Error,
+			})
 		}
 
-		// No tool calls - final response
-		result.Response = resp.Content
-		return result, nil
+		if len(toolCalls) > 1 {
+			emit(StreamEvent{Type: "tool_batch_end", BatchSize: len(toolCalls)})
+		}
+		result.ToolCalls = append(result.ToolCalls, execResults...)
+
+		// Add assistant message with tool calls
+		messages = append(messages, llm.Message{
+			Role:      "assistant",
+			Content:   fullContent,
+			ToolCalls: toolCalls,
+		})
+
+		// Add tool result messages with name
+		for _, exec := range execResults {
+			resultContent := exec.Result
+			if exec.Error != "" {
+				resultContent = "Error: " + exec.Error
+			}
+			messages = append(messages, llm.Message{
+				Role:       "tool",
+				Content:    resultContent,
+				Name:       exec.Name,
+				ToolCallID: exec.ID,
+			})
+		}
 	}
 }
 
@@ -136,122 +209,19 @@ func (e *Executor) ExecuteStream(ctx context.Context, def *AgentDefinition, user
 	go func() {
 		defer close(events)
 
-		toolProvider, ok := e.provider.(llm.ToolProvider)
-		if !ok {
-			events <- StreamEvent{Type: "error", Error: fmt.Errorf("provider does not support native tool calling")}
-			return
-		}
-
-		registry := e.buildRegistry(def)
-		systemPrompt := e.buildSystemPrompt(def, registry)
-		openAITools := registry.GetOpenAIToolDefinitions()
-
-		messages := []llm.Message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		}
-
 		events <- StreamEvent{Type: "start"}
 
-		for {
-			chunks, err := toolProvider.GenerateStreamWithTools(ctx, messages, openAITools)
-			if err != nil {
-				events <- StreamEvent{Type: "error", Error: err}
-				return
-			}
-
-			var fullContent string
-			var toolCalls []llm.OpenAIToolCall
-			for chunk := range chunks {
-				if chunk.Error != nil {
-					events <- StreamEvent{Type: "error", Error: chunk.Error}
-					return
-				}
-				if chunk.Done {
-					fullContent = chunk.Text
-					toolCalls = chunk.ToolCalls
-				} else {
-					events <- StreamEvent{Type: "chunk", Text: chunk.Text}
-				}
-			}
-
-			// Check for handoff
-			if handoff := ParseHandoff(fullContent); handoff != nil {
-				events <- StreamEvent{Type: "handoff", Handoff: handoff}
-				events <- StreamEvent{Type: "done", FinalResponse: fullContent}
-				return
-			}
-
-			// Check for tool calls
-			if len(toolCalls) > 0 {
-				if len(toolCalls) > 1 {
-					events <- StreamEvent{Type: "tool_batch_start", BatchSize: len(toolCalls)}
-				}
-
-				var execResults []ToolExecution
-				for _, tc := range toolCalls {
-					events <- StreamEvent{
-						Type:     "tool_start",
-						ToolID:   tc.ID,
-						ToolName: tc.Function.Name,
-						ToolArgs: tc.Function.Arguments,
-					}
-
-					toolResult := registry.Execute(ctx, tools.ToolCall{
-						ID:        tc.ID,
-						Name:      tc.Function.Name,
-						Arguments: parseToolArgs(tc.Function.Arguments),
-					})
-
-					events <- StreamEvent{
-						Type:       "tool_result",
-						ToolID:     tc.ID,
-						ToolName:   tc.Function.Name,
-						ToolResult: toolResult.Output,
-						ToolError:  !toolResult.Success,
-					}
-
-					execResults = append(execResults, ToolExecution{
-						ID:     tc.ID,
-						Name:   tc.Function.Name,
-						Args:   tc.Function.Arguments,
-						Result: toolResult.Output,
-						Error:  toolResult.Error,
-					})
-				}
-
-				if len(toolCalls) > 1 {
-					events <- StreamEvent{Type: "tool_batch_end", BatchSize: len(toolCalls)}
-				}
-
-				// Add assistant message with tool calls
-				messages = append(messages, llm.Message{
-					Role:      "assistant",
-					Content:   fullContent,
-					ToolCalls: toolCalls,
-				})
-
-				// Add tool result messages with name
-				for _, exec := range execResults {
-					resultContent := exec.Result
-					if exec.Error != "" {
-						resultContent = "Error: " + exec.Error
-					}
-					messages = append(messages, llm.Message{
-						Role:       "tool",
-						Content:    resultContent,
-						Name:       exec.Name,
-						ToolCallID: exec.ID,
-					})
-				}
-				continue
-			}
-
-			// No tool calls - final response
-			events <- StreamEvent{Type: "done", FinalResponse: fullContent}
+		result, err := e.ExecuteWithEvents(ctx, def, userPrompt, func(event StreamEvent) {
+			events <- event
+		})
+		if err != nil {
+			events <- StreamEvent{Type: "error", Error: err}
 			return
 		}
-
+		if result.Handoff != nil {
+			events <- StreamEvent{Type: "handoff", Handoff: result.Handoff}
+		}
+		events <- StreamEvent{Type: "done", FinalResponse: result.Response}
 	}()
 
 	return events
@@ -272,20 +242,27 @@ type StreamEvent struct {
 	Error         error
 }
 
-// buildRegistry creates a tool registry for the agent
-func (e *Executor) buildRegistry(def *AgentDefinition) *tools.Registry {
+// buildRegistry creates a tool registry for the agent. The tools config
+// applies on top of the agent's own tool list. The todo tool works on the
+// given list, which belongs to a single execution.
+func (e *Executor) buildRegistry(def *AgentDefinition, todos *tools.TodoList) *tools.Registry {
 	registry := tools.NewRegistry()
+	registry.SetPolicy(tools.ConfigPolicy())
+	todoTool := tools.NewTodoTool(todos)
 
 	if len(def.Tools) == 0 {
 		// No restrictions - register all tools
 		for _, tool := range e.allTools {
 			registry.Register(tool)
 		}
+		registry.Register(todoTool)
 	} else {
 		// Register only allowed tools
 		for _, name := range def.Tools {
 			if tool, ok := e.allTools[name]; ok {
 				registry.Register(tool)
+			} else if name == todoTool.Definition().Name {
+				registry.Register(todoTool)
 			}
 		}
 	}
@@ -294,7 +271,8 @@ func (e *Executor) buildRegistry(def *AgentDefinition) *tools.Registry {
 }
 
 // buildSystemPrompt creates the system prompt for the agent
-// Note: Tool definitions are passed separately via the native tool calling API.
+// Note: Tool definitions are passed separately via the native tool calling API,
+// or added to the system prompt by tools.PromptToolProvider.
 func (e *Executor) buildSystemPrompt(def *AgentDefinition, registry *tools.Registry) string {
 	var sb strings.Builder
 
@@ -320,37 +298,20 @@ func (e *Executor) buildSystemPrompt(def *AgentDefinition, registry *tools.Regis
 	return sb.String()
 }
 
-// executeNativeToolCalls executes multiple OpenAI-format tool calls
-func (e *Executor) executeNativeToolCalls(ctx context.Context, registry *tools.Registry, toolCalls []llm.OpenAIToolCall) []ToolExecution {
-	results := make([]ToolExecution, len(toolCalls))
-
-	for i, tc := range toolCalls {
-		toolResult := registry.Execute(ctx, tools.ToolCall{
-			ID:        tc.ID,
-			Name:      tc.Function.Name,
-			Arguments: parseToolArgs(tc.Function.Arguments),
-		})
-
-		results[i] = ToolExecution{
-			ID:     tc.ID,
-			Name:   tc.Function.Name,
-			Args:   tc.Function.Arguments,
-			Result: toolResult.Output,
-			Error:  toolResult.Error,
+// executeToolCall runs one tool call. Arguments that cannot be parsed,
+// even after repair, are reported back to the model instead of running
+// the tool without them, and reads past the read limit are refused.
+func executeToolCall(ctx context.Context, registry *tools.Registry, reads *tools.ReadLimit, tc llm.OpenAIToolCall) tools.ToolResult {
+	args, repaired, err := tools.ParseArguments(tc.Function.Name, tc.Function.Arguments)
+	if err != nil {
+		slog.Debug("failed to parse tool arguments", "tool", tc.Function.Name, "error", err, "input", tc.Function.Arguments)
+		return tools.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("invalid arguments for %s: %v. The tool was not run; call it again with valid JSON arguments", tc.Function.Name, err),
 		}
 	}
-
-	return results
-}
-
-// parseToolArgs parses JSON arguments into a map
-func parseToolArgs(argsJSON string) map[string]any {
-	var args map[string]any
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		if os.Getenv("ZCODE_DEBUG") != "" {
-			fmt.Fprintf(os.Stderr, "[DEBUG parseToolArgs] failed to parse: %v, input: %q\n", err, argsJSON)
-		}
-		return make(map[string]any)
+	if err := reads.Take(tc.Function.Name); err != nil {
+		return tools.ToolResult{Success: false, Error: err.Error()}
 	}
-	return args
+	return registry.Execute(ctx, tools.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args, Repaired: repaired})
 }
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/simonyos/Z-CODE/internal/logging"
 )
 
 // Loader handles discovery and parsing of agent definitions from markdown files
@@ -59,7 +61,7 @@ func (l *Loader) LoadAll() ([]*AgentDefinition, error) {
 			agent, err := l.LoadFromFile(filePath)
 			if err != nil {
 				// Log but don't fail on individual file errors
-				fmt.Fprintf(os.Stderr, "Warning: failed to load agent from %s: %v\n", filePath, err)
+				logging.Warnf("failed to load agent from %s: %v", filePath, err)
 				continue
 			}
 
@@ -0,0 +1,452 @@
+// Package bundle exports and imports shareable Z-CODE setups: custom
+// agents, workflows, skills and non-secret settings packed into a single
+// tar.gz archive.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/agents"
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/skills"
+	"github.com/simonyos/Z-CODE/internal/workflows"
+)
+
+// FormatVersion is the bundle format written by Export.
+// Import refuses bundles with a newer version.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	settingsName = "settings.json"
+
+	// maxEntrySize bounds a single archive entry to guard against bombs
+	maxEntrySize = 1 << 20
+)
+
+// ErrUnsupportedVersion is returned when a bundle is newer than this build
+var ErrUnsupportedVersion = errors.New("unsupported bundle version")
+
+// Manifest describes the contents of a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Agents    []string  `json:"agents,omitempty"`
+	Workflows []string  `json:"workflows,omitempty"`
+	Skills    []string  `json:"skills,omitempty"`
+	Settings  []string  `json:"settings,omitempty"`
+}
+
+// ImportOptions controls how conflicts are handled on import
+type ImportOptions struct {
+	// Force overwrites existing definitions and settings instead of skipping them
+	Force bool
+}
+
+// ImportResult summarizes what an import changed
+type ImportResult struct {
+	Installed []string // Files and settings written
+	Skipped   []string // Entries left alone because they already exist
+	Warnings  []string // Conflicts and ignored entries
+}
+
+// kind describes one type of definition stored in a bundle
+type kind struct {
+	dir      string
+	exts     []string
+	paths    func() []string
+	validate func(content []byte) error
+}
+
+var kinds = []kind{
+	{
+		dir:   "agents",
+		exts:  []string{".md"},
+		paths: config.GetAgentPaths,
+		validate: func(content []byte) error {
+			_, err := agents.ParseAgentMarkdown(string(content))
+			return err
+		},
+	},
+	{
+		dir:   "workflows",
+		exts:  []string{".yaml", ".yml"},
+		paths: config.GetWorkflowPaths,
+		validate: func(content []byte) error {
+			_, err := workflows.ParseWorkflowYAML(content)
+			return err
+		},
+	},
+	{
+		dir:   "skills",
+		exts:  []string{".md"},
+		paths: config.GetSkillPaths,
+		validate: func(content []byte) error {
+			_, err := skills.ParseSkillMarkdown(string(content))
+			return err
+		},
+	},
+}
+
+// Export writes all agents, workflows and skills from the project-local and
+// global directories, plus non-secret settings, to a tar.gz archive at path.
+// API keys and other credentials are never included.
+func Export(path string) (*Manifest, error) {
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+	files := make(map[string][]byte)
+
+	for _, k := range kinds {
+		collected := collectFiles(k)
+		names := make([]string, 0, len(collected))
+		for name, content := range collected {
+			files[k.dir+"/"+name] = content
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		switch k.dir {
+		case "agents":
+			manifest.Agents = names
+		case "workflows":
+			manifest.Workflows = names
+		case "skills":
+			manifest.Skills = names
+		}
+	}
+
+	settings, err := exportSettings()
+	if err != nil {
+		return nil, err
+	}
+	for key := range settings {
+		manifest.Settings = append(manifest.Settings, key)
+	}
+	sort.Strings(manifest.Settings)
+
+	settingsJSON, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode settings: %w", err)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	entries := []string{manifestName, settingsName}
+	files[manifestName] = manifestJSON
+	files[settingsName] = settingsJSON
+	var defs []string
+	for name := range files {
+		if name != manifestName && name != settingsName {
+			defs = append(defs, name)
+		}
+	}
+	sort.Strings(defs)
+	entries = append(entries, defs...)
+
+	for _, name := range entries {
+		content := files[name]
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Import validates a bundle and installs its definitions into the global
+// config directory. Every definition is parsed before anything is written,
+// so an invalid bundle leaves the existing setup untouched.
+func Import(path string, opts ImportOptions) (*ImportResult, error) {
+	files, err := readArchive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestJSON, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("not a zcode bundle: missing %s", manifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d (this build supports up to %d)", ErrUnsupportedVersion, manifest.Version, FormatVersion)
+	}
+
+	result := &ImportResult{}
+
+	// Validate everything up front
+	type install struct {
+		entry   string
+		target  string
+		content []byte
+	}
+	var installs []install
+
+	for name, content := range files {
+		if name == manifestName || name == settingsName {
+			continue
+		}
+
+		k, base, ok := classify(name)
+		if !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ignored unexpected entry %q", name))
+			continue
+		}
+		if err := k.validate(content); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		installs = append(installs, install{
+			entry:   name,
+			target:  filepath.Join(config.ConfigDir(), k.dir, base),
+			content: content,
+		})
+	}
+	sort.Slice(installs, func(i, j int) bool { return installs[i].entry < installs[j].entry })
+
+	settings := make(map[string]string)
+	if settingsJSON, ok := files[settingsName]; ok {
+		var raw map[string]any
+		if err := json.Unmarshal(settingsJSON, &raw); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+		settings = flatSettings(raw)
+	}
+
+	// Install definitions
+	for _, in := range installs {
+		if existing, err := os.ReadFile(in.target); err == nil {
+			if bytes.Equal(existing, in.content) {
+				result.Skipped = append(result.Skipped, in.entry+" (unchanged)")
+				continue
+			}
+			if !opts.Force {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s conflicts with existing %s; kept existing (use --force to overwrite)", in.entry, in.target))
+				result.Skipped = append(result.Skipped, in.entry)
+				continue
+			}
+			result.Warnings = append(result.Warnings, fmt.Sprintf("overwrote %s", in.target))
+		}
+
+		if err := os.MkdirAll(filepath.Dir(in.target), 0755); err != nil {
+			return result, fmt.Errorf("failed to create %s: %w", filepath.Dir(in.target), err)
+		}
+		if err := os.WriteFile(in.target, in.content, 0644); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", in.target, err)
+		}
+		result.Installed = append(result.Installed, in.entry)
+	}
+
+	// Apply settings without clobbering values the user already has. Ones
+	// that choose where requests go or relax a safeguard need --force, so
+	// a shared bundle cannot quietly send keys elsewhere or unblock
+	// commands.
+	current := config.ListKeys()
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if config.IsCredential(key) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ignored secret setting %q", key))
+			continue
+		}
+		value := settings[key]
+		if config.IsProtected(key) && !opts.Force {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("setting %s changes where requests go or a safety check; not applied (review it and use --force to apply)", key))
+			result.Skipped = append(result.Skipped, "setting "+key)
+			continue
+		}
+		if existing, ok := current[key]; ok {
+			if existing == value {
+				result.Skipped = append(result.Skipped, "setting "+key+" (unchanged)")
+				continue
+			}
+			if !opts.Force {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("setting %s is already %q; kept existing (use --force to overwrite)", key, existing))
+				result.Skipped = append(result.Skipped, "setting "+key)
+				continue
+			}
+		}
+		if err := config.Set(key, value); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ignored setting %s: %v", key, err))
+			continue
+		}
+		result.Installed = append(result.Installed, "setting "+key)
+	}
+
+	return result, nil
+}
+
+// collectFiles reads all definition files for a kind. Later search paths
+// win on name clashes, matching how the registries resolve duplicates.
+func collectFiles(k kind) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, dir := range k.paths() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !hasExt(entry.Name(), k.exts) {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			files[entry.Name()] = content
+		}
+	}
+	return files
+}
+
+// exportSettings returns the saved config as the keys and values Set
+// accepts, with credentials removed. The file is read directly so
+// in-memory defaults are not exported.
+func exportSettings() (map[string]string, error) {
+	data, err := os.ReadFile(config.ConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+	settings := flatSettings(raw)
+	for key := range settings {
+		if config.IsCredential(key) {
+			delete(settings, key)
+		}
+	}
+	return settings, nil
+}
+
+// flatSettings turns settings as stored in the config file, or in a
+// bundle written by an older build, into the keys and values Set accepts:
+// lists are joined with commas and maps become dotted keys. The file
+// format version is not a setting and is dropped.
+func flatSettings(raw map[string]any) map[string]string {
+	flat := make(map[string]string)
+	config.FlattenSettings("", raw, flat)
+	settings := make(map[string]string, len(flat))
+	for key, value := range flat {
+		if key == "version" {
+			continue
+		}
+		// The file stores secret patterns under secret_patterns
+		if name, ok := strings.CutPrefix(key, "secret_patterns."); ok {
+			key = "secret_pattern." + name
+		}
+		settings[key] = value
+	}
+	return settings
+}
+
+// readArchive loads all regular files from a bundle into memory
+func readArchive(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a zcode bundle: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("bundle entry %s is too large", hdr.Name)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		files[hdr.Name] = content
+	}
+	return files, nil
+}
+
+// classify maps an archive entry like "agents/reviewer.md" to its kind.
+// Anything nested, absolute or with an unexpected extension is rejected.
+func classify(name string) (kind, string, bool) {
+	dir, base := path.Split(path.Clean(name))
+	dir = strings.TrimSuffix(dir, "/")
+	if base == "" || base == "." || base == ".." || strings.ContainsAny(base, `\:`) {
+		return kind{}, "", false
+	}
+	for _, k := range kinds {
+		if dir == k.dir && hasExt(base, k.exts) {
+			return k, base, true
+		}
+	}
+	return kind{}, "", false
+}
+
+// hasExt reports whether name ends in one of exts
+func hasExt(name string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+const reviewerAgent = "---\nname: reviewer\ndescription: Reviews code\n---\nReview the diff.\n"
+
+// useConfig points the config at an empty directory and moves into an
+// empty working directory, so no project definitions are picked up
+func useConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	old := config.ConfigDir()
+	config.UseDir(filepath.Join(dir, "config"))
+	t.Cleanup(func() { config.UseDir(old) })
+	work := filepath.Join(dir, "work")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatalf("failed to create working dir: %v", err)
+	}
+	t.Chdir(work)
+}
+
+// writeBundle writes a bundle with the given entries
+func writeBundle(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExportImport(t *testing.T) {
+	useConfig(t)
+	for _, kv := range [][2]string{
+		{"openai", "sk-proj-1234567890abcdef"},
+		{"model", "gpt-4o"},
+		{"prompt_token_budget", "1000000"},
+		{"command_deny", "git push,rm"},
+		{"tools.disabled", "delete_file"},
+		{"command_env.GOFLAGS", "-mod=mod"},
+		{"command_env.DEPLOY_TOKEN", "tok-1234567890"},
+		{"secret_pattern.internal", "itk_[0-9]+"},
+	} {
+		if err := config.Set(kv[0], kv[1]); err != nil {
+			t.Fatalf("Set(%s) error = %v", kv[0], err)
+		}
+	}
+	agentsDir := filepath.Join(config.ConfigDir(), "agents")
+	if err := os.MkdirAll(agentsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(agentsDir, "reviewer.md"), []byte(reviewerAgent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "preset.tar.gz")
+	manifest, err := Export(path)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !slices.Equal(manifest.Agents, []string{"reviewer.md"}) {
+		t.Errorf("manifest.Agents = %v, want [reviewer.md]", manifest.Agents)
+	}
+	wantSettings := []string{"command_deny", "command_env.GOFLAGS", "default_model", "prompt_token_budget", "secret_pattern.internal", "tools.disabled"}
+	if !slices.Equal(manifest.Settings, wantSettings) {
+		t.Errorf("manifest.Settings = %v, want %v", manifest.Settings, wantSettings)
+	}
+
+	// Plain settings and definitions install; protected ones wait for --force
+	useConfig(t)
+	result, err := Import(path, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	wantInstalled := []string{"agents/reviewer.md", "setting default_model", "setting prompt_token_budget"}
+	if !slices.Equal(result.Installed, wantInstalled) {
+		t.Errorf("Installed = %v, want %v", result.Installed, wantInstalled)
+	}
+	if len(result.Skipped) != 4 {
+		t.Errorf("Skipped = %v, want the four protected settings", result.Skipped)
+	}
+	if got := config.GetPromptTokenBudget(); got != 1000000 {
+		t.Errorf("prompt_token_budget = %d, want 1000000", got)
+	}
+	if deny := config.GetCommandDeny(); len(deny) != 0 {
+		t.Errorf("command_deny = %v, want it left alone without --force", deny)
+	}
+
+	// With --force every list and map keeps its items
+	result, err = Import(path, ImportOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Import(force) error = %v", err)
+	}
+	for _, w := range result.Warnings {
+		if strings.Contains(w, "ignored setting") {
+			t.Errorf("unexpected warning %q", w)
+		}
+	}
+	if deny := config.GetCommandDeny(); !slices.Equal(deny, []string{"git push", "rm"}) {
+		t.Errorf("command_deny = %q, want [git push rm]", deny)
+	}
+	if disabled := config.GetDisabledTools(); !slices.Equal(disabled, []string{"delete_file"}) {
+		t.Errorf("tools.disabled = %v, want [delete_file]", disabled)
+	}
+	if env := config.GetCommandEnv(); len(env) != 1 || env["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("command_env = %v, want only GOFLAGS", env)
+	}
+	if patterns := config.GetSecretPatterns(); patterns["internal"] != "itk_[0-9]+" {
+		t.Errorf("secret patterns = %v, want internal", patterns)
+	}
+	if config.GetOpenAIKey() != os.Getenv("OPENAI_API_KEY") {
+		t.Error("the API key should not be exported")
+	}
+}
+
+func TestImportNestedSettings(t *testing.T) {
+	useConfig(t)
+	// Older builds wrote settings as they appear in the config file
+	path := writeBundle(t, map[string]string{
+		manifestName: `{"version": 1}`,
+		settingsName: `{"version": 1, "command_deny": ["git push", "rm"], "tools": {"readonly": true}, "secret_redaction": "aggressive", "anthropic_api_key": "sk-ant-x"}`,
+	})
+
+	result, err := Import(path, ImportOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if deny := config.GetCommandDeny(); !slices.Equal(deny, []string{"git push", "rm"}) {
+		t.Errorf("command_deny = %q, want [git push rm]", deny)
+	}
+	if !config.GetToolsReadOnly() {
+		t.Error("tools.readonly should be set")
+	}
+	if got := config.GetSecretRedaction(); got != config.RedactAggressive {
+		t.Errorf("secret_redaction = %q, want aggressive", got)
+	}
+	if got := config.Get().AnthropicKey; got != "" {
+		t.Errorf("AnthropicKey = %q, want the secret ignored", got)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "anthropic_api_key") {
+		t.Errorf("Warnings = %v, want only the ignored API key", result.Warnings)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	useConfig(t)
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"missing manifest", map[string]string{"agents/reviewer.md": reviewerAgent}, "missing manifest"},
+		{"newer version", map[string]string{manifestName: `{"version": 99}`}, "unsupported bundle version"},
+		{"invalid agent", map[string]string{
+			manifestName:         `{"version": 1}`,
+			"agents/reviewer.md": reviewerAgent,
+			"agents/broken.md":   "no frontmatter",
+		}, "invalid agents/broken.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(writeBundle(t, tt.files), ImportOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Import() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.name == "newer version" && !errors.Is(err, ErrUnsupportedVersion) {
+				t.Errorf("Import() error = %v, want ErrUnsupportedVersion", err)
+			}
+			// Nothing is written when any definition is invalid
+			if _, err := os.Stat(filepath.Join(config.ConfigDir(), "agents", "reviewer.md")); !os.IsNotExist(err) {
+				t.Errorf("reviewer.md was installed from an invalid bundle")
+			}
+		})
+	}
+}
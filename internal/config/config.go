@@ -5,10 +5,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/logging"
 )
 
 // Config holds all application configuration
 type Config struct {
+	// Version is the file format, see CurrentVersion
+	Version int `json:"version,omitempty"`
+
 	// API Keys
 	OpenAIKey      string `json:"openai_api_key,omitempty"`
 	AnthropicKey   string `json:"anthropic_api_key,omitempty"`
@@ -19,12 +28,81 @@ type Config struct {
 	// Defaults
 	DefaultProvider string `json:"default_provider,omitempty"`
 	DefaultModel    string `json:"default_model,omitempty"`
+
+	// Tools
+	WebFetchAllowPrivate bool              `json:"web_fetch_allow_private,omitempty"` // Allow web_fetch to reach private/loopback hosts
+	CommandTimeout       int               `json:"command_timeout,omitempty"`         // run_command timeout in seconds (0 = default)
+	MaxReadsPerTurn      int               `json:"max_reads_per_turn,omitempty"`      // read_file/list_dir calls allowed per turn (0 = default)
+	CommandRoot          string            `json:"command_root,omitempty"`            // Confine run_command to this directory ("" = off, "." = working directory)
+	CommandAllow         []string          `json:"command_allow,omitempty"`           // run_command may only run commands matching these patterns ("git *", "go test")
+	CommandDeny          []string          `json:"command_deny,omitempty"`            // run_command refuses commands matching these patterns
+	CommandEnv           map[string]string `json:"command_env,omitempty"`             // Environment variables set for every run_command
+	Tools                ToolsConfig       `json:"tools,omitzero"`                    // Which tools the agent may use
+
+	// Secret redaction in tool output sent to the model
+	SecretRedaction string            `json:"secret_redaction,omitempty"` // off, high or aggressive ("" = high)
+	SecretPatterns  map[string]string `json:"secret_patterns,omitempty"`  // Extra patterns by name
+
+	// Agent
+	AutoContinue        int    `json:"auto_continue,omitempty"`         // Continue turns when a response looks cut short (0 = off)
+	AutoContinuePattern string `json:"auto_continue_pattern,omitempty"` // Regex for a last line that should trigger a continue
+
+	// Providers
+	RequestTimeout int `json:"request_timeout,omitempty"` // LLM request timeout in seconds (0 = provider default)
+
+	// Prompt
+	PromptGitContext  bool `json:"prompt_git_context,omitempty"`  // Include branch and recent commits in the system prompt
+	PromptTokenBudget int  `json:"prompt_token_budget,omitempty"` // Drop low-priority prompt sections above this many tokens (0 = no limit)
+
+	// TUI
+	Mouse bool `json:"mouse,omitempty"` // Capture the mouse for wheel scrolling
+
+	// unknown holds keys from a newer build, saved back unchanged
+	unknown map[string]json.RawMessage
+}
+
+// ToolsConfig restricts the tools registered for the agent
+type ToolsConfig struct {
+	ReadOnly bool     `json:"readonly,omitempty"` // Register only tools that do not change files or run commands
+	Disabled []string `json:"disabled,omitempty"` // Tools never registered
 }
 
+// DefaultCommandTimeout is used when command_timeout is not set
+const DefaultCommandTimeout = 30 * time.Second
+
+// DefaultMaxReadsPerTurn is used when max_reads_per_turn is not set
+const DefaultMaxReadsPerTurn = 50
+
+// Secret redaction levels
+const (
+	RedactOff        = "off"
+	RedactHigh       = "high"       // Token formats that are almost always secrets
+	RedactAggressive = "aggressive" // Also generic password=/token= assignments
+)
+
+// secretPatternPrefix prefixes config keys naming custom secret patterns
+const secretPatternPrefix = "secret_pattern."
+
+// commandEnvPrefix prefixes config keys naming run_command environment
+// variables
+const commandEnvPrefix = "command_env."
+
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName reports whether name is a valid environment variable name
+func ValidEnvName(name string) bool {
+	return envNamePattern.MatchString(name)
+}
+
+// MaxAutoContinue caps auto_continue so a confused model cannot loop
+const MaxAutoContinue = 5
+
 var (
 	configDir  string
 	configFile string
-	current    *Config
+	current    *Config // The global config with the project config applied
+	global     *Config // The global config as saved
 )
 
 func init() {
@@ -37,38 +115,68 @@ func init() {
 	configFile = filepath.Join(configDir, "config.json")
 }
 
-// Load reads the config from disk
+// Load reads the config from disk. A file in an older format is copied
+// to a backup and upgraded to the current one.
 func Load() (*Config, error) {
 	if current != nil {
 		return current, nil
 	}
 
-	current = &Config{
-		DefaultProvider: "claude",
-	}
+	cfg := &Config{}
 
 	data, err := os.ReadFile(configFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return current, nil // Return default config
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
+	migrated := false
+	if err == nil {
+		upgraded, version, err := migrate(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		if err := json.Unmarshal(upgraded, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		if version < CurrentVersion {
+			// The upgraded config is used either way; the file is only
+			// rewritten once the old one is safely copied
+			if err := os.WriteFile(backupPath(version), data, 0600); err != nil {
+				logging.Warnf("%s: not upgrading the config file, backup failed: %v", configFile, err)
+			} else {
+				migrated = true
+			}
+		}
+	}
 
-	if err := json.Unmarshal(data, current); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	// The project config is checked against the global one
+	global = cfg
+	if err := loadProject(); err != nil {
+		return nil, err
+	}
+
+	if migrated {
+		err := Save(cfg)
+		if err == nil {
+			return current, nil
+		}
+		logging.Warnf("%s: failed to save the upgraded config: %v", configFile, err)
 	}
 
+	global = cfg
+	current, applied = merged()
 	return current, nil
 }
 
-// Save writes the config to disk
+// Save writes the global config to disk
 func Save(cfg *Config) error {
 	// Ensure config directory exists
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Files from a newer build keep their version
+	cfg.Version = max(cfg.Version, CurrentVersion)
+
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -78,7 +186,8 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
-	current = cfg
+	global = cfg
+	current, applied = merged()
 	return nil
 }
 
@@ -90,12 +199,40 @@ func Get() *Config {
 	return current
 }
 
-// Set updates a config value by key
+// Set updates a value in the global config by key
 func Set(key, value string) error {
-	cfg, err := Load()
-	if err != nil {
+	if _, err := Load(); err != nil {
 		return err
 	}
+	if err := setKey(global, key, value); err != nil {
+		return err
+	}
+	warnSuspicious(canonicalKey(key), value)
+	return Save(global)
+}
+
+// setKey validates a value and sets it on cfg
+func setKey(cfg *Config, key, value string) error {
+	if name, ok := strings.CutPrefix(key, secretPatternPrefix); ok && name != "" {
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		if cfg.SecretPatterns == nil {
+			cfg.SecretPatterns = make(map[string]string)
+		}
+		cfg.SecretPatterns[name] = value
+		return nil
+	}
+	if name, ok := strings.CutPrefix(key, commandEnvPrefix); ok && name != "" {
+		if !ValidEnvName(name) {
+			return fmt.Errorf("invalid environment variable name in %s", key)
+		}
+		if cfg.CommandEnv == nil {
+			cfg.CommandEnv = make(map[string]string)
+		}
+		cfg.CommandEnv[name] = value
+		return nil
+	}
 
 	switch key {
 	case "openai_api_key", "openai":
@@ -112,11 +249,88 @@ func Set(key, value string) error {
 		cfg.DefaultProvider = value
 	case "default_model", "model":
 		cfg.DefaultModel = value
+	case "web_fetch_allow_private":
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q (expected true or false)", key, value)
+		}
+		cfg.WebFetchAllowPrivate = allow
+	case "command_timeout":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("invalid value for %s: %q (expected a positive number of seconds)", key, value)
+		}
+		cfg.CommandTimeout = seconds
+	case "max_reads_per_turn":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid value for %s: %q (expected a positive number)", key, value)
+		}
+		cfg.MaxReadsPerTurn = n
+	case "command_root":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("invalid value for %s: expected a directory, or . for the working directory", key)
+		}
+		cfg.CommandRoot = value
+	case "command_allow":
+		cfg.CommandAllow = splitList(value)
+	case "command_deny":
+		cfg.CommandDeny = splitList(value)
+	case "tools.readonly":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q (expected true or false)", key, value)
+		}
+		cfg.Tools.ReadOnly = enabled
+	case "tools.disabled":
+		cfg.Tools.Disabled = splitList(value)
+	case "secret_redaction":
+		switch value {
+		case RedactOff, RedactHigh, RedactAggressive:
+			cfg.SecretRedaction = value
+		default:
+			return fmt.Errorf("invalid value for %s: %q (expected off, high or aggressive)", key, value)
+		}
+	case "auto_continue":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > MaxAutoContinue {
+			return fmt.Errorf("invalid value for %s: %q (expected 0 to %d)", key, value, MaxAutoContinue)
+		}
+		cfg.AutoContinue = n
+	case "auto_continue_pattern":
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		cfg.AutoContinuePattern = value
+	case "request_timeout":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("invalid value for %s: %q (expected a positive number of seconds)", key, value)
+		}
+		cfg.RequestTimeout = seconds
+	case "prompt_git_context":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q (expected true or false)", key, value)
+		}
+		cfg.PromptGitContext = enabled
+	case "prompt_token_budget":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value for %s: %q (expected a number of tokens, 0 for no limit)", key, value)
+		}
+		cfg.PromptTokenBudget = n
+	case "mouse":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q (expected true or false)", key, value)
+		}
+		cfg.Mouse = enabled
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
 
-	return Save(cfg)
+	return nil
 }
 
 // GetOpenAIKey returns the OpenAI API key (config or env)
@@ -167,38 +381,199 @@ func GetLiteLLMBaseURL() string {
 	return "http://localhost:4000" // Default LiteLLM proxy URL
 }
 
+// GetWebFetchAllowPrivate reports whether web_fetch may reach private hosts
+func GetWebFetchAllowPrivate() bool {
+	return Get().WebFetchAllowPrivate
+}
+
+// GetPromptGitContext reports whether the system prompt includes git history
+func GetPromptGitContext() bool {
+	return Get().PromptGitContext
+}
+
+// GetPromptTokenBudget returns the system prompt token budget, or 0 for
+// no limit
+func GetPromptTokenBudget() int {
+	return Get().PromptTokenBudget
+}
+
+// GetMouse reports whether the TUI captures the mouse
+func GetMouse() bool {
+	return Get().Mouse
+}
+
+// GetCommandRoot returns the absolute directory run_command is confined
+// to, or "" when commands are not confined. A relative command_root is
+// resolved against the working directory.
+func GetCommandRoot() string {
+	root := Get().CommandRoot
+	if root == "" {
+		return ""
+	}
+	return resolveDir(root)
+}
+
+// resolveDir returns the absolute form of a directory setting, expanding
+// a leading ~/ and resolving a relative path against the working directory
+func resolveDir(root string) string {
+	if strings.HasPrefix(root, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, root[2:])
+		}
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return root
+	}
+	return abs
+}
+
+// GetCommandAllow returns the patterns run_command is limited to, or nil
+// when any command may run
+func GetCommandAllow() []string {
+	return Get().CommandAllow
+}
+
+// GetCommandDeny returns the patterns run_command refuses
+func GetCommandDeny() []string {
+	return Get().CommandDeny
+}
+
+// GetCommandEnv returns the environment variables set for every
+// run_command, by name
+func GetCommandEnv() map[string]string {
+	return Get().CommandEnv
+}
+
+// GetToolsReadOnly reports whether only read-only tools are registered
+func GetToolsReadOnly() bool {
+	return Get().Tools.ReadOnly
+}
+
+// GetDisabledTools returns the tools that are never registered
+func GetDisabledTools() []string {
+	return Get().Tools.Disabled
+}
+
+// GetCommandTimeout returns the run_command timeout
+func GetCommandTimeout() time.Duration {
+	if seconds := Get().CommandTimeout; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return DefaultCommandTimeout
+}
+
+// GetMaxReadsPerTurn returns how many read_file/list_dir calls a turn may make
+func GetMaxReadsPerTurn() int {
+	if n := Get().MaxReadsPerTurn; n > 0 {
+		return n
+	}
+	return DefaultMaxReadsPerTurn
+}
+
+// GetSecretRedaction returns the secret redaction level
+func GetSecretRedaction() string {
+	if level := Get().SecretRedaction; level != "" {
+		return level
+	}
+	return RedactHigh
+}
+
+// GetSecretPatterns returns the custom secret patterns by name
+func GetSecretPatterns() map[string]string {
+	return Get().SecretPatterns
+}
+
+// GetAutoContinue returns how many automatic continue turns a turn may get
+func GetAutoContinue() int {
+	return min(max(Get().AutoContinue, 0), MaxAutoContinue)
+}
+
+// GetAutoContinuePattern returns the custom continue trigger, or "" for
+// the built-in one
+func GetAutoContinuePattern() string {
+	return Get().AutoContinuePattern
+}
+
+// GetRequestTimeout returns the LLM request timeout, or 0 when
+// request_timeout is not set and each provider uses its own default
+func GetRequestTimeout() time.Duration {
+	return time.Duration(Get().RequestTimeout) * time.Second
+}
+
 // ConfigPath returns the path to the config file
 func ConfigPath() string {
 	return configFile
 }
 
-// ListKeys returns configured keys (masked for display)
+// UseDir points the global config at dir and forgets the loaded config,
+// so tests can work on a throwaway config
+func UseDir(dir string) {
+	configDir = dir
+	configFile = filepath.Join(dir, "config.json")
+	current, global = nil, nil
+}
+
+// ConfigDir returns the global config directory
+func ConfigDir() string {
+	return configDir
+}
+
+// ListKeys returns configured keys (masked for display). Values from the
+// project config are marked "(project)".
 func ListKeys() map[string]string {
-	cfg := Get()
+	return listProjectKeys(false)
+}
+
+// ListKeysWithSecrets is ListKeys with API keys shown in full
+func ListKeysWithSecrets() map[string]string {
+	return listProjectKeys(true)
+}
+
+// listProjectKeys lists the keys set in the merged config, marking those
+// set by the project config. Project values that were refused are not
+// marked, since the global value is the one in effect.
+func listProjectKeys(reveal bool) map[string]string {
+	result := listKeys(Get(), reveal)
+	for key := range applied {
+		if value, ok := result[key]; ok {
+			result[key] = value + " (project)"
+		}
+	}
+	return result
+}
+
+// listKeys returns the keys set in cfg or the environment. API keys and
+// secret-looking command_env values are masked unless reveal is set.
+func listKeys(cfg *Config, reveal bool) map[string]string {
 	result := make(map[string]string)
+	mask := maskKey
+	if reveal {
+		mask = func(key string) string { return key }
+	}
 
 	if cfg.OpenAIKey != "" {
-		result["openai_api_key"] = maskKey(cfg.OpenAIKey)
+		result["openai_api_key"] = mask(cfg.OpenAIKey)
 	} else if os.Getenv("OPENAI_API_KEY") != "" {
-		result["openai_api_key"] = maskKey(os.Getenv("OPENAI_API_KEY")) + " (env)"
+		result["openai_api_key"] = mask(os.Getenv("OPENAI_API_KEY")) + " (env)"
 	}
 
 	if cfg.AnthropicKey != "" {
-		result["anthropic_api_key"] = maskKey(cfg.AnthropicKey)
+		result["anthropic_api_key"] = mask(cfg.AnthropicKey)
 	} else if os.Getenv("ANTHROPIC_API_KEY") != "" {
-		result["anthropic_api_key"] = maskKey(os.Getenv("ANTHROPIC_API_KEY")) + " (env)"
+		result["anthropic_api_key"] = mask(os.Getenv("ANTHROPIC_API_KEY")) + " (env)"
 	}
 
 	if cfg.OpenRouterKey != "" {
-		result["openrouter_api_key"] = maskKey(cfg.OpenRouterKey)
+		result["openrouter_api_key"] = mask(cfg.OpenRouterKey)
 	} else if os.Getenv("OPENROUTER_API_KEY") != "" {
-		result["openrouter_api_key"] = maskKey(os.Getenv("OPENROUTER_API_KEY")) + " (env)"
+		result["openrouter_api_key"] = mask(os.Getenv("OPENROUTER_API_KEY")) + " (env)"
 	}
 
 	if cfg.LiteLLMKey != "" {
-		result["litellm_api_key"] = maskKey(cfg.LiteLLMKey)
+		result["litellm_api_key"] = mask(cfg.LiteLLMKey)
 	} else if os.Getenv("LITELLM_API_KEY") != "" {
-		result["litellm_api_key"] = maskKey(os.Getenv("LITELLM_API_KEY")) + " (env)"
+		result["litellm_api_key"] = mask(os.Getenv("LITELLM_API_KEY")) + " (env)"
 	}
 
 	if cfg.LiteLLMBaseURL != "" {
@@ -215,23 +590,132 @@ func ListKeys() map[string]string {
 		result["default_model"] = cfg.DefaultModel
 	}
 
+	if cfg.WebFetchAllowPrivate {
+		result["web_fetch_allow_private"] = "true"
+	}
+
+	if cfg.CommandTimeout > 0 {
+		result["command_timeout"] = strconv.Itoa(cfg.CommandTimeout)
+	}
+
+	if cfg.MaxReadsPerTurn > 0 {
+		result["max_reads_per_turn"] = strconv.Itoa(cfg.MaxReadsPerTurn)
+	}
+
+	if cfg.CommandRoot != "" {
+		result["command_root"] = cfg.CommandRoot
+	}
+
+	if len(cfg.CommandAllow) > 0 {
+		result["command_allow"] = strings.Join(cfg.CommandAllow, ",")
+	}
+
+	if len(cfg.CommandDeny) > 0 {
+		result["command_deny"] = strings.Join(cfg.CommandDeny, ",")
+	}
+
+	for name, value := range cfg.CommandEnv {
+		if IsSecretName(name) {
+			value = mask(value)
+		}
+		result[commandEnvPrefix+name] = value
+	}
+
+	if cfg.Tools.ReadOnly {
+		result["tools.readonly"] = "true"
+	}
+
+	if len(cfg.Tools.Disabled) > 0 {
+		result["tools.disabled"] = strings.Join(cfg.Tools.Disabled, ",")
+	}
+
+	if cfg.SecretRedaction != "" {
+		result["secret_redaction"] = cfg.SecretRedaction
+	}
+
+	for name, pattern := range cfg.SecretPatterns {
+		result[secretPatternPrefix+name] = pattern
+	}
+
+	if cfg.AutoContinue > 0 {
+		result["auto_continue"] = strconv.Itoa(cfg.AutoContinue)
+	}
+
+	if cfg.AutoContinuePattern != "" {
+		result["auto_continue_pattern"] = cfg.AutoContinuePattern
+	}
+
+	if cfg.RequestTimeout > 0 {
+		result["request_timeout"] = strconv.Itoa(cfg.RequestTimeout)
+	}
+
+	if cfg.PromptGitContext {
+		result["prompt_git_context"] = "true"
+	}
+
+	if cfg.PromptTokenBudget > 0 {
+		result["prompt_token_budget"] = strconv.Itoa(cfg.PromptTokenBudget)
+	}
+
+	if cfg.Mouse {
+		result["mouse"] = "true"
+	}
+
 	return result
 }
 
-// maskKey shows only first 4 and last 4 characters
+// splitList parses a comma-separated list, trimming items and dropping
+// empty ones
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// maskKey shows only a key's type prefix, such as "sk-", and its last 4
+// characters
 func maskKey(key string) string {
 	if len(key) <= 8 {
 		return "****"
 	}
-	return key[:4] + "..." + key[len(key)-4:]
+	prefix := ""
+	if i := strings.IndexByte(key, '-'); i >= 0 && i < 4 {
+		prefix = key[:i+1]
+	}
+	return prefix + "..." + key[len(key)-4:]
 }
 
-// Delete removes a config value
+// Delete removes a value from the global config
 func Delete(key string) error {
-	cfg, err := Load()
-	if err != nil {
+	if _, err := Load(); err != nil {
+		return err
+	}
+	if err := deleteKey(global, key); err != nil {
 		return err
 	}
+	return Save(global)
+}
+
+// deleteKey clears a value on cfg
+func deleteKey(cfg *Config, key string) error {
+	if name, ok := strings.CutPrefix(key, secretPatternPrefix); ok {
+		if _, exists := cfg.SecretPatterns[name]; !exists {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		delete(cfg.SecretPatterns, name)
+		return nil
+	}
+	if name, ok := strings.CutPrefix(key, commandEnvPrefix); ok {
+		if _, exists := cfg.CommandEnv[name]; !exists {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		delete(cfg.CommandEnv, name)
+		return nil
+	}
 
 	switch key {
 	case "openai_api_key", "openai":
@@ -248,11 +732,41 @@ func Delete(key string) error {
 		cfg.DefaultProvider = ""
 	case "default_model", "model":
 		cfg.DefaultModel = ""
+	case "web_fetch_allow_private":
+		cfg.WebFetchAllowPrivate = false
+	case "command_timeout":
+		cfg.CommandTimeout = 0
+	case "max_reads_per_turn":
+		cfg.MaxReadsPerTurn = 0
+	case "command_root":
+		cfg.CommandRoot = ""
+	case "command_allow":
+		cfg.CommandAllow = nil
+	case "command_deny":
+		cfg.CommandDeny = nil
+	case "tools.readonly":
+		cfg.Tools.ReadOnly = false
+	case "tools.disabled":
+		cfg.Tools.Disabled = nil
+	case "secret_redaction":
+		cfg.SecretRedaction = ""
+	case "auto_continue":
+		cfg.AutoContinue = 0
+	case "auto_continue_pattern":
+		cfg.AutoContinuePattern = ""
+	case "request_timeout":
+		cfg.RequestTimeout = 0
+	case "prompt_git_context":
+		cfg.PromptGitContext = false
+	case "prompt_token_budget":
+		cfg.PromptTokenBudget = 0
+	case "mouse":
+		cfg.Mouse = false
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
 
-	return Save(cfg)
+	return nil
 }
 
 // GetAgentPaths returns paths to search for custom agent definitions
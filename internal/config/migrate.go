@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// CurrentVersion is the config file format this build writes. Files
+// without a version field predate versioning and are version 0.
+const CurrentVersion = 1
+
+// migrations upgrade a config file from the version at their index to the
+// next one. Each works on the decoded JSON, so keys it does not touch are
+// kept as they are.
+var migrations = []func(raw map[string]any){
+	// 0 -> 1: the claude and gemini providers were removed, and older
+	// builds saved claude as the default provider with every change.
+	// Dropping it lets the first run ask for a provider instead of failing.
+	func(raw map[string]any) {
+		switch raw["default_provider"] {
+		case "claude", "gemini":
+			delete(raw, "default_provider")
+		}
+	},
+}
+
+// knownKeys are the JSON keys of Config's fields. Other keys in the file,
+// written by a newer build, are kept in Config.unknown and saved back.
+var knownKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// migrate upgrades the config file contents to CurrentVersion and returns
+// them with the version they had. Files from a newer build are returned
+// unchanged.
+func migrate(data []byte) ([]byte, int, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if v, ok := raw["version"].(float64); ok {
+		version = int(v)
+	}
+	if version >= CurrentVersion {
+		return data, version, nil
+	}
+
+	for _, step := range migrations[version:] {
+		step(raw)
+	}
+	raw["version"] = CurrentVersion
+	migrated, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, version, err
+	}
+	return migrated, version, nil
+}
+
+// backupPath is where a config file of the given version is copied before
+// it is migrated
+func backupPath(version int) string {
+	return fmt.Sprintf("%s.v%d.bak", configFile, version)
+}
+
+// UnmarshalJSON decodes a config, keeping keys it does not know
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.unknown = nil
+	for key, value := range raw {
+		if !knownKeys[key] {
+			if c.unknown == nil {
+				c.unknown = make(map[string]json.RawMessage)
+			}
+			c.unknown[key] = value
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes a config, including the unknown keys it was loaded
+// with
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	data, err := json.Marshal(plain(c))
+	if err != nil || len(c.unknown) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	maps.Copy(fields, c.unknown)
+	return json.Marshal(fields)
+}
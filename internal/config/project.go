@@ -0,0 +1,391 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/simonyos/Z-CODE/internal/logging"
+)
+
+// ProjectConfigFile is the per-project config, looked for in the working
+// directory and each of its parents. Its values override the global config,
+// except that it cannot loosen the settings that protect the user.
+var ProjectConfigFile = filepath.Join(".zcode", "config.yaml")
+
+var (
+	project     map[string]string // Project settings by canonical key
+	projectFile string            // The project config in use, "" if none
+	applied     map[string]bool   // Project settings merged into current
+)
+
+// keyAliases maps the short key names Set accepts to their canonical names
+var keyAliases = map[string]string{
+	"openai":      "openai_api_key",
+	"anthropic":   "anthropic_api_key",
+	"openrouter":  "openrouter_api_key",
+	"litellm":     "litellm_api_key",
+	"litellm_url": "litellm_base_url",
+	"provider":    "default_provider",
+	"model":       "default_model",
+}
+
+// globalOnlyKeys are only read from the global config: credentials, so
+// they are not committed, and settings a cloned repository could use to
+// send them to another host or to get around the user's safeguards
+var globalOnlyKeys = map[string]bool{
+	"openai_api_key":          true,
+	"anthropic_api_key":       true,
+	"openrouter_api_key":      true,
+	"litellm_api_key":         true,
+	"litellm_base_url":        true,
+	"web_fetch_allow_private": true,
+}
+
+// isGlobalOnly reports whether a canonical key can only be set globally.
+// command_env variables are included, as PATH or LD_PRELOAD would let a
+// project choose what run_command executes.
+func isGlobalOnly(key string) bool {
+	return globalOnlyKeys[key] || strings.HasPrefix(key, commandEnvPrefix)
+}
+
+// protectedEnvNames are environment variables that choose which programs
+// run or what they load. Only the global command_env may set them;
+// protectedEnvPrefixes extends the list to whole families.
+var protectedEnvNames = map[string]bool{
+	"PATH":                  true,
+	"HOME":                  true,
+	"XDG_CONFIG_HOME":       true,
+	"IFS":                   true,
+	"ENV":                   true,
+	"BASH_ENV":              true,
+	"BASHOPTS":              true,
+	"SHELLOPTS":             true,
+	"PROMPT_COMMAND":        true,
+	"PS4":                   true,
+	"GIT_SSH":               true,
+	"GIT_SSH_COMMAND":       true,
+	"GIT_EXEC_PATH":         true,
+	"GIT_PROXY_COMMAND":     true,
+	"GIT_CONFIG_PARAMETERS": true,
+	"NODE_OPTIONS":          true,
+	"PYTHONPATH":            true,
+	"PYTHONSTARTUP":         true,
+	"PERL5LIB":              true,
+	"PERL5OPT":              true,
+	"RUBYLIB":               true,
+	"RUBYOPT":               true,
+}
+
+var protectedEnvPrefixes = []string{"LD_", "DYLD_", "GIT_CONFIG_"}
+
+// ProtectedEnvName reports whether an environment variable is one that
+// only the global command_env may set, such as PATH or LD_PRELOAD
+func ProtectedEnvName(name string) bool {
+	name = strings.ToUpper(name)
+	if protectedEnvNames[name] {
+		return true
+	}
+	for _, prefix := range protectedEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tightenOnlyKeys are the safeguards applyProject lets a project make
+// stricter but not looser, along with the secret_pattern.* keys
+var tightenOnlyKeys = map[string]bool{
+	"command_allow":    true,
+	"command_deny":     true,
+	"command_root":     true,
+	"tools.readonly":   true,
+	"tools.disabled":   true,
+	"secret_redaction": true,
+}
+
+// IsProtected reports whether a key decides where requests and
+// credentials go or weakens a safeguard when changed. Settings from a
+// shared source, such as a bundle, should only change these with the
+// user's explicit consent.
+func IsProtected(key string) bool {
+	key = canonicalKey(key)
+	return isGlobalOnly(key) || tightenOnlyKeys[key] || strings.HasPrefix(key, secretPatternPrefix)
+}
+
+// redactionStrictness orders the secret_redaction levels; unset is high
+var redactionStrictness = map[string]int{
+	RedactOff:        0,
+	"":               1,
+	RedactHigh:       1,
+	RedactAggressive: 2,
+}
+
+// canonicalKey returns the canonical name of a config key
+func canonicalKey(key string) string {
+	if name, ok := keyAliases[key]; ok {
+		return name
+	}
+	return key
+}
+
+// FindProjectConfig returns the nearest project config file, searching
+// dir and then each parent directory, or "" if there is none
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ProjectConfigPath returns the project config in use, or "" if there is
+// none
+func ProjectConfigPath() string {
+	Get()
+	return projectFile
+}
+
+// loadProject reads the project config for the working directory. Nested
+// mappings become dotted keys, so secret_pattern: {name: regex} sets
+// secret_pattern.name. Global-only keys and invalid values are skipped
+// with a warning, as are values that would loosen the global config,
+// though those are kept in the file.
+func loadProject() error {
+	project = make(map[string]string)
+	projectFile = ""
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	path := FindProjectConfig(cwd)
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read project config: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+	projectFile = path
+
+	values := make(map[string]string)
+	FlattenSettings("", raw, values)
+	for key, value := range values {
+		key = canonicalKey(key)
+		if isGlobalOnly(key) {
+			logging.Warnf("%s: ignoring %s, it is only read from the global config", path, key)
+			continue
+		}
+		if err := setKey(&Config{}, key, value); err != nil {
+			logging.Warnf("%s: %v", path, err)
+			continue
+		}
+		if err := applyProject(cloneConfig(global), key, value); err != nil {
+			logging.Warnf("%s: ignoring %s: %v", path, key, err)
+		}
+		project[key] = value
+	}
+	return nil
+}
+
+// FlattenSettings turns decoded YAML or JSON settings into the values Set
+// accepts: nested mappings become dotted keys and lists become
+// comma-separated values
+func FlattenSettings(prefix string, raw map[string]any, out map[string]string) {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			FlattenSettings(key, value, out)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = settingString(item)
+			}
+			out[key] = strings.Join(items, ",")
+		default:
+			out[key] = settingString(value)
+		}
+	}
+}
+
+// settingString formats a scalar setting. JSON numbers decode as floats,
+// which are written without an exponent so 1000000 stays 1000000.
+func settingString(value any) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// merged returns the global config with the project settings applied,
+// and the keys of the settings that were
+func merged() (*Config, map[string]bool) {
+	cfg := cloneConfig(global)
+	keys := make(map[string]bool, len(project))
+	for key, value := range project {
+		// Rejected values are reported by loadProject and SetProject
+		if applyProject(cfg, key, value) == nil {
+			keys[key] = true
+		}
+	}
+	return cfg, keys
+}
+
+// cloneConfig copies a config so the copy's lists and maps can be changed
+func cloneConfig(cfg *Config) *Config {
+	clone := &Config{}
+	if cfg != nil {
+		*clone = *cfg
+	}
+	clone.CommandAllow = slices.Clone(clone.CommandAllow)
+	clone.CommandDeny = slices.Clone(clone.CommandDeny)
+	clone.Tools.Disabled = slices.Clone(clone.Tools.Disabled)
+	clone.SecretPatterns = maps.Clone(clone.SecretPatterns)
+	clone.CommandEnv = maps.Clone(clone.CommandEnv)
+	return clone
+}
+
+// applyProject applies a project setting on top of cfg, which holds the
+// global config. The settings that protect the user can only be
+// tightened: deny lists and disabled tools are added to, the command root
+// can only move inside the global one, and redaction can only get
+// stricter. A value that would loosen them is not applied and returned as
+// an error.
+func applyProject(cfg *Config, key, value string) error {
+	setting := &Config{}
+	if err := setKey(setting, key, value); err != nil {
+		return err
+	}
+
+	switch {
+	case key == "command_deny":
+		cfg.CommandDeny = appendMissing(cfg.CommandDeny, setting.CommandDeny)
+		return nil
+	case key == "tools.disabled":
+		cfg.Tools.Disabled = appendMissing(cfg.Tools.Disabled, setting.Tools.Disabled)
+		return nil
+	case key == "command_allow":
+		if len(cfg.CommandAllow) > 0 {
+			return fmt.Errorf("the global config already limits commands with command_allow")
+		}
+	case key == "command_root":
+		if cfg.CommandRoot != "" && !withinDir(resolveDir(setting.CommandRoot), resolveDir(cfg.CommandRoot)) {
+			return fmt.Errorf("it is outside the global command_root %s", cfg.CommandRoot)
+		}
+	case key == "tools.readonly":
+		if cfg.Tools.ReadOnly && !setting.Tools.ReadOnly {
+			return fmt.Errorf("the global config enables read-only mode")
+		}
+	case key == "secret_redaction":
+		if redactionStrictness[value] < redactionStrictness[cfg.SecretRedaction] {
+			return fmt.Errorf("it is less strict than the global secret_redaction")
+		}
+	case strings.HasPrefix(key, secretPatternPrefix):
+		if _, ok := cfg.SecretPatterns[strings.TrimPrefix(key, secretPatternPrefix)]; ok {
+			return fmt.Errorf("the global config defines this pattern")
+		}
+	}
+	return setKey(cfg, key, value)
+}
+
+// appendMissing adds the items not already in list
+func appendMissing(list, items []string) []string {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// withinDir reports whether path is dir or inside it
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// SetProject sets a value in the project config, creating
+// .zcode/config.yaml in the working directory if there is no project
+// config yet. Global-only keys, and values that would loosen the global
+// config, are refused.
+func SetProject(key, value string) error {
+	if _, err := Load(); err != nil {
+		return err
+	}
+	key = canonicalKey(key)
+	if isGlobalOnly(key) {
+		return fmt.Errorf("%s can only be set in the global config, so a repository cannot change it", key)
+	}
+	if err := applyProject(cloneConfig(global), key, value); err != nil {
+		return fmt.Errorf("cannot set %s in the project config: %w", key, err)
+	}
+	warnSuspicious(key, value)
+
+	project[key] = value
+	return saveProject()
+}
+
+// DeleteProject removes a value from the project config
+func DeleteProject(key string) error {
+	if _, err := Load(); err != nil {
+		return err
+	}
+	key = canonicalKey(key)
+	if _, ok := project[key]; !ok {
+		return fmt.Errorf("%s is not set in the project config", key)
+	}
+
+	delete(project, key)
+	return saveProject()
+}
+
+// saveProject writes the project settings and reapplies them
+func saveProject() error {
+	if projectFile == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to find working directory: %w", err)
+		}
+		projectFile = filepath.Join(cwd, ProjectConfigFile)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(projectFile), 0755); err != nil {
+		return fmt.Errorf("failed to create project config directory: %w", err)
+	}
+	data, err := yaml.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project config: %w", err)
+	}
+	if err := os.WriteFile(projectFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write project config: %w", err)
+	}
+
+	current, applied = merged()
+	return nil
+}
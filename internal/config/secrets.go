@@ -0,0 +1,52 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/simonyos/Z-CODE/internal/logging"
+)
+
+// secretNamePattern matches names of settings and environment variables
+// whose values are secrets
+var secretNamePattern = regexp.MustCompile(`(?i)key|token|secret|passw|pwd|credential|auth`)
+
+// IsSecretName reports whether a variable or setting with this name
+// likely holds a secret, so its value should not be displayed
+func IsSecretName(name string) bool {
+	return secretNamePattern.MatchString(name)
+}
+
+// IsCredential reports whether a config key holds a credential: an API
+// key, or a command_env variable whose name looks secret
+func IsCredential(key string) bool {
+	key = canonicalKey(key)
+	if name, ok := strings.CutPrefix(key, commandEnvPrefix); ok {
+		return IsSecretName(name)
+	}
+	return strings.HasSuffix(key, "_api_key")
+}
+
+// apiKeyPrefixes are the prefixes each provider's API keys start with
+var apiKeyPrefixes = map[string]string{
+	"openai_api_key":     "sk-",
+	"anthropic_api_key":  "sk-ant-",
+	"openrouter_api_key": "sk-or-",
+}
+
+// warnSuspicious warns about values that are accepted but look wrong,
+// such as an API key for the wrong provider. It never blocks the change.
+func warnSuspicious(key, value string) {
+	if strings.HasSuffix(key, "_api_key") && strings.ContainsAny(value, " \t\r\n") {
+		logging.Warnf("%s contains whitespace; check it was pasted correctly", key)
+	}
+	if prefix, ok := apiKeyPrefixes[key]; ok && !strings.HasPrefix(value, prefix) {
+		logging.Warnf("%s does not start with %q, so it may not be a valid key for that provider", key, prefix)
+	}
+	if key == "litellm_base_url" {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			logging.Warnf("litellm_base_url %q is not an http(s) URL such as http://localhost:4000", value)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 // Package ignore provides .zcodeignore pattern matching for Z-CODE
-// Similar to .gitignore but for blocking tool access to certain paths
+// Similar to .gitignore but for blocking tool access to certain paths.
+// Patterns from .gitignore files are also applied unless disabled, and a
+// .zcodeallow file limits tools to the paths it lists.
 package ignore
 
 import (
@@ -8,59 +10,140 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+)
+
+// Names of the files patterns are loaded from
+const (
+	IgnoreFile    = ".zcodeignore"
+	GitignoreFile = ".gitignore"
+	AllowFile     = ".zcodeallow"
+)
+
+// Mode is how a Matcher decides which paths tools may access
+type Mode string
+
+const (
+	// ModeIgnore allows every path that is not ignored
+	ModeIgnore Mode = "ignore"
+
+	// ModeAllow allows only files matched by a .zcodeallow pattern that
+	// are not also ignored
+	ModeAllow Mode = "allow"
 )
 
 // Matcher checks if paths should be ignored based on .zcodeignore patterns
 type Matcher struct {
 	patterns  []pattern
+	allow     []pattern // From .zcodeallow files; used in ModeAllow
+	mode      Mode
 	root      string
 	statCache map[string]bool // Cache for isDir lookups to avoid repeated os.Stat calls
+	cacheMu   sync.Mutex      // Guards statCache; tools may validate paths concurrently
 }
 
 type pattern struct {
 	pattern  string
-	negation bool // patterns starting with ! are negations
-	dirOnly  bool // patterns ending with / only match directories
+	negation bool   // patterns starting with ! are negations
+	dirOnly  bool   // patterns ending with / only match directories
+	source   string // file the pattern came from, "" for the defaults
+	scope    string // directory of that file relative to the root, "" for the root itself
+}
+
+// options configures a Matcher
+type options struct {
+	includeGitignore bool
+}
+
+// Option configures NewMatcher
+type Option func(*options)
+
+// IncludeGitignore sets whether .gitignore files are loaded alongside
+// .zcodeignore files. It is on by default.
+func IncludeGitignore(include bool) Option {
+	return func(o *options) {
+		o.includeGitignore = include
+	}
 }
 
 // NewMatcher creates a new ignore matcher for the given root directory
-// It looks for .zcodeignore in the root and all parent directories
-func NewMatcher(root string) (*Matcher, error) {
+// It looks for .zcodeignore, and .gitignore unless disabled, in the root
+// and all parent directories
+func NewMatcher(root string, opts ...Option) (*Matcher, error) {
+	o := options{includeGitignore: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	files := []string{IgnoreFile}
+	if o.includeGitignore {
+		// Loaded first so .zcodeignore in the same directory can override it
+		files = []string{GitignoreFile, IgnoreFile}
+	}
+
 	m := &Matcher{
 		root:      root,
 		patterns:  []pattern{},
+		mode:      ModeIgnore,
 		statCache: make(map[string]bool),
 	}
 
-	// Load patterns from .zcodeignore files (from root up to filesystem root)
-	dir := root
-	for {
-		ignoreFile := filepath.Join(dir, ".zcodeignore")
-		if err := m.loadFile(ignoreFile); err != nil && !os.IsNotExist(err) {
-			return nil, err
-		}
-
+	// Find the directories from root up to filesystem root
+	dirs := []string{root}
+	for dir := root; ; {
 		parent := filepath.Dir(dir)
 		if parent == dir {
 			break // Reached filesystem root
 		}
+		dirs = append(dirs, parent)
 		dir = parent
 	}
 
+	// Load them farthest first, so closer files override farther ones as
+	// in git
+	for i := len(dirs) - 1; i >= 0; i-- {
+		scope := ""
+		if i > 0 {
+			rel, err := filepath.Rel(dirs[i], root)
+			if err != nil {
+				return nil, err
+			}
+			scope = filepath.ToSlash(rel)
+		}
+		for _, name := range files {
+			patterns, err := loadFile(filepath.Join(dirs[i], name), scope)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			m.patterns = append(m.patterns, patterns...)
+		}
+
+		allow, err := loadFile(filepath.Join(dirs[i], AllowFile), scope)
+		if err == nil {
+			m.mode = ModeAllow
+			m.allow = append(m.allow, allow...)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
 	// Add default patterns (always ignored)
 	m.addDefaultPatterns()
 
 	return m, nil
 }
 
-// loadFile loads patterns from a single .zcodeignore file
-func (m *Matcher) loadFile(path string) error {
+// loadFile loads patterns from a single .zcodeignore, .gitignore or
+// .zcodeallow file. scope is the path from the file's directory down to
+// the root.
+func loadFile(path, scope string) ([]pattern, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
+	var patterns []pattern
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -70,15 +153,15 @@ func (m *Matcher) loadFile(path string) error {
 			continue
 		}
 
-		m.addPattern(line)
+		patterns = append(patterns, parsePattern(line, filepath.Base(path), scope))
 	}
 
-	return scanner.Err()
+	return patterns, scanner.Err()
 }
 
-// addPattern adds a single pattern to the matcher
-func (m *Matcher) addPattern(line string) {
-	p := pattern{pattern: line}
+// parsePattern parses a single pattern line
+func parsePattern(line, source, scope string) pattern {
+	p := pattern{pattern: line, source: source, scope: scope}
 
 	// Check for negation
 	if strings.HasPrefix(line, "!") {
@@ -92,7 +175,7 @@ func (m *Matcher) addPattern(line string) {
 		p.pattern = strings.TrimSuffix(p.pattern, "/")
 	}
 
-	m.patterns = append(m.patterns, p)
+	return p
 }
 
 // addDefaultPatterns adds patterns that are always ignored
@@ -123,32 +206,66 @@ func (m *Matcher) addDefaultPatterns() {
 	}
 
 	for _, d := range defaults {
-		m.addPattern(d)
+		m.patterns = append(m.patterns, parsePattern(d, "", ""))
 	}
 }
 
+// Mode reports whether the matcher blocks ignored paths or allows only
+// the paths in .zcodeallow
+func (m *Matcher) Mode() Mode {
+	return m.mode
+}
+
 // ShouldIgnore checks if a path should be ignored
 // The path should be relative to the root directory
 func (m *Matcher) ShouldIgnore(path string) bool {
+	return m.check(path) != nil
+}
+
+// check returns an IgnoredPathError or NotAllowedPathError when a path is
+// blocked, or nil
+func (m *Matcher) check(path string) error {
 	// Normalize path separators
 	path = filepath.ToSlash(path)
 
 	// Check if it's a directory (with caching for performance)
 	isDir := m.isDirectory(path)
 
-	// Check patterns in order (later patterns override earlier ones)
-	ignored := false
-	for _, p := range m.patterns {
-		if m.matchPattern(p, path, isDir) {
-			ignored = !p.negation
+	// Ignore patterns, including the defaults, block even allowed paths
+	if matched, p := m.lastMatch(m.patterns, path, isDir); matched && !p.negation {
+		return &IgnoredPathError{Path: path, Source: p.source}
+	}
+
+	// Directories stay reachable so allowed files inside them can be found
+	if m.mode == ModeAllow && !isDir {
+		if matched, p := m.lastMatch(m.allow, path, isDir); !matched || p.negation {
+			return &NotAllowedPathError{Path: path}
 		}
 	}
 
-	return ignored
+	return nil
+}
+
+// lastMatch returns the last of patterns that matches path. Patterns are
+// in precedence order: farther files, then closer files, then the
+// defaults, so later patterns override earlier ones.
+func (m *Matcher) lastMatch(patterns []pattern, path string, isDir bool) (bool, pattern) {
+	var last pattern
+	matched := false
+	for _, p := range patterns {
+		if m.matchPattern(p, path, isDir) {
+			last = p
+			matched = true
+		}
+	}
+	return matched, last
 }
 
 // isDirectory checks if a path is a directory, with caching
 func (m *Matcher) isDirectory(path string) bool {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
 	// Check cache first
 	if isDir, ok := m.statCache[path]; ok {
 		return isDir
@@ -167,11 +284,27 @@ func (m *Matcher) isDirectory(path string) bool {
 
 // ClearCache clears the stat cache (useful after file operations)
 func (m *Matcher) ClearCache() {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
 	m.statCache = make(map[string]bool)
 }
 
-// matchPattern checks if a path matches a single pattern
+// matchPattern checks if a path, or one of the directories containing it,
+// matches a single pattern. A file inside an ignored directory is ignored.
 func (m *Matcher) matchPattern(p pattern, path string, isDir bool) bool {
+	if m.matchPath(p, path, isDir) {
+		return true
+	}
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path[:i], "/") {
+		if m.matchPath(p, path[:i], true) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath checks if a path itself matches a single pattern
+func (m *Matcher) matchPath(p pattern, path string, isDir bool) bool {
 	// Directory-only patterns don't match files
 	if p.dirOnly && !isDir {
 		return false
@@ -181,8 +314,11 @@ func (m *Matcher) matchPattern(p pattern, path string, isDir bool) bool {
 
 	// Handle patterns with leading /
 	if strings.HasPrefix(pattern, "/") {
-		// Anchored to root
+		// Anchored to the directory of the file the pattern came from
 		pattern = strings.TrimPrefix(pattern, "/")
+		if p.scope != "" {
+			path = p.scope + "/" + path
+		}
 		return m.matchGlob(pattern, path)
 	}
 
@@ -291,11 +427,7 @@ func (m *Matcher) ValidatePath(path string) error {
 		path = relPath
 	}
 
-	if m.ShouldIgnore(path) {
-		return &IgnoredPathError{Path: path}
-	}
-
-	return nil
+	return m.check(path)
 }
 
 // PathResolutionError is returned when a path cannot be safely resolved
@@ -315,12 +447,18 @@ func IsPathResolutionError(err error) bool {
 }
 
 // IgnoredPathError is returned when a path is blocked by .zcodeignore
+// or .gitignore
 type IgnoredPathError struct {
-	Path string
+	Path   string
+	Source string // .gitignore when a .gitignore pattern blocked it
 }
 
 func (e *IgnoredPathError) Error() string {
-	return "path is blocked by .zcodeignore: " + e.Path
+	source := e.Source
+	if source == "" {
+		source = IgnoreFile
+	}
+	return "path is blocked by " + source + ": " + e.Path
 }
 
 // IsIgnoredPathError checks if an error is an IgnoredPathError
@@ -329,6 +467,22 @@ func IsIgnoredPathError(err error) bool {
 	return ok
 }
 
+// NotAllowedPathError is returned in ModeAllow when no .zcodeallow pattern
+// matches a path
+type NotAllowedPathError struct {
+	Path string
+}
+
+func (e *NotAllowedPathError) Error() string {
+	return "path is not in the " + AllowFile + " allowlist: " + e.Path
+}
+
+// IsNotAllowedPathError checks if an error is a NotAllowedPathError
+func IsNotAllowedPathError(err error) bool {
+	_, ok := err.(*NotAllowedPathError)
+	return ok
+}
+
 // DefaultMatcher returns a matcher for the current working directory
 func DefaultMatcher() (*Matcher, error) {
 	cwd, err := os.Getwd()
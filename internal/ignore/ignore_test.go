@@ -0,0 +1,236 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatcher_ShouldIgnore(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // Relative to the parent of the matcher root, "proj"
+		path  string            // Relative to proj
+		want  bool
+	}{
+		{
+			name:  "child negation overrides parent pattern",
+			files: map[string]string{".zcodeignore": "*.log\n", "proj/.zcodeignore": "!keep.log\n", "proj/keep.log": ""},
+			path:  "keep.log",
+			want:  false,
+		},
+		{
+			name:  "parent pattern still applies to other files",
+			files: map[string]string{".zcodeignore": "*.log\n", "proj/.zcodeignore": "!keep.log\n", "proj/other.log": ""},
+			path:  "other.log",
+			want:  true,
+		},
+		{
+			name:  "child pattern overrides parent negation",
+			files: map[string]string{".zcodeignore": "!keep.log\n", "proj/.zcodeignore": "*.log\n", "proj/keep.log": ""},
+			path:  "keep.log",
+			want:  true,
+		},
+		{
+			name:  "later line in the same file wins",
+			files: map[string]string{"proj/.zcodeignore": "!keep.log\n*.log\n", "proj/keep.log": ""},
+			path:  "keep.log",
+			want:  true,
+		},
+		{
+			name:  "directory pattern ignores the directory",
+			files: map[string]string{"proj/.zcodeignore": "build/\n", "proj/build/out.txt": ""},
+			path:  "build",
+			want:  true,
+		},
+		{
+			name:  "directory pattern ignores files inside it",
+			files: map[string]string{"proj/.zcodeignore": "build/\n", "proj/build/out.txt": ""},
+			path:  "build/out.txt",
+			want:  true,
+		},
+		{
+			name:  "directory pattern does not match a file",
+			files: map[string]string{"proj/.zcodeignore": "build/\n", "proj/build": ""},
+			path:  "build",
+			want:  false,
+		},
+		{
+			name:  "re-included subdirectory",
+			files: map[string]string{"proj/.zcodeignore": "build/\n!build/important/\n", "proj/build/important/keep.txt": ""},
+			path:  "build/important/keep.txt",
+			want:  false,
+		},
+		{
+			name:  "re-inclusion leaves siblings ignored",
+			files: map[string]string{"proj/.zcodeignore": "build/\n!build/important/\n", "proj/build/other/out.txt": ""},
+			path:  "build/other/out.txt",
+			want:  true,
+		},
+		{
+			name:  "anchored pattern matches at the root",
+			files: map[string]string{"proj/.zcodeignore": "/build\n", "proj/build/out.txt": ""},
+			path:  "build/out.txt",
+			want:  true,
+		},
+		{
+			name:  "anchored pattern does not match deeper",
+			files: map[string]string{"proj/.zcodeignore": "/build\n", "proj/src/build/out.txt": ""},
+			path:  "src/build/out.txt",
+			want:  false,
+		},
+		{
+			name:  "anchored pattern in a parent file is relative to that file",
+			files: map[string]string{".zcodeignore": "/proj/tmp/\n", "proj/tmp/a.txt": ""},
+			path:  "tmp/a.txt",
+			want:  true,
+		},
+		{
+			name:  "anchored pattern in a parent file does not match below the root",
+			files: map[string]string{".zcodeignore": "/tmp/\n", "proj/tmp/a.txt": ""},
+			path:  "tmp/a.txt",
+			want:  false,
+		},
+		{
+			name:  "leading double star matches at any depth",
+			files: map[string]string{"proj/.zcodeignore": "**/generated/*.go\n", "proj/a/b/generated/x.go": ""},
+			path:  "a/b/generated/x.go",
+			want:  true,
+		},
+		{
+			name:  "leading double star needs the directory",
+			files: map[string]string{"proj/.zcodeignore": "**/generated/*.go\n", "proj/a/x.go": ""},
+			path:  "a/x.go",
+			want:  false,
+		},
+		{
+			name:  "trailing double star matches everything inside",
+			files: map[string]string{"proj/.zcodeignore": "docs/**\n", "proj/docs/a/b.md": ""},
+			path:  "docs/a/b.md",
+			want:  true,
+		},
+		{
+			name:  "double star with negation",
+			files: map[string]string{"proj/.zcodeignore": "docs/**\n!**/README.md\n", "proj/docs/a/README.md": ""},
+			path:  "docs/a/README.md",
+			want:  false,
+		},
+		{
+			name:  ".zcodeignore overrides .gitignore in the same directory",
+			files: map[string]string{"proj/.gitignore": "*.out\n", "proj/.zcodeignore": "!keep.out\n", "proj/keep.out": ""},
+			path:  "keep.out",
+			want:  false,
+		},
+		{
+			name:  "defaults cannot be negated",
+			files: map[string]string{"proj/.zcodeignore": "!.env\n", "proj/.env": ""},
+			path:  ".env",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(base, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("failed to create %s: %v", name, err)
+				}
+			}
+
+			m, err := NewMatcher(filepath.Join(base, "proj"))
+			if err != nil {
+				t.Fatalf("NewMatcher() error = %v", err)
+			}
+			if got := m.ShouldIgnore(tt.path); got != tt.want {
+				t.Errorf("ShouldIgnore(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_AllowMode(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".zcodeallow":         "src/\n*.md\n!src/vendor/\n",
+		".zcodeignore":        "src/generated/\n",
+		"src/main.go":         "",
+		"src/vendor/lib.go":   "",
+		"src/generated/x.go":  "",
+		"src/.env":            "",
+		"README.md":           "",
+		"scripts/deploy.sh":   "",
+		"scripts/nested/a.go": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	m, err := NewMatcher(root)
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+	if m.Mode() != ModeAllow {
+		t.Fatalf("Mode() = %s, want %s", m.Mode(), ModeAllow)
+	}
+
+	tests := []struct {
+		path       string
+		notAllowed bool
+		ignored    bool
+	}{
+		{path: "src/main.go"},
+		{path: "README.md"},
+		{path: "scripts"}, // Directories stay reachable
+		{path: "scripts/deploy.sh", notAllowed: true},
+		{path: "scripts/nested/a.go", notAllowed: true},
+		{path: "src/vendor/lib.go", notAllowed: true},
+		{path: "src/generated/x.go", ignored: true},
+		{path: "src/.env", ignored: true}, // Defaults are a hard deny
+	}
+	for _, tt := range tests {
+		err := m.ValidatePath(filepath.Join(root, tt.path))
+		switch {
+		case tt.notAllowed && !IsNotAllowedPathError(err):
+			t.Errorf("ValidatePath(%s) = %v, want NotAllowedPathError", tt.path, err)
+		case tt.ignored && !IsIgnoredPathError(err):
+			t.Errorf("ValidatePath(%s) = %v, want IgnoredPathError", tt.path, err)
+		case !tt.notAllowed && !tt.ignored && err != nil:
+			t.Errorf("ValidatePath(%s) = %v, want nil", tt.path, err)
+		}
+		if got, want := m.ShouldIgnore(tt.path), tt.notAllowed || tt.ignored; got != want {
+			t.Errorf("ShouldIgnore(%s) = %v, want %v", tt.path, got, want)
+		}
+	}
+
+	err = m.ValidatePath(filepath.Join(root, "scripts", "deploy.sh"))
+	if err == nil || !strings.Contains(err.Error(), "not in the .zcodeallow allowlist") {
+		t.Errorf("error should say the path is not in the allowlist, got: %v", err)
+	}
+
+	// Without .zcodeallow every path that is not ignored is allowed
+	if err := os.Remove(filepath.Join(root, ".zcodeallow")); err != nil {
+		t.Fatalf("failed to remove .zcodeallow: %v", err)
+	}
+	m, err = NewMatcher(root)
+	if err != nil {
+		t.Fatalf("NewMatcher() error = %v", err)
+	}
+	if m.Mode() != ModeIgnore {
+		t.Errorf("Mode() = %s, want %s", m.Mode(), ModeIgnore)
+	}
+	if m.ShouldIgnore("scripts/deploy.sh") {
+		t.Error("scripts/deploy.sh should be allowed without .zcodeallow")
+	}
+}
@@ -110,7 +110,7 @@ func NewAnthropic(model string) *Anthropic {
 		APIKey:  apiKey,
 		Model:   model,
 		BaseURL: "https://api.anthropic.com/v1",
-		client:  &http.Client{Timeout: defaultAnthropicTimeout},
+		client:  &http.Client{Timeout: requestTimeout(defaultAnthropicTimeout)},
 	}
 }
 
@@ -235,13 +235,13 @@ func (a *Anthropic) Generate(ctx context.Context, messages []Message) (string, e
 
 	resp, err := a.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -301,7 +301,7 @@ func (a *Anthropic) GenerateStream(ctx context.Context, messages []Message) (<-c
 
 	resp, err := a.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -325,7 +325,7 @@ func (a *Anthropic) GenerateStream(ctx context.Context, messages []Message) (<-c
 				if err == io.EOF {
 					break
 				}
-				chunks <- StreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- StreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
@@ -394,13 +394,13 @@ func (a *Anthropic) GenerateWithTools(ctx context.Context, messages []Message, t
 
 	resp, err := a.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -482,7 +482,7 @@ func (a *Anthropic) GenerateStreamWithTools(ctx context.Context, messages []Mess
 
 	resp, err := a.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -509,7 +509,7 @@ func (a *Anthropic) GenerateStreamWithTools(ctx context.Context, messages []Mess
 				if err == io.EOF {
 					break
 				}
-				chunks <- ToolStreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- ToolStreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
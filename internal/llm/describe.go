@@ -0,0 +1,19 @@
+package llm
+
+import "fmt"
+
+// Describe returns the name and model of a provider, for log records
+func Describe(p Provider) (provider, model string) {
+	switch p := p.(type) {
+	case *OpenAI:
+		return "openai", p.Model
+	case *OpenRouter:
+		return "openrouter", p.Model
+	case *LiteLLM:
+		return "litellm", p.Model
+	case *Anthropic:
+		return "anthropic", p.Model
+	default:
+		return fmt.Sprintf("%T", p), ""
+	}
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+// defaultRequestTimeout bounds a request when request_timeout is not set
+const defaultRequestTimeout = 2 * time.Minute
+
+var (
+	// ErrRequestTimeout marks a request that ran out of time
+	ErrRequestTimeout = errors.New("request timed out")
+
+	// ErrInterrupted marks a request canceled by the caller, e.g. the user
+	// pressing Esc
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// ClassifyError wraps err with ErrInterrupted or ErrRequestTimeout when it
+// was caused by cancellation or a deadline, either of ctx or of the HTTP
+// client. Other errors are returned unchanged.
+func ClassifyError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrInterrupted) || errors.Is(err, ErrRequestTimeout) {
+		return err
+	}
+
+	// Cancellation wins: a canceled request can also surface as a timeout
+	if errors.Is(err, context.Canceled) || (ctx != nil && errors.Is(ctx.Err(), context.Canceled)) {
+		return fmt.Errorf("%w: %w", ErrInterrupted, err)
+	}
+	if isTimeout(err) || (ctx != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	}
+	return err
+}
+
+// requestTimeout returns the configured request_timeout, or def if unset
+func requestTimeout(def time.Duration) time.Duration {
+	if timeout := config.GetRequestTimeout(); timeout > 0 {
+		return timeout
+	}
+	return def
+}
+
+// isTimeout reports whether err is a deadline or network timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// ErrorMessage returns the text shown to the user for a failed request.
+// Timeouts and interruptions get a short explanation instead of the raw
+// transport error.
+func ErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	err = ClassifyError(context.Background(), err)
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		return "Interrupted"
+	case errors.Is(err, ErrRequestTimeout):
+		return "Request timed out — consider a longer timeout (zcode config set request_timeout <seconds>) or a smaller task"
+	}
+	return err.Error()
+}
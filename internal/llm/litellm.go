@@ -28,12 +28,13 @@ type LiteLLM struct {
 func NewLiteLLM(model string) *LiteLLM {
 	apiKey := config.GetLiteLLMKey()
 	baseURL := config.GetLiteLLMBaseURL()
+	timeout := requestTimeout(defaultRequestTimeout)
 	return &LiteLLM{
 		APIKey:  apiKey,
 		Model:   model,
 		BaseURL: baseURL,
-		Timeout: 2 * time.Minute,
-		client:  &http.Client{Timeout: 2 * time.Minute},
+		Timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
 	}
 }
 
@@ -88,7 +89,7 @@ func (l *LiteLLM) Generate(ctx context.Context, messages []Message) (string, err
 
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
@@ -99,7 +100,7 @@ func (l *LiteLLM) Generate(ctx context.Context, messages []Message) (string, err
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var openAIResp openAIResponse
@@ -144,7 +145,7 @@ func (l *LiteLLM) GenerateStream(ctx context.Context, messages []Message) (<-cha
 
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -168,7 +169,7 @@ func (l *LiteLLM) GenerateStream(ctx context.Context, messages []Message) (<-cha
 				if err == io.EOF {
 					break
 				}
-				chunks <- StreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- StreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
@@ -291,7 +292,7 @@ func (l *LiteLLM) GenerateWithTools(ctx context.Context, messages []Message, too
 
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
@@ -302,7 +303,7 @@ func (l *LiteLLM) GenerateWithTools(ctx context.Context, messages []Message, too
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var toolResp toolResponse
@@ -354,7 +355,7 @@ func (l *LiteLLM) GenerateStreamWithTools(ctx context.Context, messages []Messag
 
 	resp, err := l.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -379,7 +380,7 @@ func (l *LiteLLM) GenerateStreamWithTools(ctx context.Context, messages []Messag
 				if err == io.EOF {
 					break
 				}
-				chunks <- ToolStreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- ToolStreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
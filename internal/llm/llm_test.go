@@ -2,7 +2,13 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestMessage(t *testing.T) {
@@ -79,6 +85,10 @@ func (m *MockProvider) GenerateStream(ctx context.Context, messages []Message) (
 	return ch, nil
 }
 
+func (m *MockProvider) Validate(ctx context.Context) error {
+	return nil
+}
+
 func TestMockProvider(t *testing.T) {
 	ctx := context.Background()
 
@@ -172,6 +182,55 @@ func TestNewOpenAI(t *testing.T) {
 	}
 }
 
+func TestErrorMessage_TimeoutVsCancel(t *testing.T) {
+	// A server that never answers, so every request ends by context
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider := NewOpenAIWithKey("test-key", "gpt-4o")
+	provider.BaseURL = server.URL
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelTimeout()
+	_, timeoutErr := provider.GenerateWithTools(timeoutCtx, []Message{{Role: "user", Content: "hi"}}, nil)
+	if !errors.Is(timeoutErr, ErrRequestTimeout) {
+		t.Errorf("deadline error = %v, want ErrRequestTimeout", timeoutErr)
+	}
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, cancelErr := provider.GenerateWithTools(cancelCtx, []Message{{Role: "user", Content: "hi"}}, nil)
+	if !errors.Is(cancelErr, ErrInterrupted) {
+		t.Errorf("cancel error = %v, want ErrInterrupted", cancelErr)
+	}
+
+	timeoutMsg, cancelMsg := ErrorMessage(timeoutErr), ErrorMessage(cancelErr)
+	if timeoutMsg == cancelMsg {
+		t.Fatalf("timeout and cancel share the message %q", timeoutMsg)
+	}
+	if !contains(timeoutMsg, "timed out") {
+		t.Errorf("timeout message = %q", timeoutMsg)
+	}
+	if cancelMsg != "Interrupted" {
+		t.Errorf("cancel message = %q, want %q", cancelMsg, "Interrupted")
+	}
+
+	// Raw context errors and unrelated errors are described too
+	if got := ErrorMessage(context.DeadlineExceeded); got != timeoutMsg {
+		t.Errorf("ErrorMessage(DeadlineExceeded) = %q, want %q", got, timeoutMsg)
+	}
+	if got := ErrorMessage(errors.New("boom")); got != "boom" {
+		t.Errorf("ErrorMessage(boom) = %q", got)
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
@@ -185,3 +244,173 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestValidate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "gpt-4o"}, {"id": "gpt-4o-mini"}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := NewOpenAIWithKey("test-key", "gpt-4o")
+	provider.BaseURL = server.URL
+	if err := provider.Validate(ctx); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// A typo in the model name lists the valid ones
+	provider.Model = "gpt-4-o"
+	err := provider.Validate(ctx)
+	var modelErr *ModelError
+	if !errors.As(err, &modelErr) || !errors.Is(err, ErrUnknownModel) {
+		t.Fatalf("Validate() error = %v, want a ModelError", err)
+	}
+	if len(modelErr.Available) != 2 || modelErr.Available[0] != "gpt-4o" {
+		t.Errorf("Available = %v, want [gpt-4o gpt-4o-mini]", modelErr.Available)
+	}
+
+	// A wrong key is reported as such
+	provider = NewOpenAIWithKey("wrong-key", "gpt-4o")
+	provider.BaseURL = server.URL
+	if err := provider.Validate(ctx); err == nil || errors.Is(err, ErrUnknownModel) {
+		t.Errorf("Validate() with a wrong key error = %v, want a rejected key", err)
+	}
+
+	// No request is made without a key
+	provider = NewOpenAIWithKey("", "gpt-4o")
+	provider.BaseURL = server.URL
+	if err := provider.Validate(ctx); !errors.Is(err, ErrMissingAPIKey) {
+		t.Errorf("Validate() without a key error = %v, want ErrMissingAPIKey", err)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"data": [{"id": "gpt-4o-mini"}, {"id": "gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := NewOpenAIWithKey("test-key", "gpt-4o")
+	provider.BaseURL = server.URL
+
+	list := AvailableModels(ctx, provider)
+	if list.Err != nil || list.Static {
+		t.Fatalf("AvailableModels() = %+v", list)
+	}
+	if len(list.Models) != 2 || list.Models[0] != "gpt-4o" {
+		t.Errorf("Models = %v, want [gpt-4o gpt-4o-mini]", list.Models)
+	}
+
+	// The list is cached
+	if err := provider.Validate(ctx); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("models endpoint called %d times, want 1", n)
+	}
+
+	out := FormatModels([]ProviderModels{list}, "openai", "gpt-4o", "")
+	if !strings.Contains(out, "* gpt-4o (current)") || strings.Contains(out, "* gpt-4o-mini") {
+		t.Errorf("FormatModels() should mark only the current model:\n%s", out)
+	}
+	if out := FormatModels([]ProviderModels{list}, "openai", "gpt-4o", "MINI"); strings.Contains(out, "gpt-4o\n") || !strings.Contains(out, "gpt-4o-mini") {
+		t.Errorf("FormatModels() with a filter:\n%s", out)
+	}
+
+	// Providers that cannot list their models fall back to the curated list
+	fallback := AvailableModels(ctx, &MockProvider{})
+	if !fallback.Static {
+		t.Errorf("AvailableModels() without a lister should use the curated list")
+	}
+}
+
+func TestContinuationTail(t *testing.T) {
+	tests := []struct {
+		name         string
+		delivered    string
+		continuation string
+		want         string
+	}{
+		{
+			name:         "clean continuation",
+			delivered:    "The quick brown ",
+			continuation: "fox jumps over the lazy dog.",
+			want:         "fox jumps over the lazy dog.",
+		},
+		{
+			name:         "overlapping continuation",
+			delivered:    "The quick brown fox jumps",
+			continuation: "fox jumps over the lazy dog.",
+			want:         " over the lazy dog.",
+		},
+		{
+			name:         "full restart",
+			delivered:    "The quick brown fox",
+			continuation: "The quick brown fox jumps over the lazy dog.",
+			want:         " jumps over the lazy dog.",
+		},
+		{
+			name:         "gapped continuation",
+			delivered:    "Step 1: install deps.",
+			continuation: "Step 3: run the tests.",
+			want:         "Step 3: run the tests.",
+		},
+		{
+			name:         "short coincidental overlap kept",
+			delivered:    "value is a",
+			continuation: "a, b and c",
+			want:         "a, b and c",
+		},
+		{
+			name:         "continuation fully repeated",
+			delivered:    "Hello world",
+			continuation: "world",
+			want:         "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := continuationTail(tt.delivered, tt.continuation); got != tt.want {
+				t.Errorf("continuationTail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamResumer_ChunkedOverlap(t *testing.T) {
+	r := NewStreamResumer("The quick brown fox jumps")
+
+	var out strings.Builder
+	for _, chunk := range []string{"fox ", "jum", "ps over", " the lazy dog."} {
+		out.WriteString(r.Feed(chunk))
+	}
+	out.WriteString(r.Flush())
+
+	if got, want := out.String(), " over the lazy dog."; got != want {
+		t.Errorf("resumed text = %q, want %q", got, want)
+	}
+}
+
+func TestStreamResumer_FlushBuffered(t *testing.T) {
+	r := NewStreamResumer("The quick brown fox jumps")
+
+	// Entirely repeated text stays buffered and is dropped on flush
+	if got := r.Feed("fox jumps"); got != "" {
+		t.Errorf("Feed() = %q, want buffered", got)
+	}
+	if got := r.Flush(); got != "" {
+		t.Errorf("Flush() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelCacheTTL is how long a provider's model list is reused before it is
+// fetched again
+const ModelCacheTTL = 5 * time.Minute
+
+// Providers lists the providers zcode can run with, in display order
+var Providers = []string{"openai", "openrouter", "litellm"}
+
+// DefaultModels is the model used for each provider when none is given
+var DefaultModels = map[string]string{
+	"openai":     "gpt-4o",
+	"openrouter": "anthropic/claude-sonnet-4",
+	"litellm":    "gpt-4o",
+}
+
+// KnownModels is a curated list for each provider, shown when the provider
+// does not list its models
+var KnownModels = map[string][]string{
+	"openai": {
+		"gpt-4.1",
+		"gpt-4.1-mini",
+		"gpt-4o",
+		"gpt-4o-mini",
+		"o3",
+		"o4-mini",
+	},
+	"openrouter": {
+		"anthropic/claude-3.5-sonnet",
+		"anthropic/claude-sonnet-4",
+		"google/gemini-flash-1.5",
+		"openai/gpt-4o",
+		"openai/gpt-4o-mini",
+	},
+	"litellm": {
+		"anthropic/claude-3.5-sonnet",
+		"google/gemini-flash-1.5",
+		"gpt-4o",
+		"gpt-4o-mini",
+	},
+}
+
+// ModelLister is implemented by providers that can list their models
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// NewProvider creates a provider by name. An empty model selects the
+// provider's default.
+func NewProvider(name, model string) (Provider, error) {
+	name = strings.ToLower(name)
+	if model == "" {
+		model = DefaultModels[name]
+	}
+	switch name {
+	case "openai":
+		return NewOpenAI(model), nil
+	case "openrouter":
+		return NewOpenRouter(model), nil
+	case "litellm":
+		return NewLiteLLM(model), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s (supported: %s)", name, strings.Join(Providers, ", "))
+	}
+}
+
+// ProviderModels is the model list of one provider
+type ProviderModels struct {
+	Provider string
+	Models   []string
+	Static   bool  // Models come from KnownModels, not the provider
+	Err      error // Set when the provider could not be asked
+}
+
+// AvailableModels returns the models a provider offers. When it cannot
+// list them, or lists none, the curated KnownModels are returned instead.
+func AvailableModels(ctx context.Context, p Provider) ProviderModels {
+	name, _ := Describe(p)
+	result := ProviderModels{Provider: name}
+	if lister, ok := p.(ModelLister); ok {
+		result.Models, result.Err = lister.ListModels(ctx)
+		if result.Err != nil || len(result.Models) > 0 {
+			return result
+		}
+	}
+	result.Models = KnownModels[name]
+	result.Static = true
+	return result
+}
+
+// ListProviderModels asks every provider in Providers for its models, in
+// parallel, and returns the lists in Providers order
+func ListProviderModels(ctx context.Context) []ProviderModels {
+	results := make([]ProviderModels, len(Providers))
+	var wg sync.WaitGroup
+	for i, name := range Providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider, err := NewProvider(name, "")
+			if err != nil {
+				results[i] = ProviderModels{Provider: name, Err: err}
+				return
+			}
+			results[i] = AvailableModels(ctx, provider)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// FilterModels returns the models containing filter, ignoring case
+func FilterModels(models []string, filter string) []string {
+	if filter == "" {
+		return models
+	}
+	filter = strings.ToLower(filter)
+	var matched []string
+	for _, model := range models {
+		if strings.Contains(strings.ToLower(model), filter) {
+			matched = append(matched, model)
+		}
+	}
+	return matched
+}
+
+// FormatModels renders model lists for display, marking the current
+// provider and model. Only models containing filter are shown.
+func FormatModels(lists []ProviderModels, currentProvider, currentModel, filter string) string {
+	var sb strings.Builder
+	for i, list := range lists {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if list.Err != nil {
+			fmt.Fprintf(&sb, "%s: %v\n", list.Provider, list.Err)
+			continue
+		}
+
+		header := list.Provider
+		if list.Static {
+			header += " (curated list, the provider did not list its models)"
+		}
+		sb.WriteString(header + ":\n")
+
+		models := FilterModels(list.Models, filter)
+		if len(models) == 0 {
+			sb.WriteString("  (no matching models)\n")
+		}
+		for _, model := range models {
+			if list.Provider == currentProvider && model == currentModel {
+				fmt.Fprintf(&sb, "  * %s (current)\n", model)
+			} else {
+				fmt.Fprintf(&sb, "    %s\n", model)
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+type modelCacheEntry struct {
+	models  []string
+	fetched time.Time
+}
+
+var (
+	modelCacheMu sync.Mutex
+	modelCache   = map[string]modelCacheEntry{}
+)
+
+// modelCacheKey identifies an endpoint and the credentials used on it
+func modelCacheKey(baseURL string, headers map[string]string) string {
+	parts := []string{strings.TrimSuffix(baseURL, "/")}
+	for key, value := range headers {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts[1:])
+	return strings.Join(parts, "\n")
+}
+
+// cachedModels returns a model list fetched within ModelCacheTTL
+func cachedModels(key string) ([]string, bool) {
+	modelCacheMu.Lock()
+	defer modelCacheMu.Unlock()
+	entry, ok := modelCache[key]
+	if !ok || time.Since(entry.fetched) > ModelCacheTTL {
+		return nil, false
+	}
+	return slices.Clone(entry.models), true
+}
+
+// cacheModels stores a freshly fetched model list
+func cacheModels(key string, models []string) {
+	modelCacheMu.Lock()
+	defer modelCacheMu.Unlock()
+	modelCache[key] = modelCacheEntry{models: slices.Clone(models), fetched: time.Now()}
+}
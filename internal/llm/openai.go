@@ -80,12 +80,13 @@ type openAIStreamResponse struct {
 // NewOpenAI creates a new OpenAI provider
 func NewOpenAI(model string) *OpenAI {
 	apiKey := config.GetOpenAIKey()
+	timeout := requestTimeout(defaultRequestTimeout)
 	return &OpenAI{
 		APIKey:  apiKey,
 		Model:   model,
 		BaseURL: "https://api.openai.com/v1",
-		Timeout: 2 * time.Minute,
-		client:  &http.Client{Timeout: 2 * time.Minute},
+		Timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
 	}
 }
 
@@ -139,13 +140,13 @@ func (o *OpenAI) Generate(ctx context.Context, messages []Message) (string, erro
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var openAIResp openAIResponse
@@ -192,7 +193,7 @@ func (o *OpenAI) GenerateStream(ctx context.Context, messages []Message) (<-chan
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -216,7 +217,7 @@ func (o *OpenAI) GenerateStream(ctx context.Context, messages []Message) (<-chan
 				if err == io.EOF {
 					break
 				}
-				chunks <- StreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- StreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
@@ -298,7 +299,7 @@ func (o *OpenAI) GenerateWithTools(ctx context.Context, messages []Message, tool
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
@@ -309,7 +310,7 @@ func (o *OpenAI) GenerateWithTools(ctx context.Context, messages []Message, tool
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var toolResp toolResponse
@@ -363,7 +364,7 @@ func (o *OpenAI) GenerateStreamWithTools(ctx context.Context, messages []Message
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -388,7 +389,7 @@ func (o *OpenAI) GenerateStreamWithTools(ctx context.Context, messages []Message
 				if err == io.EOF {
 					break
 				}
-				chunks <- ToolStreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- ToolStreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
@@ -26,12 +26,13 @@ type OpenRouter struct {
 // NewOpenRouter creates a new OpenRouter provider
 func NewOpenRouter(model string) *OpenRouter {
 	apiKey := config.GetOpenRouterKey()
+	timeout := requestTimeout(defaultRequestTimeout)
 	return &OpenRouter{
 		APIKey:  apiKey,
 		Model:   model,
 		BaseURL: "https://openrouter.ai/api/v1",
-		Timeout: 2 * time.Minute,
-		client:  &http.Client{Timeout: 2 * time.Minute},
+		Timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
 	}
 }
 
@@ -87,13 +88,13 @@ func (o *OpenRouter) Generate(ctx context.Context, messages []Message) (string,
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var openAIResp openAIResponse
@@ -142,7 +143,7 @@ func (o *OpenRouter) GenerateStream(ctx context.Context, messages []Message) (<-
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -166,7 +167,7 @@ func (o *OpenRouter) GenerateStream(ctx context.Context, messages []Message) (<-
 				if err == io.EOF {
 					break
 				}
-				chunks <- StreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- StreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
@@ -250,7 +251,7 @@ func (o *OpenRouter) GenerateWithTools(ctx context.Context, messages []Message,
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
@@ -261,7 +262,7 @@ func (o *OpenRouter) GenerateWithTools(ctx context.Context, messages []Message,
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
 	}
 
 	var toolResp toolResponse
@@ -317,7 +318,7 @@ func (o *OpenRouter) GenerateStreamWithTools(ctx context.Context, messages []Mes
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, ClassifyError(ctx, fmt.Errorf("request failed: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -342,7 +343,7 @@ func (o *OpenRouter) GenerateStreamWithTools(ctx context.Context, messages []Mes
 				if err == io.EOF {
 					break
 				}
-				chunks <- ToolStreamChunk{Error: fmt.Errorf("error reading stream: %w", err)}
+				chunks <- ToolStreamChunk{Error: ClassifyError(ctx, fmt.Errorf("error reading stream: %w", err))}
 				return
 			}
 
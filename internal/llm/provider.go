@@ -25,4 +25,8 @@ type Provider interface {
 
 	// GenerateStream produces a streaming response
 	GenerateStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error)
+
+	// Validate checks that the provider is configured and offers its
+	// model, without generating anything
+	Validate(ctx context.Context) error
 }
@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	// MaxStreamResumes is how many times a dropped stream is resumed per
+	// response
+	MaxStreamResumes = 2
+
+	// resumeOverlapWindow is how much of the delivered tail is compared
+	// against the start of a continuation
+	resumeOverlapWindow = 512
+
+	// minResumeOverlap is the shortest overlap treated as repeated text.
+	// Shorter matches are too likely to be coincidental.
+	minResumeOverlap = 4
+)
+
+// ResumePrompt asks the model to pick up an interrupted response
+const ResumePrompt = "Your previous response was interrupted. Continue exactly where it stopped, without repeating any text you already wrote."
+
+// continuationTail returns the part of continuation that is not already
+// present at the end of delivered. The model often repeats the last few
+// words (or its whole short answer) when asked to continue, so the longest
+// suffix of delivered that is also a prefix of continuation is dropped.
+// If there is no such overlap the continuation is returned unchanged.
+func continuationTail(delivered, continuation string) string {
+	window := delivered
+	if len(window) > resumeOverlapWindow {
+		window = window[len(window)-resumeOverlapWindow:]
+	}
+
+	maxOverlap := min(len(window), len(continuation))
+	for k := maxOverlap; k >= minResumeOverlap; k-- {
+		if window[len(window)-k:] == continuation[:k] {
+			return continuation[k:]
+		}
+	}
+
+	return continuation
+}
+
+// StreamResumer de-duplicates a continuation stream against text that was
+// already delivered before the stream dropped. Chunks are buffered only
+// while they could still be part of an overlap with the delivered tail.
+type StreamResumer struct {
+	delivered string
+	window    string
+	pending   strings.Builder
+	resolved  bool
+}
+
+// NewStreamResumer creates a resumer for a stream that already delivered text
+func NewStreamResumer(delivered string) *StreamResumer {
+	window := delivered
+	if len(window) > resumeOverlapWindow {
+		window = window[len(window)-resumeOverlapWindow:]
+	}
+	return &StreamResumer{delivered: delivered, window: window}
+}
+
+// Feed accepts the next continuation chunk and returns the text that is
+// safe to emit. It returns "" while the overlap is still undecided.
+func (r *StreamResumer) Feed(chunk string) string {
+	if r.resolved {
+		return chunk
+	}
+
+	r.pending.WriteString(chunk)
+	if strings.Contains(r.window, r.pending.String()) {
+		// Still possibly repeating delivered text, wait for more
+		return ""
+	}
+	return r.resolve()
+}
+
+// Flush returns any text still buffered when the continuation ends
+func (r *StreamResumer) Flush() string {
+	if r.resolved {
+		return ""
+	}
+	return r.resolve()
+}
+
+func (r *StreamResumer) resolve() string {
+	r.resolved = true
+	return continuationTail(r.delivered, r.pending.String())
+}
+
+// StreamWithResume streams one response, passing its text to onChunk as
+// it arrives, and returns the full text and any tool calls. A stream that
+// drops after text was delivered is resumed up to MaxStreamResumes times
+// by asking the model to continue; repeated text is dropped, so onChunk
+// sees the answer neither repeated nor cut short. On error the text
+// delivered so far is returned with it.
+func StreamWithResume(ctx context.Context, provider ToolProvider, messages []Message, tools []OpenAITool, onChunk func(text string)) (string, []OpenAIToolCall, error) {
+	chunks, err := provider.GenerateStreamWithTools(ctx, messages, tools)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var delivered string
+	var resumer *StreamResumer
+	deliver := func(text string) {
+		if text != "" {
+			onChunk(text)
+			delivered += text
+		}
+	}
+
+	for resumes := 0; ; resumes++ {
+		var streamErr error
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+				break
+			}
+			if chunk.Done {
+				if resumer == nil {
+					return chunk.Text, chunk.ToolCalls, nil
+				}
+				deliver(resumer.Flush())
+				return delivered, chunk.ToolCalls, nil
+			}
+			if resumer != nil {
+				deliver(resumer.Feed(chunk.Text))
+			} else {
+				deliver(chunk.Text)
+			}
+		}
+
+		if streamErr == nil {
+			// Stream closed without a final chunk
+			if resumer != nil {
+				deliver(resumer.Flush())
+			}
+			return delivered, nil, nil
+		}
+
+		// Nothing to resume from, or the caller gave up
+		if delivered == "" || ctx.Err() != nil || resumes >= MaxStreamResumes {
+			return delivered, nil, streamErr
+		}
+
+		// The resume scaffolding is only sent, never added to messages
+		resumeMessages := append(messages[:len(messages):len(messages)],
+			Message{Role: "assistant", Content: delivered},
+			Message{Role: "user", Content: ResumePrompt},
+		)
+		resumer = NewStreamResumer(delivered)
+		chunks, err = provider.GenerateStreamWithTools(ctx, resumeMessages, tools)
+		if err != nil {
+			return delivered, nil, err
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMissingAPIKey marks a provider that has no API key configured
+	ErrMissingAPIKey = errors.New("API key not configured")
+
+	// ErrUnknownModel marks a model the provider does not list
+	ErrUnknownModel = errors.New("unknown model")
+)
+
+// ModelError is returned by Validate when the model is not one the
+// provider offers. Available lists the models it does offer.
+type ModelError struct {
+	Model     string
+	Available []string
+}
+
+func (e *ModelError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrUnknownModel, e.Model)
+}
+
+func (e *ModelError) Unwrap() error {
+	return ErrUnknownModel
+}
+
+// modelsResponse is the model list returned by OpenAI-compatible APIs and
+// by Anthropic
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// listModels fetches baseURL/models and returns the model IDs, sorted.
+// Lists are cached for ModelCacheTTL per endpoint and credentials.
+func listModels(ctx context.Context, client *http.Client, baseURL string, headers map[string]string) ([]string, error) {
+	key := modelCacheKey(baseURL, headers)
+	if models, ok := cachedModels(key); ok {
+		return models, nil
+	}
+	models, err := fetchModels(ctx, client, baseURL, headers)
+	if err != nil {
+		return nil, err
+	}
+	cacheModels(key, models)
+	return models, nil
+}
+
+// fetchModels requests baseURL/models
+func fetchModels(ctx context.Context, client *http.Client, baseURL string, headers map[string]string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, ClassifyError(ctx, fmt.Errorf("cannot reach %s: %w", baseURL, err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ClassifyError(ctx, fmt.Errorf("failed to read response: %w", err))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("API key rejected (status %d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("listing models failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var models modelsResponse
+	if err := json.Unmarshal(body, &models); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+
+	ids := make([]string, 0, len(models.Data))
+	for _, m := range models.Data {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// checkModel returns a ModelError unless model is in available. An empty
+// list is trusted, since some proxies do not list their models.
+func checkModel(model string, available []string) error {
+	if len(available) == 0 {
+		return nil
+	}
+	for _, id := range available {
+		if id == model {
+			return nil
+		}
+	}
+	return &ModelError{Model: model, Available: available}
+}
+
+// ListModels returns the models OpenAI offers
+func (o *OpenAI) ListModels(ctx context.Context) ([]string, error) {
+	if o.APIKey == "" {
+		return nil, fmt.Errorf("OpenAI %w. Use 'zcode config set openai <key>' or set OPENAI_API_KEY", ErrMissingAPIKey)
+	}
+	return listModels(ctx, o.client, o.BaseURL, map[string]string{"Authorization": "Bearer " + o.APIKey})
+}
+
+// Validate checks the API key and that the model is listed by OpenAI
+func (o *OpenAI) Validate(ctx context.Context) error {
+	models, err := o.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	return checkModel(o.Model, models)
+}
+
+// ListModels returns the models OpenRouter offers
+func (o *OpenRouter) ListModels(ctx context.Context) ([]string, error) {
+	if o.APIKey == "" {
+		return nil, fmt.Errorf("OpenRouter %w. Use 'zcode config set openrouter <key>' or set OPENROUTER_API_KEY", ErrMissingAPIKey)
+	}
+	return listModels(ctx, o.client, o.BaseURL, map[string]string{"Authorization": "Bearer " + o.APIKey})
+}
+
+// Validate checks the API key and that the model is listed by OpenRouter
+func (o *OpenRouter) Validate(ctx context.Context) error {
+	models, err := o.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	return checkModel(o.Model, models)
+}
+
+// ListModels returns the models the LiteLLM proxy serves. The API key is
+// optional for LiteLLM.
+func (l *LiteLLM) ListModels(ctx context.Context) ([]string, error) {
+	headers := map[string]string{}
+	if l.APIKey != "" {
+		headers["Authorization"] = "Bearer " + l.APIKey
+	}
+	return listModels(ctx, l.client, l.BaseURL, headers)
+}
+
+// Validate checks that the proxy is reachable and serves the model
+func (l *LiteLLM) Validate(ctx context.Context) error {
+	models, err := l.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	return checkModel(l.Model, models)
+}
+
+// ListModels returns the models Anthropic offers
+func (a *Anthropic) ListModels(ctx context.Context) ([]string, error) {
+	if a.APIKey == "" {
+		return nil, fmt.Errorf("Anthropic %w. Use 'zcode config set anthropic <key>' or set ANTHROPIC_API_KEY", ErrMissingAPIKey)
+	}
+	return listModels(ctx, a.client, a.BaseURL, map[string]string{
+		"x-api-key":         a.APIKey,
+		"anthropic-version": "2023-06-01",
+	})
+}
+
+// Validate checks the API key and that the model is listed by Anthropic
+func (a *Anthropic) Validate(ctx context.Context) error {
+	models, err := a.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	return checkModel(a.Model, models)
+}
@@ -0,0 +1,123 @@
+// Package logging sets up Z-CODE's structured log. Records are written as
+// JSON lines to a file, never to the terminal, so logging cannot corrupt
+// the TUI.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvLevel enables logging at the given level: debug, info, warn or
+	// error. Logging is off when neither it nor EnvFile is set.
+	EnvLevel = "ZCODE_LOG_LEVEL"
+
+	// EnvFile overrides the log file. Setting it alone logs at info level.
+	EnvFile = "ZCODE_LOG_FILE"
+
+	// FileName is the log file created in the log directory
+	FileName = "zcode.log"
+
+	// maxHeldWarnings caps the warnings kept while stderr is held
+	maxHeldWarnings = 50
+)
+
+var (
+	mu       sync.Mutex
+	held     bool     // Warnings are kept instead of printed to stderr
+	warnings []string // Warnings kept while held
+)
+
+// Init configures the default slog logger from the environment. dir is
+// used for the log file when EnvFile is not set. The returned function
+// closes the file. When logging is off, records are discarded.
+func Init(dir string) (func() error, error) {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	noop := func() error { return nil }
+
+	levelName := os.Getenv(EnvLevel)
+	path := os.Getenv(EnvFile)
+	if levelName == "" && path == "" {
+		return noop, nil
+	}
+
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		return noop, err
+	}
+	if path == "" {
+		path = filepath.Join(dir, FileName)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return noop, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return noop, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	slog.SetDefault(New(file, level))
+	return file.Close, nil
+}
+
+// New returns a JSON logger writing to w at the given level
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLevel converts a level name to a slog level. An empty name is info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid %s %q: use debug, info, warn or error", EnvLevel, name)
+	}
+}
+
+// Warnf logs a warning and prints it to stderr, or keeps it for
+// TakeWarnings while stderr is held
+func Warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	slog.Warn(msg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !held {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+		return
+	}
+	if len(warnings) < maxHeldWarnings {
+		warnings = append(warnings, msg)
+	}
+}
+
+// HoldStderr stops Warnf from printing to stderr. Call it before the TUI
+// takes over the terminal.
+func HoldStderr() {
+	mu.Lock()
+	defer mu.Unlock()
+	held = true
+}
+
+// TakeWarnings returns the warnings kept since HoldStderr and clears them
+func TakeWarnings() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	taken := warnings
+	warnings = nil
+	return taken
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("off by default", func(t *testing.T) {
+		t.Setenv(EnvLevel, "")
+		t.Setenv(EnvFile, "")
+		closeLog, err := Init(dir)
+		if err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		defer closeLog()
+
+		slog.Error("not written")
+		if _, err := os.Stat(filepath.Join(dir, FileName)); !os.IsNotExist(err) {
+			t.Errorf("log file should not be created when logging is off")
+		}
+	})
+
+	t.Run("writes JSON at the configured level", func(t *testing.T) {
+		path := filepath.Join(dir, "custom", "z.log")
+		t.Setenv(EnvLevel, "warn")
+		t.Setenv(EnvFile, path)
+		closeLog, err := Init(dir)
+		if err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		slog.Info("below level")
+		slog.Warn("llm request failed", "provider", "openai", "latency", 42)
+		closeLog()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read log: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("got %d records, want 1:\n%s", len(lines), data)
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+			t.Fatalf("record is not JSON: %v", err)
+		}
+		if record["msg"] != "llm request failed" || record["provider"] != "openai" || record["level"] != "WARN" {
+			t.Errorf("unexpected record: %v", record)
+		}
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		t.Setenv(EnvLevel, "loud")
+		t.Setenv(EnvFile, "")
+		if _, err := Init(dir); err == nil {
+			t.Error("Init() should reject an unknown level")
+		}
+	})
+}
+
+func TestWarnf_HoldStderr(t *testing.T) {
+	HoldStderr()
+	defer func() { held = false }()
+
+	Warnf("failed to load agent from %s", "a.md")
+	Warnf("skipping workflow %s", "loop")
+
+	got := TakeWarnings()
+	if len(got) != 2 || got[0] != "failed to load agent from a.md" {
+		t.Errorf("TakeWarnings() = %v", got)
+	}
+	if again := TakeWarnings(); len(again) != 0 {
+		t.Errorf("TakeWarnings() should clear the warnings, got %v", again)
+	}
+}
@@ -0,0 +1,190 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
+)
+
+const (
+	// MaxProjectContextBytes caps how much project context goes into the
+	// system prompt; the rest is dropped with a warning
+	MaxProjectContextBytes = 16 * 1024
+
+	// maxIncludeDepth limits how deeply @include directives may nest
+	maxIncludeDepth = 5
+
+	// includeDirective starts a line that pulls in another file, relative
+	// to the file containing it and inside the project
+	includeDirective = "@include "
+)
+
+// ProjectContextFiles are the names looked for in the working directory
+// and each of its parents, in order of preference
+var ProjectContextFiles = []string{
+	"ZCODE.md",
+	filepath.Join(".zcode", "rules.md"),
+}
+
+// ProjectContext holds a project's standing instructions for the agent
+type ProjectContext struct {
+	Path     string   // The file that was found
+	Content  string   // Its text with includes expanded, capped in size
+	Warnings []string // Oversized content, missing, blocked or circular includes
+
+	root    string          // The project directory includes are confined to
+	matcher *ignore.Matcher // Blocks includes of ignored files
+}
+
+// FindProjectContext returns the nearest project context file, searching
+// dir and then each parent directory, or "" if there is none
+func FindProjectContext(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range ProjectContextFiles {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectContext finds and reads the project context file for dir.
+// It returns nil when there is none.
+func LoadProjectContext(dir string) (*ProjectContext, error) {
+	path := FindProjectContext(dir)
+	if path == "" {
+		return nil, nil
+	}
+
+	pc := &ProjectContext{Path: path, root: projectRoot(path)}
+	matcher, err := ignore.NewMatcher(pc.root)
+	if err != nil {
+		return nil, err
+	}
+	pc.matcher = matcher
+
+	content, err := pc.expand(path, 0, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	content = strings.TrimSpace(content)
+
+	if len(content) > MaxProjectContextBytes {
+		pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s is %d KB; only the first %d KB is used", path, len(content)/1024, MaxProjectContextBytes/1024))
+		content = truncateUTF8(content, MaxProjectContextBytes)
+	}
+	pc.Content = content
+	return pc, nil
+}
+
+// expand reads path and replaces its @include lines with the included
+// files. seen holds the files on the current include chain.
+func (pc *ProjectContext) expand(path string, depth int, seen map[string]bool) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	seen[path] = true
+	defer delete(seen, path)
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		target, ok := strings.CutPrefix(strings.TrimSpace(line), includeDirective)
+		if !ok {
+			continue
+		}
+
+		target = strings.TrimSpace(target)
+		if filepath.IsAbs(target) {
+			pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s: skipped %s, includes must be relative paths", path, target))
+			lines[i] = ""
+			continue
+		}
+		target = filepath.Join(filepath.Dir(path), target)
+
+		switch {
+		case seen[target]:
+			pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s: skipped circular include of %s", path, target))
+			lines[i] = ""
+		case depth+1 > maxIncludeDepth:
+			pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s: skipped %s, includes nest more than %d deep", path, target, maxIncludeDepth))
+			lines[i] = ""
+		default:
+			if err := pc.checkInclude(target); err != nil {
+				pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s: skipped %s: %v", path, target, err))
+				lines[i] = ""
+				continue
+			}
+			included, err := pc.expand(target, depth+1, seen)
+			if err != nil {
+				pc.Warnings = append(pc.Warnings, fmt.Sprintf("%s: cannot include %s: %v", path, target, err))
+				lines[i] = ""
+				continue
+			}
+			lines[i] = strings.TrimSpace(included)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// projectRoot returns the directory a project context file belongs to:
+// the one containing ZCODE.md, or the parent of .zcode
+func projectRoot(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == ".zcode" {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// checkInclude rejects an included file that is outside the project,
+// directly or through a symlink, or that the ignore files block. The
+// content goes to the provider in the system prompt, so a cloned
+// repository must not be able to pull in ~/.ssh or .env.
+func (pc *ProjectContext) checkInclude(target string) error {
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(pc.root)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range [][2]string{{pc.root, target}, {root, resolved}} {
+		rel, err := filepath.Rel(p[0], p[1])
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("it is outside the project directory %s", pc.root)
+		}
+		if err := pc.matcher.ValidatePath(rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
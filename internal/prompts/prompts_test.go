@@ -0,0 +1,315 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadProjectContext(t *testing.T) {
+	// ZCODE.md includes 1.md, which includes 2.md, and so on
+	chain := map[string]string{"ZCODE.md": "@include 1.md"}
+	for i := 1; i <= maxIncludeDepth+1; i++ {
+		chain[fmt.Sprintf("%d.md", i)] = fmt.Sprintf("level %d\n@include %d.md", i, i+1)
+	}
+
+	tests := []struct {
+		name        string
+		files       map[string]string // Relative to the project directory
+		symlinks    map[string]string // Link name to target, relative to the project
+		wantContent []string
+		notContent  []string
+		wantWarning string
+	}{
+		{
+			name:        "include",
+			files:       map[string]string{"ZCODE.md": "Use tabs.\n@include docs/style.md", "docs/style.md": "Wrap at 100."},
+			wantContent: []string{"Use tabs.\nWrap at 100."},
+		},
+		{
+			name:        "rules file includes from the project root",
+			files:       map[string]string{".zcode/rules.md": "@include ../docs/style.md", "docs/style.md": "Wrap at 100."},
+			wantContent: []string{"Wrap at 100."},
+		},
+		{
+			name:        "circular include",
+			files:       map[string]string{"ZCODE.md": "A\n@include b.md", "b.md": "B\n@include ZCODE.md"},
+			wantContent: []string{"A\nB"},
+			wantWarning: "circular include",
+		},
+		{
+			name:        "includes nested too deep",
+			files:       chain,
+			wantContent: []string{"level 5"},
+			notContent:  []string{"level 6"},
+			wantWarning: "nest more than 5 deep",
+		},
+		{
+			name:        "missing include",
+			files:       map[string]string{"ZCODE.md": "A\n@include missing.md"},
+			wantContent: []string{"A"},
+			wantWarning: "missing.md",
+		},
+		{
+			name:        "absolute include",
+			files:       map[string]string{"ZCODE.md": "A\n@include " + filepath.Join(os.TempDir(), "notes.md")},
+			wantContent: []string{"A"},
+			wantWarning: "must be relative paths",
+		},
+		{
+			name:        "include outside the project",
+			files:       map[string]string{"ZCODE.md": "A\n@include ../outside.txt"},
+			notContent:  []string{"outside secret"},
+			wantWarning: "outside the project directory",
+		},
+		{
+			name:        "symlink out of the project",
+			files:       map[string]string{"ZCODE.md": "A\n@include docs/link.md"},
+			symlinks:    map[string]string{"docs/link.md": "../../outside.txt"},
+			notContent:  []string{"outside secret"},
+			wantWarning: "outside the project directory",
+		},
+		{
+			name:        "ignored include",
+			files:       map[string]string{"ZCODE.md": "A\n@include notes/private.md\n@include .env", ".zcodeignore": "notes/", "notes/private.md": "private", ".env": "TOKEN=1"},
+			notContent:  []string{"private", "TOKEN"},
+			wantWarning: "private.md",
+		},
+		{
+			name:        "oversized content",
+			files:       map[string]string{"ZCODE.md": strings.Repeat("x", MaxProjectContextBytes+100)},
+			wantWarning: "only the first 16 KB is used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "outside.txt"), []byte("outside secret"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			project := filepath.Join(dir, "project")
+			for name, content := range tt.files {
+				path := filepath.Join(project, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, target := range tt.symlinks {
+				path := filepath.Join(project, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(target, path); err != nil {
+					if runtime.GOOS == "windows" {
+						t.Skip("symlinks need extra privileges on windows")
+					}
+					t.Fatal(err)
+				}
+			}
+
+			pc, err := LoadProjectContext(project)
+			if err != nil {
+				t.Fatalf("LoadProjectContext() error = %v", err)
+			}
+			if pc == nil {
+				t.Fatal("LoadProjectContext() = nil, want a context")
+			}
+			if len(pc.Content) > MaxProjectContextBytes {
+				t.Errorf("Content is %d bytes, want at most %d", len(pc.Content), MaxProjectContextBytes)
+			}
+			for _, want := range tt.wantContent {
+				if !strings.Contains(pc.Content, want) {
+					t.Errorf("Content = %q, want it to contain %q", pc.Content, want)
+				}
+			}
+			for _, unwanted := range tt.notContent {
+				if strings.Contains(pc.Content, unwanted) {
+					t.Errorf("Content = %q, should not contain %q", pc.Content, unwanted)
+				}
+			}
+			warnings := strings.Join(pc.Warnings, "\n")
+			if tt.wantWarning == "" && warnings != "" {
+				t.Errorf("Warnings = %q, want none", warnings)
+			}
+			if !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("Warnings = %q, want one mentioning %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestLoadProjectContext_None(t *testing.T) {
+	pc, err := LoadProjectContext(t.TempDir())
+	if pc != nil || err != nil {
+		t.Errorf("LoadProjectContext() = %v, %v, want nil, nil", pc, err)
+	}
+}
+
+// testContext is a prompt context that does not depend on the machine
+func testContext() *PromptContext {
+	return &PromptContext{CWD: "/work", OS: "Linux", Shell: "/bin/bash", HomeDir: "/home/dev"}
+}
+
+func TestBuild(t *testing.T) {
+	ctx := testContext()
+	sections := []string{agentRole(ctx), capabilities(ctx), editingFiles(ctx), rules(ctx), systemInfo(ctx), objective(ctx)}
+	sep := "\n\n====\n\n"
+
+	tests := []struct {
+		name        string
+		customRules string
+		customize   func(*PromptBuilder)
+		want        string
+	}{
+		{
+			name: "default components",
+			want: strings.Join(sections, sep),
+		},
+		{
+			name:        "custom rules last",
+			customRules: "Use tabs.",
+			want:        strings.Join(sections, sep) + sep + "USER INSTRUCTIONS\n\nUse tabs.",
+		},
+		{
+			name: "replaced component",
+			customize: func(b *PromptBuilder) {
+				b.WithComponent(ComponentObjective, func(*PromptContext) string { return "GOAL" })
+			},
+			want: strings.Join(append(slices.Clone(sections[:5]), "GOAL"), sep),
+		},
+		{
+			name:      "added component",
+			customize: func(b *PromptBuilder) { b.WithComponent("extra", func(*PromptContext) string { return "EXTRA" }) },
+			want:      strings.Join(append(slices.Clone(sections), "EXTRA"), sep),
+		},
+		{
+			name:      "removed component",
+			customize: func(b *PromptBuilder) { b.RemoveComponent(ComponentEditingFiles) },
+			want:      strings.Join(slices.Delete(slices.Clone(sections), 2, 3), sep),
+		},
+		{
+			name:      "empty component left out",
+			customize: func(b *PromptBuilder) { b.WithComponent(ComponentRules, func(*PromptContext) string { return "" }) },
+			want:      strings.Join(slices.Delete(slices.Clone(sections), 3, 4), sep),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewPromptBuilder(testContext()).WithCustomRules(tt.customRules)
+			if tt.customize != nil {
+				tt.customize(b)
+			}
+			if got := b.Build(); got != tt.want {
+				t.Errorf("Build() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderComponents(t *testing.T) {
+	defaults := NewPromptBuilder(testContext()).ComponentNames()
+
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{"none", nil, defaults, ""},
+		{"one to the front", []string{ComponentObjective}, []string{
+			ComponentObjective, ComponentAgentRole, ComponentCapabilities, ComponentEditingFiles,
+			ComponentRules, ComponentSystemInfo, ComponentGitContext,
+		}, ""},
+		{"several in order", []string{ComponentRules, ComponentAgentRole}, []string{
+			ComponentRules, ComponentAgentRole, ComponentCapabilities, ComponentEditingFiles,
+			ComponentSystemInfo, ComponentGitContext, ComponentObjective,
+		}, ""},
+		{"unknown", []string{ComponentRules, "nope"}, defaults, "unknown prompt component: nope"},
+		{"listed twice", []string{ComponentRules, ComponentRules}, defaults, "listed twice: rules"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewPromptBuilder(testContext())
+			err := b.ReorderComponents(tt.names)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ReorderComponents() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ReorderComponents() error = %v, want %q", err, tt.wantErr)
+			}
+			// A failed reorder leaves the order alone
+			if got := b.ComponentNames(); !slices.Equal(got, tt.want) {
+				t.Errorf("ComponentNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildWithBudget(t *testing.T) {
+	full := EstimateTokens(NewPromptBuilder(testContext()).Build())
+	withoutObjective := EstimateTokens(NewPromptBuilder(testContext()).RemoveComponent(ComponentObjective).Build())
+
+	tests := []struct {
+		name        string
+		budget      int
+		wantDropped []string
+		fits        bool
+	}{
+		{"no limit", 0, nil, true},
+		{"fits", full, nil, true},
+		{"one over", full - 1, []string{ComponentObjective}, true},
+		{"fits without the objective", withoutObjective, []string{ComponentObjective}, true},
+		{"just below that", withoutObjective - 1, []string{ComponentObjective, ComponentEditingFiles}, true},
+		// The git context is empty here, so it is not reported as dropped,
+		// and the prompt stays over budget once nothing is left to drop
+		{"tiny", 1, []string{ComponentObjective, ComponentEditingFiles, ComponentCapabilities}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, dropped := NewPromptBuilder(testContext()).BuildWithBudget(tt.budget)
+			if !slices.Equal(dropped, tt.wantDropped) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+			if tt.fits && tt.budget > 0 && EstimateTokens(prompt) > tt.budget {
+				t.Errorf("prompt is %d tokens, want at most %d", EstimateTokens(prompt), tt.budget)
+			}
+			if slices.Contains(dropped, ComponentObjective) == strings.Contains(prompt, "OBJECTIVE") {
+				t.Errorf("OBJECTIVE in prompt = %v, dropped = %v", strings.Contains(prompt, "OBJECTIVE"), dropped)
+			}
+			// Sections outside the drop order are always kept
+			if !strings.Contains(prompt, "RULES") || !strings.Contains(prompt, "SYSTEM INFORMATION") {
+				t.Error("prompt should keep the rules and system information")
+			}
+		})
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3},
+		{strings.Repeat("x", 400), 100},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -3,20 +3,64 @@
 package prompts
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"runtime"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/simonyos/Z-CODE/internal/config"
 )
 
+// gitLogCount is how many recent commits the git context lists
+const gitLogCount = 5
+
 // PromptContext contains runtime context for prompt generation
 type PromptContext struct {
 	CWD         string
 	OS          string
 	Shell       string
 	HomeDir     string
-	ToolNames   []string // Available tool names
-	CustomRules string   // User-defined rules from config
+	Tools       []ToolInfo // Registered tools, listed under CAPABILITIES
+	CustomRules string     // User-defined rules from config
+	IncludeGit  bool       // Add branch and recent commits (prompt_git_context)
+}
+
+// ToolInfo describes a registered tool for the prompt
+type ToolInfo struct {
+	Name        string
+	Description string
+}
+
+// HasTool reports whether the named tool is available. With no tools set
+// every tool is assumed to be, as in the default agent.
+func (c *PromptContext) HasTool(name string) bool {
+	if len(c.Tools) == 0 {
+		return true
+	}
+	for _, tool := range c.Tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyTool reports whether any of the named tools is available; an
+// empty list always matches
+func (c *PromptContext) hasAnyTool(names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if c.HasTool(name) {
+			return true
+		}
+	}
+	return false
 }
 
 // NewPromptContext creates a context with system defaults
@@ -47,40 +91,116 @@ func NewPromptContext() *PromptContext {
 	}
 
 	return &PromptContext{
-		CWD:     cwd,
-		OS:      osName,
-		Shell:   shell,
-		HomeDir: home,
+		CWD:        cwd,
+		OS:         osName,
+		Shell:      shell,
+		HomeDir:    home,
+		IncludeGit: config.GetPromptGitContext(),
 	}
 }
 
+// ComponentFunc renders one section of the system prompt; an empty result
+// leaves the section out
+type ComponentFunc func(*PromptContext) string
+
+// Names of the built-in prompt components, in their default order
+const (
+	ComponentAgentRole    = "agent_role"
+	ComponentCapabilities = "capabilities"
+	ComponentEditingFiles = "editing_files"
+	ComponentRules        = "rules"
+	ComponentSystemInfo   = "system_info"
+	ComponentGitContext   = "git_context"
+	ComponentObjective    = "objective"
+)
+
+// component is a named section of the system prompt
+type component struct {
+	name   string
+	render ComponentFunc
+}
+
 // PromptBuilder constructs the system prompt from components
 type PromptBuilder struct {
 	ctx        *PromptContext
-	components []func(*PromptContext) string
+	components []component
 }
 
 // NewPromptBuilder creates a new builder with default components
 func NewPromptBuilder(ctx *PromptContext) *PromptBuilder {
 	return &PromptBuilder{
 		ctx: ctx,
-		components: []func(*PromptContext) string{
-			agentRole,
-			capabilities,
-			editingFiles,
-			rules,
-			systemInfo,
-			objective,
+		components: []component{
+			{ComponentAgentRole, agentRole},
+			{ComponentCapabilities, capabilities},
+			{ComponentEditingFiles, editingFiles},
+			{ComponentRules, rules},
+			{ComponentSystemInfo, systemInfo},
+			{ComponentGitContext, gitContext},
+			{ComponentObjective, objective},
 		},
 	}
 }
 
+// BudgetDropOrder lists the components BuildWithBudget drops, one at a
+// time, until the prompt fits. Components not listed are always kept.
+var BudgetDropOrder = []string{
+	ComponentObjective,
+	ComponentEditingFiles,
+	ComponentGitContext,
+	ComponentCapabilities,
+}
+
+// EstimateTokens gives a rough token count for text, at about four
+// characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
 // Build generates the complete system prompt
 func (b *PromptBuilder) Build() string {
-	var sections []string
+	prompt, _ := b.BuildWithBudget(0)
+	return prompt
+}
+
+// BuildWithBudget generates the system prompt, dropping components in
+// BudgetDropOrder while it is estimated at more than budget tokens. It
+// returns the names of the dropped components. A budget of 0 means no
+// limit. The prompt may still exceed the budget once nothing is left to
+// drop.
+func (b *PromptBuilder) BuildWithBudget(budget int) (string, []string) {
+	rendered := make([]string, len(b.components))
+	for i, c := range b.components {
+		rendered[i] = c.render(b.ctx)
+	}
+
+	var dropped []string
+	prompt := b.join(rendered)
+	if budget <= 0 {
+		return prompt, nil
+	}
 
-	for _, component := range b.components {
-		section := component(b.ctx)
+	for _, name := range BudgetDropOrder {
+		if EstimateTokens(prompt) <= budget {
+			break
+		}
+		for i, c := range b.components {
+			if c.name == name && rendered[i] != "" {
+				rendered[i] = ""
+				dropped = append(dropped, name)
+				prompt = b.join(rendered)
+				break
+			}
+		}
+	}
+	return prompt, dropped
+}
+
+// join assembles the rendered components and custom rules, skipping
+// empty sections
+func (b *PromptBuilder) join(rendered []string) string {
+	var sections []string
+	for _, section := range rendered {
 		if section != "" {
 			sections = append(sections, section)
 		}
@@ -94,15 +214,80 @@ func (b *PromptBuilder) Build() string {
 	return strings.Join(sections, "\n\n====\n\n")
 }
 
+// ComponentNames returns the names of the components in build order
+func (b *PromptBuilder) ComponentNames() []string {
+	names := make([]string, len(b.components))
+	for i, c := range b.components {
+		names[i] = c.name
+	}
+	return names
+}
+
+// WithComponent replaces the component with the given name, or appends a
+// new one after the existing components
+func (b *PromptBuilder) WithComponent(name string, fn ComponentFunc) *PromptBuilder {
+	for i := range b.components {
+		if b.components[i].name == name {
+			b.components[i].render = fn
+			return b
+		}
+	}
+	b.components = append(b.components, component{name, fn})
+	return b
+}
+
+// RemoveComponent drops the component with the given name, e.g.
+// editing_files for small-context models. Unknown names are ignored.
+func (b *PromptBuilder) RemoveComponent(name string) *PromptBuilder {
+	for i := range b.components {
+		if b.components[i].name == name {
+			b.components = append(b.components[:i], b.components[i+1:]...)
+			break
+		}
+	}
+	return b
+}
+
+// ReorderComponents moves the named components to the front, in the given
+// order. Components not named keep their relative order after them.
+func (b *PromptBuilder) ReorderComponents(names []string) error {
+	byName := make(map[string]component, len(b.components))
+	for _, c := range b.components {
+		byName[c.name] = c
+	}
+
+	reordered := make([]component, 0, len(b.components))
+	placed := make(map[string]bool, len(names))
+	for _, name := range names {
+		c, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("unknown prompt component: %s", name)
+		}
+		if placed[name] {
+			return fmt.Errorf("prompt component listed twice: %s", name)
+		}
+		placed[name] = true
+		reordered = append(reordered, c)
+	}
+	for _, c := range b.components {
+		if !placed[c.name] {
+			reordered = append(reordered, c)
+		}
+	}
+
+	b.components = reordered
+	return nil
+}
+
 // WithCustomRules adds user-defined rules
 func (b *PromptBuilder) WithCustomRules(rules string) *PromptBuilder {
 	b.ctx.CustomRules = rules
 	return b
 }
 
-// WithTools sets the available tool names for capability descriptions
-func (b *PromptBuilder) WithTools(tools []string) *PromptBuilder {
-	b.ctx.ToolNames = tools
+// WithTools sets the registered tools the capabilities and rules describe
+func (b *PromptBuilder) WithTools(tools []ToolInfo) *PromptBuilder {
+	b.ctx.Tools = tools
 	return b
 }
 
@@ -117,13 +302,32 @@ func agentRole(ctx *PromptContext) string {
 
 // capabilities describes what the agent can do with its tools
 func capabilities(ctx *PromptContext) string {
-	return fmt.Sprintf(`CAPABILITIES
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`CAPABILITIES
+
+- You have access to tools that let you work on the user's computer, such as reading, searching and editing files and running commands. These tools help you effectively accomplish a wide range of tasks, such as writing code, making edits or improvements to existing files, understanding the current state of a project, performing system operations, and much more.
+- When the user initially gives you a task, a recursive list of all filepaths in the current working directory ('%s') will be included in environment_details. This provides an overview of the project's file structure, offering key insights into the project from directory/file names (how developers conceptualize and organize their code) and file extensions (the language used). This can also guide decision-making on which files to explore further.`, ctx.CWD))
+
+	if len(ctx.Tools) > 0 {
+		sb.WriteString("\n- The tools available to you are:")
+		for _, tool := range ctx.Tools {
+			sb.WriteString(fmt.Sprintf("\n  - %s: %s", tool.Name, firstSentence(tool.Description)))
+		}
+	}
+	if ctx.HasTool("run_command") {
+		sb.WriteString("\n- When you need to execute a CLI command with run_command, you must provide a clear explanation of what the command does. Prefer to execute complex CLI commands over creating executable scripts, since they are more flexible and easier to run. For command chaining, use && to chain commands.")
+	}
+	return sb.String()
+}
 
-- You have access to tools that let you execute CLI commands on the user's computer, list files, view source code, regex search, read and edit files, and ask follow-up questions. These tools help you effectively accomplish a wide range of tasks, such as writing code, making edits or improvements to existing files, understanding the current state of a project, performing system operations, and much more.
-- When the user initially gives you a task, a recursive list of all filepaths in the current working directory ('%s') will be included in environment_details. This provides an overview of the project's file structure, offering key insights into the project from directory/file names (how developers conceptualize and organize their code) and file extensions (the language used). This can also guide decision-making on which files to explore further.
-- You can use the glob tool to find files matching patterns (e.g., "**/*.go" for all Go files). This is useful for discovering project structure and finding relevant files.
-- You can use the grep tool to perform regex searches across files in a specified directory, outputting context-rich results that include surrounding lines. This is particularly useful for understanding code patterns, finding specific implementations, or identifying areas that need refactoring.
-- You can use the run_command tool to run commands on the user's computer whenever you feel it can help accomplish the user's task. When you need to execute a CLI command, you must provide a clear explanation of what the command does. Prefer to execute complex CLI commands over creating executable scripts, since they are more flexible and easier to run. For command chaining, use && to chain commands.`, ctx.CWD)
+// firstSentence returns the first line of a description, cut after its
+// first sentence
+func firstSentence(text string) string {
+	text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
 }
 
 // editingFiles provides guidance on file modification strategies
@@ -186,29 +390,49 @@ You have access to two tools for working with files: **write_file** and **edit_f
 5. ALWAYS read a file before editing it to understand the current content and ensure your old_string matches exactly.`
 }
 
+// rule is one line of the RULES section. Rules naming tools apply only
+// when one of those tools is available; {cwd} is replaced with the working
+// directory.
+type rule struct {
+	tools []string
+	text  string
+}
+
+// ruleList holds the RULES section in order
+var ruleList = []rule{
+	{nil, `Your current working directory is: {cwd}`},
+	{nil, `You cannot 'cd' into a different directory to complete a task. You are stuck operating from '{cwd}', so be sure to pass in the correct 'path' parameter when using tools that require a path.`},
+	{nil, `Do not use the ~ character or $HOME to refer to the home directory. Always use absolute paths.`},
+	{[]string{"run_command"}, `Before using the run_command tool, consider the user's operating system and shell to ensure your commands are compatible. If you need to run a command in a different directory, prepend with 'cd <path> && <command>' (as one command since you are stuck operating from '{cwd}').`},
+	{[]string{"grep"}, `When using the grep tool, craft your regex patterns carefully to balance specificity and flexibility. Use it to find code patterns, TODO comments, function definitions, or any text-based information across the project. Leverage grep in combination with other tools for more comprehensive analysis.`},
+	{[]string{"write_file"}, `When creating a new project, organize all new files within a dedicated project directory unless the user specifies otherwise. Use appropriate file paths when creating files, as the write_file tool will automatically create any necessary directories.`},
+	{nil, `Be sure to consider the type of project (e.g. Python, JavaScript, Go, web application) when determining the appropriate structure and files to include. Also consider what files may be most relevant to accomplishing the task.`},
+	{nil, `When making changes to code, always consider the context in which the code is being used. Ensure that your changes are compatible with the existing codebase and that they follow the project's coding standards and best practices.`},
+	{[]string{"edit_file", "write_file"}, `When you want to modify a file, use the edit_file or write_file tool directly with the desired changes. You do not need to display the changes before using the tool.`},
+	{nil, `Do not ask for more information than necessary. Use the tools provided to accomplish the user's request efficiently and effectively.`},
+	{nil, `You are allowed to ask the user questions when you need additional details to complete a task. Use clear and concise questions that will help you move forward. However, if you can use the available tools to avoid having to ask the user questions, you should do so. For example, if the user mentions a file, use glob or list_dir to find it rather than asking for the path.`},
+	{[]string{"run_command"}, `When executing commands, if you don't see the expected output, assume the terminal executed the command successfully and proceed with the task. The terminal may be unable to stream the output back properly.`},
+	{[]string{"read_file"}, `The user may provide a file's contents directly in their message, in which case you shouldn't use the read_file tool to get the file contents again since you already have it.`},
+	{nil, `Your goal is to try to accomplish the user's task, NOT engage in a back and forth conversation.`},
+	{nil, `You are STRICTLY FORBIDDEN from starting your messages with "Great", "Certainly", "Okay", "Sure". You should NOT be conversational in your responses, but rather direct and to the point. For example you should NOT say "Great, I've updated the CSS" but instead something like "I've updated the CSS". It is important you be clear and technical in your messages.`},
+	{nil, `When presented with images, utilize your vision capabilities to thoroughly examine them and extract meaningful information. Incorporate these insights into your thought process as you accomplish the user's task.`},
+	{nil, `It is critical you wait for the tool results after each tool use, in order to confirm the success of the tool use. For example, if asked to make a todo app, you would create a file, wait for confirmation it was created successfully, then create another file if needed, wait for confirmation, etc.`},
+	{nil, `You can call multiple tools in parallel when they are independent operations. This improves efficiency. But ensure you wait for all results before proceeding.`},
+	{nil, `NEVER end your response with a question or request to engage in further conversation! Formulate the end of your result in a way that is final and does not require further input from the user unless you genuinely need clarification to proceed.`},
+}
+
 // rules defines behavioral constraints and guidelines
 func rules(ctx *PromptContext) string {
-	return fmt.Sprintf(`RULES
-
-- Your current working directory is: %s
-- You cannot 'cd' into a different directory to complete a task. You are stuck operating from '%s', so be sure to pass in the correct 'path' parameter when using tools that require a path.
-- Do not use the ~ character or $HOME to refer to the home directory. Always use absolute paths.
-- Before using the run_command tool, consider the user's operating system and shell to ensure your commands are compatible. If you need to run a command in a different directory, prepend with 'cd <path> && <command>' (as one command since you are stuck operating from '%s').
-- When using the grep tool, craft your regex patterns carefully to balance specificity and flexibility. Use it to find code patterns, TODO comments, function definitions, or any text-based information across the project. Leverage grep in combination with other tools for more comprehensive analysis.
-- When creating a new project, organize all new files within a dedicated project directory unless the user specifies otherwise. Use appropriate file paths when creating files, as the write_file tool will automatically create any necessary directories.
-- Be sure to consider the type of project (e.g. Python, JavaScript, Go, web application) when determining the appropriate structure and files to include. Also consider what files may be most relevant to accomplishing the task.
-- When making changes to code, always consider the context in which the code is being used. Ensure that your changes are compatible with the existing codebase and that they follow the project's coding standards and best practices.
-- When you want to modify a file, use the edit_file or write_file tool directly with the desired changes. You do not need to display the changes before using the tool.
-- Do not ask for more information than necessary. Use the tools provided to accomplish the user's request efficiently and effectively.
-- You are allowed to ask the user questions when you need additional details to complete a task. Use clear and concise questions that will help you move forward. However, if you can use the available tools to avoid having to ask the user questions, you should do so. For example, if the user mentions a file, use glob or list_dir to find it rather than asking for the path.
-- When executing commands, if you don't see the expected output, assume the terminal executed the command successfully and proceed with the task. The terminal may be unable to stream the output back properly.
-- The user may provide a file's contents directly in their message, in which case you shouldn't use the read_file tool to get the file contents again since you already have it.
-- Your goal is to try to accomplish the user's task, NOT engage in a back and forth conversation.
-- You are STRICTLY FORBIDDEN from starting your messages with "Great", "Certainly", "Okay", "Sure". You should NOT be conversational in your responses, but rather direct and to the point. For example you should NOT say "Great, I've updated the CSS" but instead something like "I've updated the CSS". It is important you be clear and technical in your messages.
-- When presented with images, utilize your vision capabilities to thoroughly examine them and extract meaningful information. Incorporate these insights into your thought process as you accomplish the user's task.
-- It is critical you wait for the tool results after each tool use, in order to confirm the success of the tool use. For example, if asked to make a todo app, you would create a file, wait for confirmation it was created successfully, then create another file if needed, wait for confirmation, etc.
-- You can call multiple tools in parallel when they are independent operations. This improves efficiency. But ensure you wait for all results before proceeding.
-- NEVER end your response with a question or request to engage in further conversation! Formulate the end of your result in a way that is final and does not require further input from the user unless you genuinely need clarification to proceed.`, ctx.CWD, ctx.CWD, ctx.CWD)
+	var sb strings.Builder
+	sb.WriteString("RULES\n")
+	for _, r := range ruleList {
+		if !ctx.hasAnyTool(r.tools) {
+			continue
+		}
+		sb.WriteString("\n- ")
+		sb.WriteString(strings.ReplaceAll(r.text, "{cwd}", ctx.CWD))
+	}
+	return sb.String()
 }
 
 // systemInfo provides environment details
@@ -221,30 +445,80 @@ Home Directory: %s
 Current Working Directory: %s`, ctx.OS, ctx.Shell, ctx.HomeDir, ctx.CWD)
 }
 
+// gitContext lists the current branch and recent commits when enabled.
+// It is omitted outside a git repository or if git is unavailable.
+func gitContext(ctx *PromptContext) string {
+	if !ctx.IncludeGit {
+		return ""
+	}
+
+	branch := runGit(ctx.CWD, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "" {
+		return ""
+	}
+	commits := runGit(ctx.CWD, "log", fmt.Sprintf("-n%d", gitLogCount), "--pretty=format:- %s")
+
+	var sb strings.Builder
+	sb.WriteString("GIT CONTEXT\n\n")
+	sb.WriteString(fmt.Sprintf("Current Branch: %s", branch))
+	if commits != "" {
+		sb.WriteString("\n\nRecent Commits:\n")
+		sb.WriteString(commits)
+	}
+	return sb.String()
+}
+
+// runGit runs a git command in dir and returns its trimmed output, or ""
+// on any failure
+func runGit(dir string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
 // objective describes the iterative workflow approach
 func objective(ctx *PromptContext) string {
 	return `OBJECTIVE
 
 You accomplish a given task iteratively, breaking it down into clear steps and working through them methodically.
 
-1. Analyze the user's task and set clear, achievable goals to accomplish it. Prioritize these goals in a logical order.
-2. Work through these goals sequentially, utilizing available tools one at a time as necessary. Each goal should correspond to a distinct step in your problem-solving process. You will be informed on the work completed and what's remaining as you go.
+1. Analyze the user's task and set clear, achievable goals to accomplish it. Prioritize these goals in a logical order. For work with more than a couple of steps, record the goals with the todo tool before you begin.
+2. Work through these goals sequentially, utilizing available tools one at a time as necessary. Each goal should correspond to a distinct step in your problem-solving process. You will be informed on the work completed and what's remaining as you go. Mark each todo item complete as soon as it is done so the remaining list stays accurate.
 3. Remember, you have extensive capabilities with access to a wide range of tools that can be used in powerful and clever ways as necessary to accomplish each goal. Before calling a tool, think about which of the provided tools is the most relevant to accomplish the user's task. Consider the required parameters and determine if the user has provided enough information to infer values. If a required parameter is missing and cannot be inferred, ask the user to provide it.
 4. Once you've completed the user's task, present the result clearly. You may also provide a CLI command to showcase the result of your task if appropriate.
 5. The user may provide feedback, which you can use to make improvements and try again. But DO NOT continue in pointless back and forth conversations, i.e. don't end your responses with questions or offers for further assistance.`
 }
 
-// BuildSystemPrompt is a convenience function that builds a prompt with default settings
-func BuildSystemPrompt() string {
-	ctx := NewPromptContext()
-	builder := NewPromptBuilder(ctx)
-	return builder.Build()
+// BuildSystemPrompt is a convenience function that builds a prompt with default settings,
+// the given tools and the project's ZCODE.md, if any. It also returns the
+// sections dropped to fit prompt_token_budget.
+func BuildSystemPrompt(tools []ToolInfo) (string, []string) {
+	return BuildSystemPromptWithRules(tools, "")
 }
 
-// BuildSystemPromptWithRules builds a prompt with custom user rules
-func BuildSystemPromptWithRules(customRules string) string {
+// BuildSystemPromptWithRules builds a prompt with the project's ZCODE.md
+// followed by custom user rules, within the configured prompt_token_budget.
+// It returns the names of the sections dropped to fit, so the caller can
+// tell the user the prompt was trimmed.
+func BuildSystemPromptWithRules(tools []ToolInfo, customRules string) (string, []string) {
 	ctx := NewPromptContext()
-	builder := NewPromptBuilder(ctx)
-	builder.WithCustomRules(customRules)
-	return builder.Build()
+	builder := NewPromptBuilder(ctx).WithTools(tools)
+
+	var rules []string
+	if project, err := LoadProjectContext(ctx.CWD); err == nil && project != nil && project.Content != "" {
+		rules = append(rules, project.Content)
+	}
+	if customRules != "" {
+		rules = append(rules, customRules)
+	}
+	builder.WithCustomRules(strings.Join(rules, "\n\n"))
+
+	return builder.BuildWithBudget(config.GetPromptTokenBudget())
 }
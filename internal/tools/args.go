@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errTruncatedString reports arguments that end inside a string, as when
+// the response hit its length limit in the middle of a file's content
+var errTruncatedString = errors.New("the arguments end inside a string, probably cut off by the response length limit; " +
+	"nothing was written, so send the content in smaller pieces")
+
+// ParseArguments decodes the JSON arguments of a call to the named tool.
+// Slightly malformed JSON, as models sometimes stream it, is repaired with
+// RepairJSON before giving up, and repaired reports that it was. Arguments
+// cut off inside a string are only repaired for read-only tools: closing
+// the string for a tool that writes would save truncated content. The
+// error returned is from the original text.
+func ParseArguments(tool, raw string) (args map[string]any, repaired bool, err error) {
+	if strings.TrimSpace(raw) == "" {
+		return map[string]any{}, false, nil
+	}
+
+	args, err = decodeArguments(raw)
+	if err == nil {
+		return args, false, nil
+	}
+	fixed, openString := repairJSON(raw)
+	if openString && !ReadOnlyTools[tool] {
+		return nil, false, errTruncatedString
+	}
+	if args, repairErr := decodeArguments(fixed); repairErr == nil {
+		return args, true, nil
+	}
+	return nil, false, err
+}
+
+// decodeArguments decodes a JSON object, unwrapping an object that was
+// encoded a second time as a JSON string
+func decodeArguments(raw string) (map[string]any, error) {
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, err
+	}
+	if s, ok := value.(string); ok {
+		return decodeArguments(s)
+	}
+	args, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("arguments must be a JSON object, got %s", raw)
+	}
+	return args, nil
+}
+
+// RepairJSON fixes the mistakes models commonly make in JSON arguments:
+// surrounding code fences, raw newlines and tabs inside strings, trailing
+// commas, and objects cut off before their closing quotes and brackets.
+// Text it cannot make sense of is returned in a form that still fails to
+// parse.
+func RepairJSON(raw string) string {
+	repaired, _ := repairJSON(raw)
+	return repaired
+}
+
+// repairJSON is RepairJSON, also reporting whether the text ended inside
+// a string that had to be closed
+func repairJSON(raw string) (string, bool) {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+
+	var out strings.Builder
+	var closers []byte // Closing brackets still owed, innermost last
+	inString, escaped := false, false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				out.WriteByte(c)
+			case c == '\\':
+				escaped = true
+				out.WriteByte(c)
+			case c == '"':
+				inString = false
+				out.WriteByte(c)
+			case c == '\n':
+				out.WriteString(`\n`)
+			case c == '\r':
+				out.WriteString(`\r`)
+			case c == '\t':
+				out.WriteString(`\t`)
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			trimTrailingComma(&out)
+			if len(closers) > 0 {
+				closers = closers[:len(closers)-1]
+			}
+		}
+		out.WriteByte(c)
+	}
+
+	// Close what a truncated response left open
+	if inString {
+		if escaped {
+			out.WriteByte('\\')
+		}
+		out.WriteByte('"')
+	}
+	trimTrailingComma(&out)
+	if strings.HasSuffix(strings.TrimRight(out.String(), " \t\r\n"), ":") {
+		out.WriteString("null")
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		out.WriteByte(closers[i])
+	}
+	return out.String(), inString
+}
+
+// trimTrailingComma removes a comma, and the whitespace after it, from the
+// end of the output so far
+func trimTrailingComma(out *strings.Builder) {
+	text := strings.TrimRight(out.String(), " \t\r\n")
+	if strings.HasSuffix(text, ",") {
+		text = text[:len(text)-1]
+		out.Reset()
+		out.WriteString(text)
+	}
+}
@@ -1,24 +1,45 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/logging"
 )
 
+// maxCommandTimeout caps the timeout_seconds a model may request
+const maxCommandTimeout = 10 * time.Minute
+
 // BashTool executes shell commands
 type BashTool struct {
 	BaseTool
 	ConfirmFn ConfirmFunc
 	Timeout   time.Duration
+	Redactor  *Redactor
+	Root      string            // Commands run here and may not reference paths outside it; "" for no confinement
+	Commands  CommandPolicy     // Commands allowed and denied
+	Env       map[string]string // Set for every command; a call's env argument overrides it
 }
 
 // NewBashTool creates a new bash command tool
 func NewBashTool(confirmFn ConfirmFunc) *BashTool {
-	return &BashTool{
+	t := &BashTool{
 		ConfirmFn: confirmFn,
-		Timeout:   30 * time.Second,
+		Timeout:   config.GetCommandTimeout(),
+		Redactor:  DefaultRedactor(),
+		Root:      config.GetCommandRoot(),
+		Commands:  ConfigCommandPolicy(),
+		Env:       config.GetCommandEnv(),
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "run_command",
@@ -30,49 +51,260 @@ func NewBashTool(confirmFn ConfirmFunc) *BashTool {
 							Type:        "string",
 							Description: "The shell command to execute",
 						},
+						"timeout_seconds": {
+							Type:        "integer",
+							Description: "Kill the command after this many seconds (default from config, max 600). Raise it for slow commands like installs or builds.",
+						},
+						"env": {
+							Type:        "object",
+							Description: "Environment variables for this command only, e.g. {\"CGO_ENABLED\": \"0\"}. They are added to the inherited environment.",
+						},
 					},
 					Required: []string{"command"},
 				},
 			},
 		},
 	}
+	if t.Root != "" {
+		t.Def.Description += fmt.Sprintf(". Commands run in %s and may not reference paths outside it.", t.Root)
+	}
+	if len(t.Commands.Allow) > 0 {
+		t.Def.Description += fmt.Sprintf(" Only these commands are allowed: %s.", strings.Join(t.Commands.Allow, ", "))
+	}
+	if len(t.Env) > 0 {
+		t.Def.Description += fmt.Sprintf(" Commands run with %s set.", strings.Join(slices.Sorted(maps.Keys(t.Env)), ", "))
+	}
+	return t
 }
 
 // Execute runs the shell command
 func (t *BashTool) Execute(ctx context.Context, args map[string]any) ToolResult {
 	command, _ := args["command"].(string)
 
+	if err := checkCommand(command, t.Commands, t.Root); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	env, err := commandEnv(t.Env, args)
+	if err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+	display := t.displayCommand(command, env)
+
+	// Confirmation may be automatic, so the warning is also printed and
+	// kept in the result
+	risk := riskyCommand(command)
+	if risk != "" {
+		logging.Warnf("running %s, which %s", display, risk)
+	}
+
 	// Ask for confirmation if a confirm function is provided
 	if t.ConfirmFn != nil {
-		prompt := fmt.Sprintf("Run command: %s", command)
-		if !t.ConfirmFn(prompt) {
+		prompt := fmt.Sprintf("Run command: %s", display)
+		if risk != "" {
+			prompt += "\nWarning: this command " + risk
+		}
+		if !t.ConfirmFn(ConfirmRequest{Tool: t.Def.Name, Prompt: prompt}) {
 			return ToolResult{Success: false, Error: "user denied command execution"}
 		}
 	}
 
+	timeout, err := commandTimeout(args, t.Timeout)
+	if err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	result := redactResult(t.Redactor, runShell(ctx, shellCommand{command: command, dir: t.Root, env: environ(env), timeout: timeout}))
+	if risk != "" {
+		result.Output = "Warning: this command " + risk + "\n" + result.Output
+	}
+	return result
+}
+
+// commandEnv merges the env argument of a call over the tool's default
+// environment variables
+func commandEnv(defaults map[string]string, args map[string]any) (map[string]string, error) {
+	raw, ok := args["env"]
+	if !ok || raw == nil {
+		return defaults, nil
+	}
+	vars, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.New("env must be an object mapping variable names to values")
+	}
+
+	env := maps.Clone(defaults)
+	if env == nil {
+		env = make(map[string]string, len(vars))
+	}
+	for name, value := range vars {
+		if !config.ValidEnvName(name) {
+			return nil, fmt.Errorf("invalid environment variable name: %q", name)
+		}
+		if config.ProtectedEnvName(name) {
+			return nil, fmt.Errorf("env cannot set %s: it changes which programs run, so only the global command_env may set it", name)
+		}
+		switch value.(type) {
+		case string, float64, bool:
+			env[name] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("env value for %s must be a string", name)
+		}
+	}
+	return env, nil
+}
+
+// environ returns the inherited environment with env added, or nil to
+// inherit it unchanged. Later entries win, so env overrides inherited
+// values.
+func environ(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	vars := os.Environ()
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		vars = append(vars, name+"="+env[name])
+	}
+	return vars
+}
+
+// displayCommand shows a command with its environment variables as
+// NAME=value prefixes, for the confirmation prompt and logs. Values of
+// variables named like secrets, and secrets in the command itself, are
+// redacted.
+func (t *BashTool) displayCommand(command string, env map[string]string) string {
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		value := env[name]
+		switch {
+		case config.IsSecretName(name):
+			value = "[REDACTED]"
+		case value == "" || strings.ContainsAny(value, " \t\n'\"$`\\"):
+			value = fmt.Sprintf("%q", value)
+		}
+		sb.WriteString(name + "=" + value + " ")
+	}
+	sb.WriteString(command)
+	display, _ := t.Redactor.Redact(sb.String())
+	return display
+}
+
+// shellCommand describes one sh -c invocation
+type shellCommand struct {
+	command   string
+	wrapper   []string // Command the shell runs under, e.g. unshare; may be nil
+	dir       string   // Working directory; empty means the current one
+	env       []string // Environment; nil inherits the current one
+	timeout   time.Duration
+	maxOutput int // Bytes of output kept for the result; 0 means unlimited
+}
+
+// commandTimeout reads timeout_seconds from args, falling back to def
+func commandTimeout(args map[string]any, def time.Duration) (time.Duration, error) {
+	seconds, ok := intArg(args, "timeout_seconds")
+	if !ok {
+		return def, nil
+	}
+	if seconds <= 0 {
+		return 0, errors.New("timeout_seconds must be positive")
+	}
+	return min(time.Duration(seconds)*time.Second, maxCommandTimeout), nil
+}
+
+// runShell runs a command through sh, streaming its output to any
+// OutputFunc in ctx and killing its process group on timeout
+func runShell(ctx context.Context, sc shellCommand) ToolResult {
 	// Create context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	execCtx, cancel := context.WithTimeout(ctx, sc.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(execCtx, "sh", "-c", command)
-	output, err := cmd.CombinedOutput()
+	argv := append(append([]string{}, sc.wrapper...), "sh", "-c", sc.command)
+	cmd := exec.CommandContext(execCtx, argv[0], argv[1:]...)
+	cmd.Dir = sc.dir
+	cmd.Env = sc.env
+
+	// Run in its own process group so the whole tree is killed on expiry
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// Don't wait forever on pipes held open by orphaned grandchildren
+	cmd.WaitDelay = 2 * time.Second
+
+	// Stream output as it arrives while keeping the combined copy
+	output := &streamBuffer{onWrite: OutputFuncFrom(ctx), limit: sc.maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
 
 	if execCtx.Err() == context.DeadlineExceeded {
-		return ToolResult{Success: false, Error: "command timed out"}
+		return ToolResult{
+			Success: false,
+			Output:  output.String(),
+			Error:   fmt.Sprintf("command timed out after %s and was killed", sc.timeout),
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return ToolResult{
+			Success: false,
+			Output:  output.String(),
+			Error:   fmt.Sprintf("command exited with status %d", exitErr.ExitCode()),
+		}
 	}
 
 	if err != nil {
 		return ToolResult{
 			Success: false,
-			Output:  string(output),
+			Output:  output.String(),
 			Error:   err.Error(),
 		}
 	}
 
-	result := string(output)
+	result := output.String()
 	if result == "" {
 		result = "(no output)"
 	}
 
 	return ToolResult{Success: true, Output: result}
 }
+
+// streamBuffer collects command output and forwards each write to an
+// optional callback. stdout and stderr share it, so writes are serialized.
+// With a limit set, output past the limit is streamed but not kept.
+type streamBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	onWrite OutputFunc
+	limit   int
+	dropped int
+}
+
+func (b *streamBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keep := p
+	if b.limit > 0 {
+		room := max(b.limit-b.buf.Len(), 0)
+		if len(keep) > room {
+			b.dropped += len(keep) - room
+			keep = keep[:room]
+		}
+	}
+	b.buf.Write(keep)
+	if b.onWrite != nil {
+		b.onWrite(string(p))
+	}
+	return len(p), nil
+}
+
+func (b *streamBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dropped > 0 {
+		return b.buf.String() + fmt.Sprintf("\n... (output truncated, %d more bytes)", b.dropped)
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// binarySniffLen is how much of a file is examined to decide whether it
+// is binary, as git does
+const binarySniffLen = 8000
+
+// executableMagic identifies compiled binaries, which content sniffing
+// reports only as application/octet-stream
+var executableMagic = []struct {
+	prefix []byte
+	kind   string
+}{
+	{[]byte("\x7fELF"), "ELF executable or object"},
+	{[]byte("\xcf\xfa\xed\xfe"), "Mach-O executable"},
+	{[]byte("\xce\xfa\xed\xfe"), "Mach-O executable"},
+	{[]byte("\xca\xfe\xba\xbe"), "Mach-O universal binary or Java class"},
+	{[]byte("MZ"), "Windows executable"},
+	{[]byte("\x00asm"), "WebAssembly module"},
+}
+
+// looksBinary reports whether content is binary rather than text: it
+// contains a NUL byte, or more than a tenth of it is not valid UTF-8.
+// The tolerance keeps Latin-1 text with the odd accented letter readable.
+func looksBinary(content []byte) bool {
+	sample := content[:min(len(content), binarySniffLen)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+
+	invalid := 0
+	for i := 0; i < len(sample); {
+		if !utf8.FullRune(sample[i:]) {
+			break // Cut off by the end of the sample
+		}
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		i += size
+	}
+	return invalid*10 > len(sample)
+}
+
+// binaryKind names the type of binary content and reports whether it is
+// an image
+func binaryKind(content []byte) (kind string, image bool) {
+	for _, m := range executableMagic {
+		if bytes.HasPrefix(content, m.prefix) {
+			return m.kind, false
+		}
+	}
+
+	mime, _, _ := strings.Cut(http.DetectContentType(content), ";")
+	if subtype, ok := strings.CutPrefix(mime, "image/"); ok {
+		return strings.ToUpper(strings.TrimPrefix(subtype, "x-")) + " image", true
+	}
+	if mime == "application/octet-stream" {
+		return "binary data", false
+	}
+	return mime, false
+}
+
+// describeBinary explains why a binary file's contents were not returned
+func describeBinary(path string, content []byte) string {
+	kind, image := binaryKind(content)
+	msg := fmt.Sprintf("%s is a binary file (%s, %s); its contents were not returned.", path, kind, formatSize(int64(len(content))))
+	if image {
+		return msg + " read_file only returns text; to look at an image, ask the user to share it with a vision-capable model."
+	}
+	return msg + " Inspect it with a command such as file, strings or xxd, or pass force: true to read the raw bytes."
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+// AlwaysDeniedCommands are refused whatever the command policy says
+var AlwaysDeniedCommands = []string{"sudo", "su", "doas", "shutdown", "reboot", "halt", "poweroff"}
+
+// CommandPolicy limits which commands run_command may run. Patterns name
+// a program, optionally followed by a subcommand; either may use glob
+// wildcards, so "go" and "git *" allow every go and git command while
+// "git st*" allows only git status and git stash.
+type CommandPolicy struct {
+	Allow []string // When set, every command must match one of these
+	Deny  []string // Commands matching any of these are refused
+}
+
+// ConfigCommandPolicy returns the policy set by the command_allow and
+// command_deny config keys
+func ConfigCommandPolicy() CommandPolicy {
+	return CommandPolicy{Allow: config.GetCommandAllow(), Deny: config.GetCommandDeny()}
+}
+
+// commandSeparators split a command line into the simple commands it runs
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]|\$\(|` + "`")
+
+// fdRedirects are redirections using & that are not command separators,
+// such as 2>&1 and &>file
+var fdRedirects = regexp.MustCompile(`[0-9]*[<>]&[0-9-]*|&>>?`)
+
+// Check returns an error if any command in a command line is not allowed.
+// Chained and piped commands are checked one by one, and so are the
+// commands run through wrappers such as env, nohup, xargs and sh -c. Like
+// the confinement check it reads the command text and is not a sandbox.
+func (p CommandPolicy) Check(command string) error {
+	command = fdRedirects.ReplaceAllString(command, " > ")
+	for _, part := range commandSeparators.Split(command, -1) {
+		for _, cmd := range commandChain(part) {
+			if err := p.check(strings.TrimSpace(part), cmd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check applies the policy to one program of a simple command
+func (p CommandPolicy) check(part string, cmd simpleCommand) error {
+	for _, denied := range AlwaysDeniedCommands {
+		if cmd.program == denied {
+			return fmt.Errorf("command not allowed: %s is always denied", cmd.program)
+		}
+	}
+	for _, pattern := range p.Deny {
+		if matchCommand(pattern, cmd.program, cmd.subcommand) {
+			return fmt.Errorf("command not allowed: %q matches command_deny pattern %q", part, pattern)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matchCommand(pattern, cmd.program, cmd.subcommand) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command not allowed: %q does not match command_allow (%s)", part, strings.Join(p.Allow, ", "))
+}
+
+// simpleCommand is a program and its first argument
+type simpleCommand struct {
+	program    string
+	subcommand string
+}
+
+// commandWrapper describes a command that runs the command given in its
+// arguments
+type commandWrapper struct {
+	valueFlags []string // Flags followed by a separate value
+	operands   int      // Arguments before the command, e.g. timeout's duration
+}
+
+// commandWrappers run the command that follows their own flags and operands
+var commandWrappers = map[string]commandWrapper{
+	"builtin": {},
+	"command": {},
+	"env":     {valueFlags: []string{"-u", "--unset", "-C", "--chdir"}},
+	"eval":    {},
+	"exec":    {valueFlags: []string{"-a"}},
+	"ionice":  {valueFlags: []string{"-c", "--class", "-n", "--classdata"}},
+	"nice":    {valueFlags: []string{"-n", "--adjustment"}},
+	"nohup":   {},
+	"setsid":  {},
+	"stdbuf":  {valueFlags: []string{"-i", "-o", "-e"}},
+	"time":    {},
+	"timeout": {valueFlags: []string{"-s", "--signal", "-k", "--kill-after"}, operands: 1},
+	"xargs": {valueFlags: []string{"-a", "--arg-file", "-d", "--delimiter", "-E", "-I", "-L", "--max-lines",
+		"-n", "--max-args", "-P", "--max-procs", "-s", "--max-chars"}},
+}
+
+// shells run the command string given with -c
+var shells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+
+// commandChain returns the programs a simple command runs: the command
+// itself, then the command each wrapper or sh -c runs in turn. Leading
+// VAR=value assignments are skipped, and programs are reduced to their
+// base name so /usr/bin/sudo counts as sudo.
+func commandChain(command string) []simpleCommand {
+	var words []string
+	for _, word := range strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(command)) {
+		if word != "{" && word != "}" {
+			words = append(words, strings.Trim(word, `'"`))
+		}
+	}
+
+	var chain []simpleCommand
+	for {
+		for len(words) > 0 && strings.Contains(words[0], "=") && !strings.HasPrefix(words[0], "=") {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			return chain
+		}
+		cmd := simpleCommand{program: path.Base(filepath.ToSlash(words[0]))}
+		if len(words) > 1 {
+			cmd.subcommand = words[1]
+		}
+		chain = append(chain, cmd)
+		if words = wrappedCommand(cmd.program, words[1:]); words == nil {
+			return chain
+		}
+	}
+}
+
+// wrappedCommand returns the words of the command a wrapper or shell runs,
+// or nil if program runs no other command
+func wrappedCommand(program string, args []string) []string {
+	if shells[program] {
+		for i, arg := range args {
+			switch {
+			case !strings.HasPrefix(arg, "-"):
+				return nil // A script, not a command string
+			case !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c"):
+				return args[i+1:]
+			}
+		}
+		return nil
+	}
+
+	wrapper, ok := commandWrappers[program]
+	if !ok {
+		return nil
+	}
+	operands := wrapper.operands
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+		case strings.HasPrefix(arg, "-"):
+			if slices.Contains(wrapper.valueFlags, arg) {
+				i++
+			}
+		case operands > 0:
+			operands--
+		default:
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+// matchCommand reports whether a program and subcommand match a pattern
+// such as "go", "git *" or "npm run*"
+func matchCommand(pattern, program, subcommand string) bool {
+	fields := strings.Fields(pattern)
+	if len(fields) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(fields[0], program); !ok {
+		return false
+	}
+	if len(fields) == 1 || fields[1] == "*" {
+		return true
+	}
+	ok, _ := path.Match(fields[1], subcommand)
+	return ok
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// confinedDevices are paths outside the root that confined commands may
+// still use
+var confinedDevices = map[string]bool{
+	"/dev/null":   true,
+	"/dev/stdin":  true,
+	"/dev/stdout": true,
+	"/dev/stderr": true,
+}
+
+// shellSeparators split a command line into words for the confinement
+// check; quotes are dropped so quoted paths are checked too
+var shellSeparators = strings.NewReplacer(
+	";", " ", "|", " ", "&", " ", "<", " ", ">", " ",
+	"(", " ", ")", " ", "`", " ", "'", " ", "\"", " ", "\n", " ",
+)
+
+// checkCommand applies the command policy and, unless root is "", the
+// confinement check. run_command and run_in_sandbox share it.
+func checkCommand(command string, policy CommandPolicy, root string) error {
+	if err := policy.Check(command); err != nil {
+		return err
+	}
+	if root == "" {
+		return nil
+	}
+	return checkConfined(command, root)
+}
+
+// checkConfined rejects commands that reference paths outside root:
+// absolute paths, home directory paths and relative paths climbing out
+// with "..". It is a static check on the command string, not a sandbox;
+// paths built at run time (variables, globs, subshells) get through.
+func checkConfined(command, root string) error {
+	home, _ := os.UserHomeDir()
+	for _, word := range strings.Fields(shellSeparators.Replace(command)) {
+		// Check the value of --flag=path arguments
+		if i := strings.Index(word, "="); i >= 0 {
+			word = word[i+1:]
+		}
+
+		var path string
+		switch {
+		case word == "":
+			continue
+		case word == "~" || strings.HasPrefix(word, "~/"):
+			path = filepath.Join(home, strings.TrimPrefix(word, "~"))
+		case strings.HasPrefix(word, "$HOME") || strings.HasPrefix(word, "${HOME}"):
+			path = filepath.Join(home, strings.TrimPrefix(strings.TrimPrefix(word, "${HOME}"), "$HOME"))
+		case strings.HasPrefix(word, "/") || filepath.IsAbs(word):
+			if confinedDevices[word] {
+				continue
+			}
+			path = filepath.Clean(word)
+		case strings.Contains(word, ".."):
+			path = filepath.Join(root, word)
+		default:
+			continue
+		}
+
+		if !withinRoot(path, root) {
+			return fmt.Errorf("command not allowed: %s is outside the command root %s", word, root)
+		}
+	}
+	return nil
+}
+
+// withinRoot reports whether path is root or inside it
+func withinRoot(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// riskyPatterns flag commands worth a second look before approving them
+var riskyPatterns = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`(^|[\s;&|(])sudo\s`), "runs with root privileges (sudo)"},
+	{regexp.MustCompile(`\brm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z]*\s+)*(/|~|\$HOME)(\s|$|/\*)`), "recursively deletes the filesystem or home directory"},
+	{regexp.MustCompile(`(curl|wget)\b[^|]*\|\s*(sudo\s+)?(ba|z)?sh\b`), "pipes a download into a shell"},
+	{regexp.MustCompile(`\b(mkfs|dd\s+[^|]*of=/dev/)`), "writes to a raw device"},
+	{regexp.MustCompile(`:\(\)\s*\{\s*:\|:&\s*\};:`), "is a fork bomb"},
+	{regexp.MustCompile(`\bchmod\s+(-R\s+)?777\s+/`), "opens permissions on system paths"},
+}
+
+// riskyCommand returns why a command looks dangerous, or "" if it does not
+func riskyCommand(command string) string {
+	var reasons []string
+	for _, p := range riskyPatterns {
+		if p.re.MatchString(command) {
+			reasons = append(reasons, p.reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
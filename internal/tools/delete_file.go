@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
+)
+
+// DeleteFileTool deletes a file or directory
+type DeleteFileTool struct {
+	BaseTool
+	ConfirmFn ConfirmFunc
+	Matcher   *ignore.Matcher
+	Journal   *Journal
+}
+
+// NewDeleteFileTool creates a new delete file tool
+func NewDeleteFileTool(confirmFn ConfirmFunc) *DeleteFileTool {
+	return &DeleteFileTool{
+		ConfirmFn: confirmFn,
+		Matcher:   defaultMatcher(),
+		Journal:   DefaultJournal(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "delete_file",
+				Description: "Delete a file. Directories are only deleted when recursive is true.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"path": {
+							Type:        "string",
+							Description: "The path to the file or directory to delete",
+						},
+						"recursive": {
+							Type:        "boolean",
+							Description: "Set to true to delete a directory and everything in it",
+						},
+					},
+					Required: []string{"path"},
+				},
+			},
+		},
+	}
+}
+
+// Execute deletes the file
+func (t *DeleteFileTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'path' parameter"}
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	if err := validatePath(t.Matcher, path); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to stat path: %v", err)}
+	}
+	if info.IsDir() && !recursive {
+		return ToolResult{Success: false, Error: fmt.Sprintf("%s is a directory; pass recursive: true to delete it", path)}
+	}
+	if info.IsDir() {
+		// Ignored files inside the directory are protected too
+		if err := validateTree(t.Matcher, path, ""); err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
+	// Ask for confirmation if a confirm function is provided
+	if t.ConfirmFn != nil {
+		prompt := fmt.Sprintf("Delete file: %s", path)
+		if info.IsDir() {
+			prompt = fmt.Sprintf("Delete directory recursively: %s", path)
+		}
+		if !t.ConfirmFn(ConfirmRequest{Tool: t.Def.Name, Prompt: prompt, Path: path}) {
+			return ToolResult{Success: false, Error: "user denied delete permission"}
+		}
+	}
+
+	if err := recordUndo(t.Journal, t.Def.Name, path); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	if info.IsDir() {
+		err = os.RemoveAll(path)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to delete: %v", err)}
+	}
+	if t.Matcher != nil {
+		t.Matcher.ClearCache()
+	}
+
+	return ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Successfully deleted %s", path),
+	}
+}
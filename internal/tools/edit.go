@@ -11,12 +11,14 @@ import (
 type EditTool struct {
 	BaseTool
 	ConfirmFn ConfirmFunc
+	Journal   *Journal
 }
 
 // NewEditTool creates a new edit file tool
 func NewEditTool(confirmFn ConfirmFunc) *EditTool {
 	return &EditTool{
 		ConfirmFn: confirmFn,
+		Journal:   DefaultJournal(),
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "edit_file",
@@ -36,6 +38,10 @@ func NewEditTool(confirmFn ConfirmFunc) *EditTool {
 							Type:        "string",
 							Description: "The text to replace old_string with",
 						},
+						"fuzzy": {
+							Type:        "boolean",
+							Description: "If old_string is not found exactly, replace the whole lines of a single very close match (90%+ similar) instead of failing",
+						},
 					},
 					Required: []string{"path", "old_string", "new_string"},
 				},
@@ -59,6 +65,8 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]any) ToolResult
 		return ToolResult{Success: false, Error: "missing or invalid 'new_string' parameter"}
 	}
 
+	fuzzy, _ := args["fuzzy"].(bool)
+
 	// Get file info to preserve permissions
 	fileInfo, err := os.Stat(path)
 	if err != nil {
@@ -74,16 +82,16 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]any) ToolResult
 
 	fileContent := string(content)
 
-	// Check if old_string exists in file
-	count := strings.Count(fileContent, oldString)
-	if count == 0 {
+	// Check if old_string equals new_string
+	if oldString == newString {
 		return ToolResult{
 			Success: false,
-			Error:   fmt.Sprintf("old_string not found in file. Make sure you're using the exact text from the file."),
+			Error:   "old_string and new_string are identical. No changes needed.",
 		}
 	}
 
-	// Check if old_string is unique
+	// Check if old_string exists in file and is unique
+	count := strings.Count(fileContent, oldString)
 	if count > 1 {
 		return ToolResult{
 			Success: false,
@@ -91,26 +99,50 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]any) ToolResult
 		}
 	}
 
-	// Check if old_string equals new_string
-	if oldString == newString {
-		return ToolResult{
-			Success: false,
-			Error:   "old_string and new_string are identical. No changes needed.",
+	var newContent, fuzzyNote string
+	if count == 1 {
+		// Perform the replacement
+		newContent = strings.Replace(fileContent, oldString, newString, 1)
+	} else {
+		fileLines := splitLines(fileContent)
+		matches := findFuzzyMatches(fileLines, splitLines(oldString))
+
+		if !fuzzy || !fuzzyApplicable(matches) {
+			return ToolResult{Success: false, Error: notFoundError(fileLines, matches, fuzzy)}
 		}
+
+		// Replace the matched lines wholesale
+		m := matches[0]
+		var lines []string
+		lines = append(lines, fileLines[:m.start-1]...)
+		lines = append(lines, splitLines(newString)...)
+		lines = append(lines, fileLines[m.end:]...)
+		newContent = strings.Join(lines, "\n")
+		if strings.HasSuffix(fileContent, "\n") {
+			newContent += "\n"
+		}
+		fuzzyNote = fmt.Sprintf(" (fuzzy match at lines %d-%d, %.0f%% similar)", m.start, m.end, m.score*100)
+	}
+	if err := checkRedactedWrite(path, fileContent, newContent); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
 	}
 
 	// Ask for confirmation if a confirm function is provided
 	if t.ConfirmFn != nil {
-		// Create a simple diff preview
-		preview := createDiffPreview(oldString, newString)
-		prompt := fmt.Sprintf("Edit file %s:\n%s", path, preview)
-		if !t.ConfirmFn(prompt) {
+		req := ConfirmRequest{
+			Tool:   t.Def.Name,
+			Prompt: fmt.Sprintf("Edit file: %s%s", path, fuzzyNote),
+			Path:   path,
+			Diff:   unifiedDiff(path, fileContent, newContent, false),
+		}
+		if !t.ConfirmFn(req) {
 			return ToolResult{Success: false, Error: "user denied edit permission"}
 		}
 	}
 
-	// Perform the replacement
-	newContent := strings.Replace(fileContent, oldString, newString, 1)
+	if err := recordUndo(t.Journal, t.Def.Name, path); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
 
 	// Write back to file with original permissions
 	err = os.WriteFile(path, []byte(newContent), fileMode)
@@ -124,42 +156,35 @@ func (t *EditTool) Execute(ctx context.Context, args map[string]any) ToolResult
 
 	return ToolResult{
 		Success: true,
-		Output:  fmt.Sprintf("Successfully edited %s: replaced %d lines with %d lines", path, oldLines, newLines),
+		Output:  fmt.Sprintf("Successfully edited %s: replaced %d lines with %d lines%s", path, oldLines, newLines, fuzzyNote),
 	}
 }
 
-// createDiffPreview creates a simple diff-like preview
-func createDiffPreview(oldString, newString string) string {
-	var sb strings.Builder
-
-	oldLines := strings.Split(oldString, "\n")
-	newLines := strings.Split(newString, "\n")
-
-	// Show what's being removed (first few lines)
-	maxLines := 5
-	sb.WriteString("- ")
-	for i, line := range oldLines {
-		if i >= maxLines {
-			sb.WriteString(fmt.Sprintf("\n  ... (%d more lines)", len(oldLines)-maxLines))
-			break
-		}
-		if i > 0 {
-			sb.WriteString("\n- ")
-		}
-		sb.WriteString(line)
+// fuzzyApplicable reports whether the best match is close enough and clearly
+// better than the runner-up to apply without an exact match
+func fuzzyApplicable(matches []fuzzyMatch) bool {
+	if len(matches) == 0 || matches[0].score < fuzzyApplyThreshold {
+		return false
 	}
+	return len(matches) == 1 || matches[1].score < matches[0].score
+}
 
-	sb.WriteString("\n+ ")
-	for i, line := range newLines {
-		if i >= maxLines {
-			sb.WriteString(fmt.Sprintf("\n  ... (%d more lines)", len(newLines)-maxLines))
-			break
-		}
-		if i > 0 {
-			sb.WriteString("\n+ ")
-		}
-		sb.WriteString(line)
+// notFoundError explains a missing old_string, listing the closest lines so
+// the model can copy their exact text
+func notFoundError(fileLines []string, matches []fuzzyMatch, triedFuzzy bool) string {
+	msg := "old_string not found in file. Make sure you're using the exact text from the file."
+	if len(matches) == 0 {
+		return msg
 	}
 
-	return sb.String()
+	msg += "\n\n" + describeFuzzyMatches(fileLines, matches)
+	switch {
+	case triedFuzzy:
+		msg += "\n\nNo single match was close enough to apply fuzzily; copy the exact lines into old_string."
+	case fuzzyApplicable(matches):
+		msg += fmt.Sprintf("\n\nCopy the exact lines into old_string, or pass fuzzy: true to replace lines %d-%d.", matches[0].start, matches[0].end)
+	default:
+		msg += "\n\nCopy the exact lines into old_string."
+	}
+	return msg
 }
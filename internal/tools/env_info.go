@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionProbe describes how to ask a runtime for its version
+type versionProbe struct {
+	name   string
+	binary string
+	args   []string
+}
+
+// versionProbes are the language runtimes env_info reports on
+var versionProbes = []versionProbe{
+	{name: "go", binary: "go", args: []string{"version"}},
+	{name: "node", binary: "node", args: []string{"--version"}},
+	{name: "python", binary: "python3", args: []string{"--version"}},
+	{name: "ruby", binary: "ruby", args: []string{"--version"}},
+	{name: "rust", binary: "rustc", args: []string{"--version"}},
+	{name: "java", binary: "java", args: []string{"-version"}},
+	{name: "docker", binary: "docker", args: []string{"--version"}},
+}
+
+// keyBinaries are tools whose presence on PATH is worth reporting
+var keyBinaries = []string{
+	"git", "make", "npm", "yarn", "pnpm", "pip3", "cargo", "gcc", "clang",
+	"docker", "kubectl", "terraform", "curl",
+}
+
+// EnvInfoTool reports OS, architecture, runtime versions and available binaries
+type EnvInfoTool struct {
+	BaseTool
+	ProbeTimeout time.Duration
+	Commands     CommandPolicy // Version probes it refuses are skipped
+
+	mu     sync.Mutex
+	report string // Cached once a report was gathered without interruption
+}
+
+// NewEnvInfoTool creates a new environment info tool
+func NewEnvInfoTool() *EnvInfoTool {
+	return &EnvInfoTool{
+		ProbeTimeout: 3 * time.Second,
+		Commands:     ConfigCommandPolicy(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "env_info",
+				Description: "Report the OS, architecture, installed language runtime versions (go, node, python, ...) and key binaries available on PATH. Use this instead of running version commands yourself.",
+				Parameters: &JSONSchema{
+					Type:       "object",
+					Properties: map[string]*JSONSchema{},
+					Required:   []string{},
+				},
+			},
+		},
+	}
+}
+
+// Execute gathers the environment report, probing only until a report is
+// complete. A report cut short by a cancelled ctx is returned but not
+// cached, so its missing versions are probed again next time.
+func (t *EnvInfoTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.report != "" {
+		return ToolResult{Success: true, Output: t.report}
+	}
+	report := t.gather(ctx)
+	if ctx.Err() == nil {
+		t.report = report
+	}
+	return ToolResult{Success: true, Output: report}
+}
+
+// gather builds the environment report
+func (t *EnvInfoTool) gather(ctx context.Context) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("OS: %s\n", runtime.GOOS))
+	sb.WriteString(fmt.Sprintf("Architecture: %s\n", runtime.GOARCH))
+	if shell := os.Getenv("SHELL"); shell != "" {
+		sb.WriteString(fmt.Sprintf("Shell: %s\n", shell))
+	}
+
+	// Probe runtimes concurrently; each probe is bounded by ProbeTimeout
+	versions := make([]string, len(versionProbes))
+	var wg sync.WaitGroup
+	for i, probe := range versionProbes {
+		wg.Add(1)
+		go func(idx int, p versionProbe) {
+			defer wg.Done()
+			versions[idx] = t.probeVersion(ctx, p)
+		}(i, probe)
+	}
+	wg.Wait()
+
+	sb.WriteString("\nRuntimes:\n")
+	for i, probe := range versionProbes {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", probe.name, versions[i]))
+	}
+
+	var found []string
+	for _, bin := range keyBinaries {
+		if _, err := exec.LookPath(bin); err == nil {
+			found = append(found, bin)
+		}
+	}
+	sb.WriteString("\nBinaries on PATH: ")
+	if len(found) == 0 {
+		sb.WriteString("(none detected)")
+	} else {
+		sb.WriteString(strings.Join(found, ", "))
+	}
+
+	return sb.String()
+}
+
+// probeVersion runs a bounded version command and returns its first line.
+// Commands the command policy refuses are not run.
+func (t *EnvInfoTool) probeVersion(ctx context.Context, p versionProbe) string {
+	if err := t.Commands.Check(p.binary + " " + strings.Join(p.args, " ")); err != nil {
+		return "skipped (not allowed by the command policy)"
+	}
+
+	path, err := exec.LookPath(p.binary)
+	if err != nil {
+		return "not found"
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, t.ProbeTimeout)
+	defer cancel()
+
+	// Some runtimes (java) print their version to stderr
+	output, err := exec.CommandContext(probeCtx, path, p.args...).CombinedOutput()
+	if probeCtx.Err() == context.DeadlineExceeded {
+		return "installed (version probe timed out)"
+	}
+	if err != nil {
+		return "installed (version unknown)"
+	}
+
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// fuzzyMinSimilarity is the lowest score worth reporting as a near match
+	fuzzyMinSimilarity = 0.5
+
+	// fuzzyApplyThreshold is the score a match needs before edit_file will
+	// apply it when fuzzy is requested
+	fuzzyApplyThreshold = 0.9
+
+	// maxFuzzyCandidates is how many near matches an error lists
+	maxFuzzyCandidates = 3
+
+	// maxFuzzyComparisons bounds the line pairs compared, keeping huge
+	// files from stalling the tool
+	maxFuzzyComparisons = 100_000
+
+	// maxFuzzyLineLen truncates very long lines before comparing them
+	maxFuzzyLineLen = 200
+)
+
+// fuzzyMatch is a window of file lines that resembles the searched text
+type fuzzyMatch struct {
+	start, end int // 1-indexed, inclusive
+	score      float64
+}
+
+// findFuzzyMatches slides a window the size of target over the file and
+// scores each position by the mean similarity of its lines. Leading and
+// trailing whitespace is ignored so indentation drift still matches.
+// The best non-overlapping matches are returned, highest score first.
+func findFuzzyMatches(fileLines, target []string) []fuzzyMatch {
+	n, k := len(fileLines), len(target)
+	if k == 0 || n < k || (n-k+1)*k > maxFuzzyComparisons {
+		return nil
+	}
+
+	normTarget := make([]string, k)
+	for i, line := range target {
+		normTarget[i] = normalizeFuzzyLine(line)
+	}
+	normFile := make([]string, n)
+	for i, line := range fileLines {
+		normFile[i] = normalizeFuzzyLine(line)
+	}
+
+	var candidates []fuzzyMatch
+	for start := 0; start+k <= n; start++ {
+		var total float64
+		for j := 0; j < k; j++ {
+			total += lineSimilarity(normFile[start+j], normTarget[j])
+		}
+		if score := total / float64(k); score >= fuzzyMinSimilarity {
+			candidates = append(candidates, fuzzyMatch{start: start + 1, end: start + k, score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	var matches []fuzzyMatch
+	for _, c := range candidates {
+		overlaps := false
+		for _, m := range matches {
+			if c.start <= m.end && m.start <= c.end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			matches = append(matches, c)
+			if len(matches) == maxFuzzyCandidates {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// describeFuzzyMatches formats matches with their exact numbered lines
+func describeFuzzyMatches(fileLines []string, matches []fuzzyMatch) string {
+	var sb strings.Builder
+	sb.WriteString("Closest matches in the file:")
+	for _, m := range matches {
+		sb.WriteString(fmt.Sprintf("\n\nLines %d-%d (%.0f%% similar):\n", m.start, m.end, m.score*100))
+		sb.WriteString(numberLines(fileLines[m.start-1:m.end], m.start))
+	}
+	return sb.String()
+}
+
+// normalizeFuzzyLine trims whitespace and caps the length of a line
+func normalizeFuzzyLine(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) > maxFuzzyLineLen {
+		line = line[:maxFuzzyLineLen]
+	}
+	return line
+}
+
+// lineSimilarity returns 1 for identical lines down to 0 for unrelated ones
+func lineSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
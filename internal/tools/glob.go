@@ -7,20 +7,34 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
 )
 
+// defaultGlobLimit caps the paths listed when limit is not set
+const defaultGlobLimit = 100
+
 // GlobTool searches for files matching a glob pattern
 type GlobTool struct {
 	BaseTool
 }
 
+// globMatch is a matched path with the file details used for sorting
+// and counting
+type globMatch struct {
+	path    string // Relative to the search root
+	size    int64
+	modTime time.Time
+}
+
 // NewGlobTool creates a new glob file search tool
 func NewGlobTool() *GlobTool {
 	return &GlobTool{
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "glob",
-				Description: "Find files matching a glob pattern. Supports patterns like '**/*.go', 'src/**/*.ts', '*.json'. Returns matching file paths.",
+				Description: "Find files matching a glob pattern. Supports patterns like '**/*.go', 'src/**/*.ts', '*.json'. Returns matching file paths, or with count_only just how many match and their total size. Paths blocked by .zcodeignore and hidden directories are skipped unless no_ignore is true.",
 				Parameters: &JSONSchema{
 					Type: "object",
 					Properties: map[string]*JSONSchema{
@@ -32,6 +46,23 @@ func NewGlobTool() *GlobTool {
 							Type:        "string",
 							Description: "The directory to search in (defaults to current directory)",
 						},
+						"no_ignore": {
+							Type:        "boolean",
+							Description: "If true, also match paths blocked by .zcodeignore and hidden directories. Only use when the user asks for it",
+						},
+						"count_only": {
+							Type:        "boolean",
+							Description: "If true, return only the number of matching files and their total size instead of the paths. Use it to size up large directories",
+						},
+						"sort": {
+							Type:        "string",
+							Description: "Order of the paths: name (default), size (largest first) or mtime (most recently modified first)",
+							Enum:        []string{"name", "size", "mtime"},
+						},
+						"limit": {
+							Type:        "integer",
+							Description: fmt.Sprintf("Maximum number of paths to return (default %d)", defaultGlobLimit),
+						},
 					},
 					Required: []string{"pattern"},
 				},
@@ -44,6 +75,19 @@ func NewGlobTool() *GlobTool {
 func (t *GlobTool) Execute(ctx context.Context, args map[string]any) ToolResult {
 	pattern, _ := args["pattern"].(string)
 	basePath, _ := args["path"].(string)
+	noIgnore, _ := args["no_ignore"].(bool)
+	countOnly, _ := args["count_only"].(bool)
+	sortBy, _ := args["sort"].(string)
+
+	switch sortBy {
+	case "", "name", "size", "mtime":
+	default:
+		return ToolResult{Success: false, Error: fmt.Sprintf("invalid sort %q: use name, size or mtime", sortBy)}
+	}
+	limit := defaultGlobLimit
+	if n, ok := intArg(args, "limit"); ok && n > 0 {
+		limit = n
+	}
 
 	if basePath == "" {
 		basePath = "."
@@ -64,12 +108,21 @@ func (t *GlobTool) Execute(ctx context.Context, args map[string]any) ToolResult
 		return ToolResult{Success: false, Error: "path is not a directory"}
 	}
 
+	// Respect .zcodeignore, starting with the search root itself
+	var matcher *ignore.Matcher
+	if !noIgnore {
+		matcher, err = searchMatcher(absPath, true)
+		if err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
 	var matches []string
 	var warning string
 
 	// Handle ** pattern (recursive)
 	if strings.Contains(pattern, "**") {
-		matches, err = globRecursive(absPath, pattern)
+		matches, err = globRecursive(absPath, pattern, matcher)
 		// Check if this is just a "skipped paths" warning (not a hard error)
 		if err != nil && strings.Contains(err.Error(), "skipped") {
 			warning = err.Error()
@@ -79,41 +132,62 @@ func (t *GlobTool) Execute(ctx context.Context, args map[string]any) ToolResult
 		// Simple glob
 		fullPattern := filepath.Join(absPath, pattern)
 		matches, err = filepath.Glob(fullPattern)
+		if err == nil && matcher != nil {
+			matches = filterIgnored(matches, matcher)
+		}
 	}
 
 	if err != nil {
 		return ToolResult{Success: false, Error: fmt.Sprintf("glob error: %v", err)}
 	}
 
-	// Sort matches
-	sort.Strings(matches)
+	if len(matches) == 0 {
+		return ToolResult{
+			Success: true,
+			Output:  "No files found matching pattern: " + pattern,
+		}
+	}
 
-	// Convert to relative paths for cleaner output
-	relMatches := make([]string, 0, len(matches))
+	// Convert to relative paths for cleaner output, reading file details
+	// only when they are needed
+	needStat := countOnly || sortBy == "size" || sortBy == "mtime"
+	found := make([]globMatch, 0, len(matches))
+	var totalSize int64
 	for _, match := range matches {
 		rel, err := filepath.Rel(absPath, match)
 		if err != nil {
 			rel = match
 		}
-		relMatches = append(relMatches, rel)
-	}
-
-	if len(relMatches) == 0 {
-		return ToolResult{
-			Success: true,
-			Output:  "No files found matching pattern: " + pattern,
+		m := globMatch{path: rel}
+		if needStat {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				m.size, m.modTime = info.Size(), info.ModTime()
+				totalSize += m.size
+			}
 		}
+		found = append(found, m)
 	}
 
-	// Limit output if too many matches
-	maxMatches := 100
-	output := strings.Join(relMatches, "\n")
-	if len(relMatches) > maxMatches {
-		output = strings.Join(relMatches[:maxMatches], "\n")
-		output += fmt.Sprintf("\n... and %d more files", len(relMatches)-maxMatches)
-	}
+	var result string
+	if countOnly {
+		result = fmt.Sprintf("Found %d files matching %s, %s in total", len(found), pattern, formatSize(totalSize))
+	} else {
+		sortGlobMatches(found, sortBy)
 
-	result := fmt.Sprintf("Found %d files:\n%s", len(relMatches), output)
+		var sb strings.Builder
+		for i, m := range found {
+			if i == limit {
+				sb.WriteString(fmt.Sprintf("... and %d more files (raise limit to see more)\n", len(found)-limit))
+				break
+			}
+			sb.WriteString(m.path)
+			if sortBy == "size" {
+				sb.WriteString(" (" + formatSize(m.size) + ")")
+			}
+			sb.WriteString("\n")
+		}
+		result = fmt.Sprintf("Found %d files:\n%s", len(found), strings.TrimSuffix(sb.String(), "\n"))
+	}
 	if warning != "" {
 		result += fmt.Sprintf("\n\nNote: %s", warning)
 	}
@@ -124,14 +198,55 @@ func (t *GlobTool) Execute(ctx context.Context, args map[string]any) ToolResult
 	}
 }
 
+// sortGlobMatches orders matches by name, by size with the largest first,
+// or by modification time with the newest first. Ties are broken by name.
+func sortGlobMatches(matches []globMatch, by string) {
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case by == "size" && a.size != b.size:
+			return a.size > b.size
+		case by == "mtime" && !a.modTime.Equal(b.modTime):
+			return a.modTime.After(b.modTime)
+		}
+		return a.path < b.path
+	})
+}
+
+// formatSize formats a byte count for display, e.g. "1.5 MB"
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// filterIgnored drops paths that the matcher blocks
+func filterIgnored(paths []string, matcher *ignore.Matcher) []string {
+	kept := paths[:0]
+	for _, path := range paths {
+		if matcher.ValidatePath(path) == nil {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
 // globResult holds matches and metadata from recursive glob
 type globResult struct {
 	matches      []string
 	skippedCount int
 }
 
-// globRecursive handles ** patterns for recursive matching
-func globRecursive(basePath, pattern string) ([]string, error) {
+// globRecursive handles ** patterns for recursive matching.
+// A nil matcher disables .zcodeignore and hidden-directory filtering.
+func globRecursive(basePath, pattern string, matcher *ignore.Matcher) ([]string, error) {
 	result := &globResult{}
 
 	// Split pattern by **
@@ -156,9 +271,19 @@ func globRecursive(basePath, pattern string) ([]string, error) {
 			return nil
 		}
 
-		// Skip hidden directories
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." {
-			return filepath.SkipDir
+		if matcher != nil {
+			// Skip hidden directories
+			if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." && path != startPath {
+				return filepath.SkipDir
+			}
+
+			// Skip paths blocked by .zcodeignore
+			if matcher.ValidatePath(path) != nil {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 		}
 
 		if info.IsDir() {
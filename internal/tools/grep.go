@@ -8,11 +8,14 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
 )
 
 // GrepTool searches for content in files
 type GrepTool struct {
 	BaseTool
+	Redactor *Redactor
 }
 
 // GrepMatch represents a single match result
@@ -20,15 +23,29 @@ type GrepMatch struct {
 	File    string
 	Line    int
 	Content string
+
+	// Surrounding lines when context is requested, in file order
+	Before []string
+	After  []string
+}
+
+// grepOptions controls how much surrounding context is collected per match
+type grepOptions struct {
+	before int
+	after  int
 }
 
+// defaultGrepMaxResults caps the matches returned when max_results is not set
+const defaultGrepMaxResults = 100
+
 // NewGrepTool creates a new grep content search tool
 func NewGrepTool() *GrepTool {
 	return &GrepTool{
+		Redactor: DefaultRedactor(),
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "grep",
-				Description: "Search for text or regex patterns in files. Returns matching lines with file paths and line numbers.",
+				Description: "Search for text or regex patterns in files. Returns matching lines with file paths and line numbers. With before/after/context, surrounding lines are included: match lines are shown as 'file:line: text' and context lines as 'file-line- text'. Paths blocked by .zcodeignore, hidden files and dependency directories are skipped unless no_ignore is true.",
 				Parameters: &JSONSchema{
 					Type: "object",
 					Properties: map[string]*JSONSchema{
@@ -48,6 +65,26 @@ func NewGrepTool() *GrepTool {
 							Type:        "boolean",
 							Description: "If true, search is case-insensitive",
 						},
+						"before": {
+							Type:        "integer",
+							Description: "Number of lines to show before each match (like grep -B)",
+						},
+						"after": {
+							Type:        "integer",
+							Description: "Number of lines to show after each match (like grep -A)",
+						},
+						"context": {
+							Type:        "integer",
+							Description: "Number of lines to show before and after each match (like grep -C). before/after override it",
+						},
+						"max_results": {
+							Type:        "integer",
+							Description: fmt.Sprintf("Maximum number of matches to return (default %d)", defaultGrepMaxResults),
+						},
+						"no_ignore": {
+							Type:        "boolean",
+							Description: "If true, also search paths blocked by .zcodeignore, hidden files and dependency directories. Only use when the user asks for it",
+						},
 					},
 					Required: []string{"pattern"},
 				},
@@ -62,11 +99,28 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]any) ToolResult
 	searchPath, _ := args["path"].(string)
 	globPattern, _ := args["glob"].(string)
 	caseInsensitive, _ := args["case_insensitive"].(bool)
+	noIgnore, _ := args["no_ignore"].(bool)
 
 	if searchPath == "" {
 		searchPath = "."
 	}
 
+	var opts grepOptions
+	if n, ok := intArg(args, "context"); ok && n > 0 {
+		opts.before, opts.after = n, n
+	}
+	if n, ok := intArg(args, "before"); ok && n >= 0 {
+		opts.before = n
+	}
+	if n, ok := intArg(args, "after"); ok && n >= 0 {
+		opts.after = n
+	}
+
+	maxResults := defaultGrepMaxResults
+	if n, ok := intArg(args, "max_results"); ok && n > 0 {
+		maxResults = n
+	}
+
 	// Compile regex
 	regexPattern := pattern
 	if caseInsensitive {
@@ -96,18 +150,27 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]any) ToolResult
 		return ToolResult{Success: false, Error: fmt.Sprintf("path not found: %v", err)}
 	}
 
+	// Respect .zcodeignore, starting with the search root itself
+	var matcher *ignore.Matcher
+	if !noIgnore {
+		matcher, err = searchMatcher(absPath, info.IsDir())
+		if err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
 	var matches []GrepMatch
 	var warning string
 
 	if info.IsDir() {
-		matches, err = grepDirectory(absPath, re, globPattern)
+		matches, err = grepDirectory(absPath, re, globPattern, opts, matcher)
 		// Check if this is just a "skipped files" warning (not a hard error)
 		if err != nil && strings.Contains(err.Error(), "skipped") {
 			warning = err.Error()
 			err = nil
 		}
 	} else {
-		matches, err = grepFile(absPath, re)
+		matches, err = grepFile(absPath, re, opts)
 	}
 
 	if err != nil {
@@ -132,38 +195,93 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]any) ToolResult
 	}
 	sb.WriteString(fmt.Sprintf("Found %d matches:\n\n", len(matches)))
 
-	maxMatches := 50
-	for i, match := range matches {
-		if i >= maxMatches {
-			sb.WriteString(fmt.Sprintf("\n... and %d more matches", len(matches)-maxMatches))
-			break
-		}
-		// Truncate long lines
-		content := match.Content
-		if len(content) > 200 {
-			content = content[:200] + "..."
-		}
-		sb.WriteString(fmt.Sprintf("%s:%d: %s\n", match.File, match.Line, content))
+	shown := matches
+	if len(shown) > maxResults {
+		shown = shown[:maxResults]
+	}
+	writeGrepMatches(&sb, shown, opts)
+
+	if len(matches) > maxResults {
+		sb.WriteString(fmt.Sprintf("\n... and %d more matches (results truncated at %d; narrow the pattern or raise max_results)\n", len(matches)-maxResults, maxResults))
 	}
 
 	if warning != "" {
 		sb.WriteString(fmt.Sprintf("\nNote: %s", warning))
 	}
 
-	return ToolResult{
+	return redactResult(t.Redactor, ToolResult{
 		Success: true,
 		Output:  sb.String(),
+	})
+}
+
+// writeGrepMatches formats matches, merging overlapping context so each
+// line is printed once and separating non-adjacent groups with "--". A
+// match inside the previous match's after-context is still printed as a
+// match.
+func writeGrepMatches(sb *strings.Builder, matches []GrepMatch, opts grepOptions) {
+	withContext := opts.before > 0 || opts.after > 0
+	lastFile := ""
+	lastLine := 0
+
+	for m, match := range matches {
+		if !withContext {
+			sb.WriteString(fmt.Sprintf("%s:%d: %s\n", match.File, match.Line, truncateGrepLine(match.Content)))
+			continue
+		}
+
+		first := match.Line - len(match.Before)
+		if match.File != lastFile {
+			if lastFile != "" {
+				sb.WriteString("--\n")
+			}
+			lastFile = match.File
+			lastLine = 0
+		} else if first > lastLine+1 {
+			sb.WriteString("--\n")
+		}
+
+		for i, line := range match.Before {
+			if n := first + i; n > lastLine {
+				sb.WriteString(fmt.Sprintf("%s-%d- %s\n", match.File, n, truncateGrepLine(line)))
+				lastLine = n
+			}
+		}
+		if match.Line > lastLine {
+			sb.WriteString(fmt.Sprintf("%s:%d: %s\n", match.File, match.Line, truncateGrepLine(match.Content)))
+			lastLine = match.Line
+		}
+		// Stop the after-context at the next match, which prints itself
+		afterEnd := match.Line + len(match.After)
+		if m+1 < len(matches) && matches[m+1].File == match.File {
+			afterEnd = min(afterEnd, matches[m+1].Line-1)
+		}
+		for i, line := range match.After {
+			if n := match.Line + 1 + i; n > lastLine && n <= afterEnd {
+				sb.WriteString(fmt.Sprintf("%s-%d- %s\n", match.File, n, truncateGrepLine(line)))
+				lastLine = n
+			}
+		}
 	}
 }
 
+// truncateGrepLine shortens long lines such as minified code
+func truncateGrepLine(line string) string {
+	if len(line) > 200 {
+		return line[:200] + "..."
+	}
+	return line
+}
+
 // grepDirResult holds matches and metadata from directory grep
 type grepDirResult struct {
 	matches      []GrepMatch
 	skippedCount int
 }
 
-// grepDirectory searches all files in a directory
-func grepDirectory(dirPath string, re *regexp.Regexp, globPattern string) ([]GrepMatch, error) {
+// grepDirectory searches all files in a directory.
+// A nil matcher disables .zcodeignore, hidden-path and dependency-directory filtering.
+func grepDirectory(dirPath string, re *regexp.Regexp, globPattern string, opts grepOptions, matcher *ignore.Matcher) ([]GrepMatch, error) {
 	result := &grepDirResult{}
 
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
@@ -172,21 +290,33 @@ func grepDirectory(dirPath string, re *regexp.Regexp, globPattern string) ([]Gre
 			return nil // Skip errors but track them
 		}
 
-		// Skip hidden directories
-		if info.IsDir() {
-			if strings.HasPrefix(info.Name(), ".") && info.Name() != "." {
-				return filepath.SkipDir
+		if matcher != nil {
+			// Skip paths blocked by .zcodeignore
+			if matcher.ValidatePath(path) != nil {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
 			}
-			// Skip common non-code directories
-			switch info.Name() {
-			case "node_modules", "vendor", "__pycache__", ".git", "dist", "build":
-				return filepath.SkipDir
+
+			// Skip hidden directories
+			if info.IsDir() {
+				if strings.HasPrefix(info.Name(), ".") && info.Name() != "." && path != dirPath {
+					return filepath.SkipDir
+				}
+				// Skip common non-code directories
+				switch info.Name() {
+				case "node_modules", "vendor", "__pycache__", ".git", "dist", "build":
+					return filepath.SkipDir
+				}
+				return nil
 			}
-			return nil
-		}
 
-		// Skip hidden files
-		if strings.HasPrefix(info.Name(), ".") {
+			// Skip hidden files
+			if strings.HasPrefix(info.Name(), ".") {
+				return nil
+			}
+		} else if info.IsDir() {
 			return nil
 		}
 
@@ -204,7 +334,7 @@ func grepDirectory(dirPath string, re *regexp.Regexp, globPattern string) ([]Gre
 		}
 
 		// Search this file
-		matches, err := grepFile(path, re)
+		matches, err := grepFile(path, re, opts)
 		if err != nil {
 			result.skippedCount++
 			return nil // Skip files we can't read but track them
@@ -232,7 +362,7 @@ func grepDirectory(dirPath string, re *regexp.Regexp, globPattern string) ([]Gre
 
 // grepFile searches a single file.
 // Uses a 1MB buffer to handle files with long lines (e.g., minified JS).
-func grepFile(filePath string, re *regexp.Regexp) ([]GrepMatch, error) {
+func grepFile(filePath string, re *regexp.Regexp, opts grepOptions) ([]GrepMatch, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -247,16 +377,44 @@ func grepFile(filePath string, re *regexp.Regexp) ([]GrepMatch, error) {
 	scanner.Buffer(buf, maxScanTokenSize)
 	lineNum := 0
 
+	var recent []string // Up to opts.before preceding lines
+	var pending []int   // Indexes of matches still collecting after-context
+
 	for scanner.Scan() {
 		lineNum++
 		line := scanner.Text()
+		contextLine := strings.TrimRight(line, " \t\r")
+
+		// Feed after-context to earlier matches
+		stillPending := pending[:0]
+		for _, idx := range pending {
+			matches[idx].After = append(matches[idx].After, contextLine)
+			if len(matches[idx].After) < opts.after {
+				stillPending = append(stillPending, idx)
+			}
+		}
+		pending = stillPending
 
 		if re.MatchString(line) {
-			matches = append(matches, GrepMatch{
+			match := GrepMatch{
 				File:    filePath,
 				Line:    lineNum,
 				Content: strings.TrimSpace(line),
-			})
+			}
+			if len(recent) > 0 {
+				match.Before = append([]string(nil), recent...)
+			}
+			matches = append(matches, match)
+			if opts.after > 0 {
+				pending = append(pending, len(matches)-1)
+			}
+		}
+
+		if opts.before > 0 {
+			recent = append(recent, contextLine)
+			if len(recent) > opts.before {
+				recent = recent[1:]
+			}
 		}
 	}
 
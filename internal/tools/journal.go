@@ -0,0 +1,361 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultJournalMaxBytes caps the disk used by one session's backups
+const DefaultJournalMaxBytes = 100 << 20
+
+// ErrNothingToUndo is returned when the journal has no entries left
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// JournalEntry records the state of a path before a tool changed it
+type JournalEntry struct {
+	Tool    string
+	Path    string // Absolute path that was changed
+	MovedTo string // Destination for move_file entries
+	Existed bool   // False when the tool created the path
+	IsDir   bool
+	Backup  string // Copy of the original under the journal directory
+	Hash    string // sha256 of the original content
+	Size    int64
+	Time    time.Time
+}
+
+// Journal keeps backups of files modified by tools so edits can be undone.
+// Backups live under <dir>, which is created on first use.
+type Journal struct {
+	MaxBytes int64
+
+	mu      sync.Mutex
+	dir     string
+	entries []JournalEntry
+	seq     int
+}
+
+// NewJournal creates a journal that stores backups in dir
+func NewJournal(dir string) *Journal {
+	return &Journal{dir: dir, MaxBytes: DefaultJournalMaxBytes}
+}
+
+var (
+	defaultJournal     *Journal
+	defaultJournalOnce sync.Once
+)
+
+// DefaultJournal returns the journal shared by every tool in this process,
+// stored under ~/.zcode/backups/<session>. It returns nil if the home
+// directory cannot be found.
+func DefaultJournal() *Journal {
+	defaultJournalOnce.Do(func() {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		session := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+		defaultJournal = NewJournal(filepath.Join(home, ".zcode", "backups", session))
+	})
+	return defaultJournal
+}
+
+// Dir returns the directory backups are written to
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Entries returns a copy of the journal, oldest first
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// Record backs up path before tool modifies or deletes it. A path that
+// does not exist yet is recorded so undo can remove it again.
+func (j *Journal) Record(tool, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := JournalEntry{Tool: tool, Path: absPath, Time: time.Now()}
+
+	info, err := os.Stat(absPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		j.entries = append(j.entries, entry)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	j.seq++
+	entry.Existed = true
+	entry.IsDir = info.IsDir()
+	entry.Backup = filepath.Join(j.dir, fmt.Sprintf("%04d-%s", j.seq, filepath.Base(absPath)))
+
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if entry.IsDir {
+		entry.Size, err = copyTree(absPath, entry.Backup)
+	} else {
+		entry.Size = info.Size()
+		err = copyFile(absPath, entry.Backup, info.Mode().Perm())
+	}
+	if err != nil {
+		os.RemoveAll(entry.Backup)
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	entry.Hash, err = hashPath(entry.Backup)
+	if err != nil {
+		os.RemoveAll(entry.Backup)
+		return err
+	}
+
+	j.entries = append(j.entries, entry)
+	j.prune()
+	return nil
+}
+
+// RecordMove records a completed move so undo can move it back
+func (j *Journal) RecordMove(tool, source, destination string) error {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashPath(absDest)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, JournalEntry{
+		Tool:    tool,
+		Path:    absSource,
+		MovedTo: absDest,
+		Existed: true,
+		Hash:    hash,
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// Undo reverts the most recent entry and returns a description of what
+// was restored. Restored content is checked against the recorded hash.
+func (j *Journal) Undo() (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) == 0 {
+		return "", ErrNothingToUndo
+	}
+	entry := j.entries[len(j.entries)-1]
+
+	summary, err := j.restore(entry)
+	if err != nil {
+		return "", err
+	}
+
+	j.entries = j.entries[:len(j.entries)-1]
+	if entry.Backup != "" {
+		os.RemoveAll(entry.Backup)
+	}
+	return summary, nil
+}
+
+// restore applies one entry in reverse
+func (j *Journal) restore(entry JournalEntry) (string, error) {
+	switch {
+	case entry.MovedTo != "":
+		if _, err := os.Stat(entry.Path); err == nil {
+			return "", fmt.Errorf("cannot move %s back: %s already exists", entry.MovedTo, entry.Path)
+		}
+		if err := os.Rename(entry.MovedTo, entry.Path); err != nil {
+			return "", fmt.Errorf("failed to move %s back: %w", entry.MovedTo, err)
+		}
+		if err := verifyHash(entry.Path, entry.Hash); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Moved %s back to %s (undid %s)", entry.MovedTo, entry.Path, entry.Tool), nil
+
+	case !entry.Existed:
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return "", fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+		}
+		return fmt.Sprintf("Removed %s (undid %s)", entry.Path, entry.Tool), nil
+	}
+
+	if entry.IsDir {
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return "", fmt.Errorf("failed to clear %s: %w", entry.Path, err)
+		}
+		if _, err := copyTree(entry.Backup, entry.Path); err != nil {
+			return "", fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+	} else {
+		info, err := os.Stat(entry.Backup)
+		if err != nil {
+			return "", fmt.Errorf("backup for %s is missing: %w", entry.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create parent directories: %w", err)
+		}
+		if err := copyFile(entry.Backup, entry.Path, info.Mode().Perm()); err != nil {
+			return "", fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+	}
+
+	if err := verifyHash(entry.Path, entry.Hash); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Restored %s (undid %s)", entry.Path, entry.Tool), nil
+}
+
+// prune drops the oldest backups until the journal fits in MaxBytes.
+// The newest entry is always kept so the latest change stays undoable.
+func (j *Journal) prune() {
+	if j.MaxBytes <= 0 {
+		return
+	}
+
+	var total int64
+	for _, e := range j.entries {
+		total += e.Size
+	}
+
+	for total > j.MaxBytes && len(j.entries) > 1 {
+		oldest := j.entries[0]
+		if oldest.Backup != "" {
+			os.RemoveAll(oldest.Backup)
+		}
+		total -= oldest.Size
+		j.entries = j.entries[1:]
+	}
+}
+
+// verifyHash checks that path matches a recorded hash
+func verifyHash(path, want string) error {
+	got, err := hashPath(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("restored %s does not match its backup (hash %s, want %s)", path, got[:12], want[:12])
+	}
+	return nil
+}
+
+// hashPath returns the sha256 of a file, or of every file (with its
+// relative path) in a directory tree
+func hashPath(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if !info.IsDir() {
+		if err := hashFile(h, path); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		rel, _ := filepath.Rel(path, f)
+		io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		if err := hashFile(h, f); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// copyTree copies the regular files and directories under src to dst and
+// returns the number of bytes copied
+func copyTree(src, dst string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return copyFile(path, target, info.Mode().Perm())
+	})
+	return total, err
+}
+
+// recordUndo backs up path in j before a tool changes it. A nil journal
+// records nothing.
+func recordUndo(j *Journal, tool, path string) error {
+	if j == nil {
+		return nil
+	}
+	if err := j.Record(tool, path); err != nil {
+		return fmt.Errorf("failed to record undo backup: %w", err)
+	}
+	return nil
+}
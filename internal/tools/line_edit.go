@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReadTracker remembers the content hash of each file when it was last
+// read, so position-based edits can detect that a file changed underneath
+type ReadTracker struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// NewReadTracker creates an empty read tracker
+func NewReadTracker() *ReadTracker {
+	return &ReadTracker{hashes: make(map[string]string)}
+}
+
+var (
+	defaultReadTracker     *ReadTracker
+	defaultReadTrackerOnce sync.Once
+)
+
+// DefaultReadTracker returns the tracker shared by every tool in this process
+func DefaultReadTracker() *ReadTracker {
+	defaultReadTrackerOnce.Do(func() {
+		defaultReadTracker = NewReadTracker()
+	})
+	return defaultReadTracker
+}
+
+// Remember records content as the last-read version of path
+func (r *ReadTracker) Remember(path string, content []byte) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hashes[key] = contentHash(content)
+}
+
+// Check returns an error unless content matches the last-read version of path
+func (r *ReadTracker) Check(path string, content []byte) error {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	want, ok := r.hashes[key]
+	if !ok {
+		return fmt.Errorf("%s has not been read yet; read it with read_file before editing by line number", path)
+	}
+	if contentHash(content) != want {
+		return fmt.Errorf("%s has changed since it was last read; read it again to get current line numbers", path)
+	}
+	return nil
+}
+
+// contentHash returns the hex sha256 of content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// lineEdit replaces lines start..end (1-indexed, inclusive). An end of
+// start-1 inserts before start without removing anything.
+type lineEdit struct {
+	start, end  int
+	replacement []string
+}
+
+// LineEditTool applies edits addressed by line number
+type LineEditTool struct {
+	BaseTool
+	ConfirmFn ConfirmFunc
+	Journal   *Journal
+	Tracker   *ReadTracker
+}
+
+// NewLineEditTool creates a new line edit tool
+func NewLineEditTool(confirmFn ConfirmFunc) *LineEditTool {
+	return &LineEditTool{
+		ConfirmFn: confirmFn,
+		Journal:   DefaultJournal(),
+		Tracker:   DefaultReadTracker(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "line_edit",
+				Description: "Replace line ranges in a file by line number, without repeating the old text. Read the lines with read_file (start_line/end_line) first; line numbers refer to that read, and the edit is refused if the file changed since. All edits in one call use the original numbering and must not overlap. Prefer this over edit_file for large files.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"path": {
+							Type:        "string",
+							Description: "The path to the file to edit",
+						},
+						"edits": {
+							Type:        "array",
+							Description: "Edits to apply, each replacing start_line..end_line (inclusive) with replacement",
+							Items: &JSONSchema{
+								Type: "object",
+								Properties: map[string]*JSONSchema{
+									"start_line": {
+										Type:        "integer",
+										Description: "First line to replace (1-indexed)",
+									},
+									"end_line": {
+										Type:        "integer",
+										Description: "Last line to replace (inclusive). Use start_line - 1 to insert before start_line without replacing",
+									},
+									"replacement": {
+										Type:        "string",
+										Description: "New text for the range; empty deletes the lines",
+									},
+								},
+								Required: []string{"start_line", "end_line", "replacement"},
+							},
+						},
+					},
+					Required: []string{"path", "edits"},
+				},
+			},
+		},
+	}
+}
+
+// Execute applies the line edits
+func (t *LineEditTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'path' parameter"}
+	}
+
+	edits, err := parseLineEdits(args["edits"])
+	if err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to stat file: %v", err)}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to read file: %v", err)}
+	}
+
+	// Conflict detection: line numbers are only meaningful for the version the model read
+	if t.Tracker != nil {
+		if err := t.Tracker.Check(path, content); err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
+	fileContent := string(content)
+	hasTrailingNewline := strings.HasSuffix(fileContent, "\n")
+	lines := splitLines(fileContent)
+
+	if err := validateLineEdits(edits, len(lines)); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	// Apply bottom-up so earlier line numbers stay valid
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		updated := make([]string, 0, len(lines)-(e.end-e.start+1)+len(e.replacement))
+		updated = append(updated, lines[:e.start-1]...)
+		updated = append(updated, e.replacement...)
+		updated = append(updated, lines[e.end:]...)
+		lines = updated
+	}
+
+	newContent := strings.Join(lines, "\n")
+	if len(lines) > 0 && (hasTrailingNewline || fileContent == "") {
+		newContent += "\n"
+	}
+	if err := checkRedactedWrite(path, fileContent, newContent); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	// Ask for confirmation if a confirm function is provided
+	if t.ConfirmFn != nil {
+		req := ConfirmRequest{
+			Tool:   t.Def.Name,
+			Prompt: fmt.Sprintf("Edit file: %s (%d line edits)", path, len(edits)),
+			Path:   path,
+			Diff:   unifiedDiff(path, fileContent, newContent, false),
+		}
+		if !t.ConfirmFn(req) {
+			return ToolResult{Success: false, Error: "user denied edit permission"}
+		}
+	}
+
+	if err := recordUndo(t.Journal, t.Def.Name, path); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	if err := os.WriteFile(path, []byte(newContent), info.Mode().Perm()); err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to write file: %v", err)}
+	}
+
+	// The model knows what it wrote, so the new content counts as read
+	if t.Tracker != nil {
+		t.Tracker.Remember(path, []byte(newContent))
+	}
+
+	return ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Applied %d edits to %s; file now has %d lines", len(edits), path, len(lines)),
+	}
+}
+
+// parseLineEdits converts the decoded JSON edits argument and sorts it
+func parseLineEdits(raw any) ([]lineEdit, error) {
+	items, ok := raw.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("missing or invalid 'edits' parameter: expected a non-empty array")
+	}
+
+	edits := make([]lineEdit, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: expected an object", i+1)
+		}
+		start, ok := intArg(obj, "start_line")
+		if !ok {
+			return nil, fmt.Errorf("edit %d: missing start_line", i+1)
+		}
+		end, ok := intArg(obj, "end_line")
+		if !ok {
+			return nil, fmt.Errorf("edit %d: missing end_line", i+1)
+		}
+		replacement, ok := obj["replacement"].(string)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: missing replacement", i+1)
+		}
+		edits = append(edits, lineEdit{start: start, end: end, replacement: splitLines(replacement)})
+	}
+
+	sort.SliceStable(edits, func(a, b int) bool { return edits[a].start < edits[b].start })
+	return edits, nil
+}
+
+// validateLineEdits checks sorted edits against the file length and each other
+func validateLineEdits(edits []lineEdit, total int) error {
+	prevEnd := 0
+	for _, e := range edits {
+		if e.start < 1 || e.start > total+1 {
+			return fmt.Errorf("start_line %d is out of range (file has %d lines)", e.start, total)
+		}
+		if e.end < e.start-1 {
+			return fmt.Errorf("end_line %d is before start_line %d", e.end, e.start)
+		}
+		if e.end > total {
+			return fmt.Errorf("end_line %d is out of range (file has %d lines)", e.end, total)
+		}
+		if e.start <= prevEnd {
+			return fmt.Errorf("edit at lines %d-%d overlaps the previous edit", e.start, e.end)
+		}
+		prevEnd = e.end
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
+)
+
+// MoveFileTool moves or renames a file or directory
+type MoveFileTool struct {
+	BaseTool
+	ConfirmFn ConfirmFunc
+	Matcher   *ignore.Matcher
+	Journal   *Journal
+}
+
+// NewMoveFileTool creates a new move file tool
+func NewMoveFileTool(confirmFn ConfirmFunc) *MoveFileTool {
+	return &MoveFileTool{
+		ConfirmFn: confirmFn,
+		Matcher:   defaultMatcher(),
+		Journal:   DefaultJournal(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "move_file",
+				Description: "Move or rename a file or directory. Parent directories of the destination are created as needed.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"source": {
+							Type:        "string",
+							Description: "The path of the file or directory to move",
+						},
+						"destination": {
+							Type:        "string",
+							Description: "The new path for the file or directory",
+						},
+					},
+					Required: []string{"source", "destination"},
+				},
+			},
+		},
+	}
+}
+
+// Execute moves the file
+func (t *MoveFileTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	source, ok := args["source"].(string)
+	if !ok || source == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'source' parameter"}
+	}
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'destination' parameter"}
+	}
+
+	for _, path := range []string{source, destination} {
+		if err := validatePath(t.Matcher, path); err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to stat source: %v", err)}
+	}
+	if info.IsDir() {
+		// Moving a directory moves everything inside it, so each path
+		// must be allowed where it is and where it would end up
+		if err := validateTree(t.Matcher, source, destination); err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("destination already exists: %s", destination)}
+	}
+
+	// Ask for confirmation if a confirm function is provided
+	if t.ConfirmFn != nil {
+		prompt := fmt.Sprintf("Move %s -> %s", source, destination)
+		if !t.ConfirmFn(ConfirmRequest{Tool: t.Def.Name, Prompt: prompt, Path: source}) {
+			return ToolResult{Success: false, Error: "user denied move permission"}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to create parent directories: %v", err)}
+	}
+
+	if err := os.Rename(source, destination); err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to move: %v", err)}
+	}
+	if t.Journal != nil {
+		// The move already happened, so a journal failure only costs undo
+		t.Journal.RecordMove(t.Def.Name, source, destination)
+	}
+	if t.Matcher != nil {
+		t.Matcher.ClearCache()
+	}
+
+	return ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Successfully moved %s to %s", source, destination),
+	}
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+// ReadOnlyTools never change files or run commands. They are the only
+// tools registered in read-only mode.
+var ReadOnlyTools = map[string]bool{
+	"read_file": true,
+	"list_dir":  true,
+	"glob":      true,
+	"grep":      true,
+	"web_fetch": true,
+	"env_info":  true,
+	"todo":      true,
+}
+
+// Policy restricts which tools a registry accepts
+type Policy struct {
+	ReadOnly bool            // Only accept tools in ReadOnlyTools
+	Disabled map[string]bool // Tools never accepted
+}
+
+// ConfigPolicy returns the policy set by the tools.readonly and
+// tools.disabled config keys
+func ConfigPolicy() Policy {
+	policy := Policy{ReadOnly: config.GetToolsReadOnly()}
+	if disabled := config.GetDisabledTools(); len(disabled) > 0 {
+		policy.Disabled = make(map[string]bool, len(disabled))
+		for _, name := range disabled {
+			policy.Disabled[name] = true
+		}
+	}
+	return policy
+}
+
+// Allows reports whether the policy accepts the named tool
+func (p Policy) Allows(name string) bool {
+	if p.Disabled[name] {
+		return false
+	}
+	return !p.ReadOnly || ReadOnlyTools[name]
+}
+
+// readTools are the tools counted against the per-turn read cap
+var readTools = map[string]bool{
+	"read_file": true,
+	"list_dir":  true,
+}
+
+// ReadLimit caps the read_file and list_dir calls of a turn, so the model
+// narrows its search instead of opening every file. It is safe for
+// concurrent use.
+type ReadLimit struct {
+	max   int // 0 for no limit
+	reads atomic.Int64
+}
+
+// NewReadLimit creates a limit of max reads per turn; 0 means no limit
+func NewReadLimit(max int) *ReadLimit {
+	return &ReadLimit{max: max}
+}
+
+// Reset starts a new turn
+func (l *ReadLimit) Reset() {
+	l.reads.Store(0)
+}
+
+// Take counts a call to the named tool and returns an error once the
+// turn's reads are used up. Other tools are not counted.
+func (l *ReadLimit) Take(name string) error {
+	if !readTools[name] || l.max <= 0 || l.reads.Add(1) <= int64(l.max) {
+		return nil
+	}
+	return fmt.Errorf("read limit reached: %s was not run because this turn already used %d read_file/list_dir calls. "+
+		"Narrow the search with grep or glob and read only the files you need; the limit resets on the next user message "+
+		"(raise it with 'zcode config set max_reads_per_turn <n>')", name, l.max)
+}
@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/simonyos/Z-CODE/internal/llm"
+)
+
+// toolCallFence opens a tool call block in a text response
+const toolCallFence = "```tool_call"
+
+// toolCallBlock matches a complete tool call block and captures its JSON
+var toolCallBlock = regexp.MustCompile("(?s)```tool_call[ \\t]*\\n(.*?)\\n?```")
+
+// ParseToolCalls extracts the tool calls written as ```tool_call blocks in a
+// text response, for models without native tool calling. Each block holds
+// a JSON object with "name" and "arguments". It returns the calls and the
+// text with the blocks removed. Blocks that are not valid JSON are left in
+// the text.
+func ParseToolCalls(text string) ([]ToolCall, string) {
+	var calls []ToolCall
+	rest := toolCallBlock.ReplaceAllStringFunc(text, func(block string) string {
+		body := toolCallBlock.FindStringSubmatch(block)[1]
+		var raw struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &raw); err != nil || raw.Name == "" {
+			return block
+		}
+		if raw.Arguments == nil {
+			raw.Arguments = map[string]any{}
+		}
+		calls = append(calls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", len(calls)+1),
+			Name:      raw.Name,
+			Arguments: raw.Arguments,
+		})
+		return ""
+	})
+	return calls, strings.TrimSpace(rest)
+}
+
+// PromptToolProvider adds tool calling to a provider without native
+// support. Tool descriptions are written into the system prompt, tool calls
+// are parsed from the text response with ParseToolCalls, and tool results
+// are sent back as user messages.
+type PromptToolProvider struct {
+	llm.Provider
+}
+
+// NewPromptToolProvider wraps a provider with prompt-based tool calling
+func NewPromptToolProvider(provider llm.Provider) *PromptToolProvider {
+	return &PromptToolProvider{Provider: provider}
+}
+
+// AsToolProvider returns the provider itself when it supports native tool
+// calling, and otherwise wraps it with prompt-based tool calling
+func AsToolProvider(provider llm.Provider) llm.ToolProvider {
+	if tp, ok := provider.(llm.ToolProvider); ok {
+		return tp
+	}
+	return NewPromptToolProvider(provider)
+}
+
+// GenerateWithTools implements llm.ToolProvider
+func (p *PromptToolProvider) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (*llm.ToolCallResponse, error) {
+	text, err := p.Generate(ctx, promptToolMessages(messages, tools))
+	if err != nil {
+		return nil, err
+	}
+	return toolCallResponse(text), nil
+}
+
+// GenerateStreamWithTools implements llm.ToolProvider. Text is streamed
+// until a tool call block starts; the block itself is not shown.
+func (p *PromptToolProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	chunks, err := p.GenerateStream(ctx, promptToolMessages(messages, tools))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan llm.ToolStreamChunk)
+	go func() {
+		defer close(out)
+		var text strings.Builder
+		emitted := 0
+		held := false // A tool call block has started; stop streaming
+		send := func(chunk llm.ToolStreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for chunk := range chunks {
+			if chunk.Error != nil {
+				send(llm.ToolStreamChunk{Error: chunk.Error})
+				return
+			}
+			if chunk.Done {
+				full := chunk.Text
+				if full == "" {
+					full = text.String()
+				}
+				resp := toolCallResponse(full)
+				// Flush visible text not yet streamed, e.g. when the
+				// provider only sent the final chunk
+				if visible := visibleText(full); !held && len(visible) > emitted && strings.HasPrefix(visible, text.String()[:emitted]) {
+					if !send(llm.ToolStreamChunk{Text: visible[emitted:]}) {
+						return
+					}
+				}
+				send(llm.ToolStreamChunk{Text: resp.Content, ToolCalls: resp.ToolCalls, Done: true})
+				return
+			}
+
+			text.WriteString(chunk.Text)
+			if held {
+				continue
+			}
+			current := text.String()
+			safe := len(current) - partialFenceLen(current)
+			if i := strings.Index(current, toolCallFence); i >= 0 {
+				safe = i
+				held = true
+			}
+			if safe > emitted {
+				if !send(llm.ToolStreamChunk{Text: current[emitted:safe]}) {
+					return
+				}
+				emitted = safe
+			}
+		}
+	}()
+	return out, nil
+}
+
+// toolCallResponse converts a text response into a tool call response
+func toolCallResponse(text string) *llm.ToolCallResponse {
+	calls, rest := ParseToolCalls(text)
+	resp := &llm.ToolCallResponse{Content: rest, Done: len(calls) == 0}
+	for _, call := range calls {
+		args, _ := json.Marshal(call.Arguments)
+		tc := llm.OpenAIToolCall{ID: call.ID, Type: "function"}
+		tc.Function.Name = call.Name
+		tc.Function.Arguments = string(args)
+		resp.ToolCalls = append(resp.ToolCalls, tc)
+	}
+	return resp
+}
+
+// visibleText returns the text before the first tool call block
+func visibleText(text string) string {
+	if i := strings.Index(text, toolCallFence); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// partialFenceLen returns how many trailing bytes of text could be the
+// start of a tool call fence, so they are held back until the next chunk
+func partialFenceLen(text string) int {
+	for n := min(len(toolCallFence)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, toolCallFence[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// promptToolMessages rewrites a tool calling conversation for a provider
+// that only understands plain text: tools are described in the system
+// prompt, assistant tool calls become tool_call blocks, and tool results
+// become user messages
+func promptToolMessages(messages []llm.Message, tools []llm.OpenAITool) []llm.Message {
+	instructions := toolInstructions(tools)
+	out := make([]llm.Message, 0, len(messages)+1)
+	if instructions != "" && (len(messages) == 0 || messages[0].Role != "system") {
+		out = append(out, llm.Message{Role: "system", Content: instructions})
+	}
+
+	for i, msg := range messages {
+		switch {
+		case i == 0 && msg.Role == "system" && instructions != "":
+			out = append(out, llm.Message{Role: "system", Content: msg.Content + "\n\n" + instructions})
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			var sb strings.Builder
+			sb.WriteString(msg.Content)
+			for _, tc := range msg.ToolCalls {
+				args := tc.Function.Arguments
+				if args == "" {
+					args = "{}"
+				}
+				fmt.Fprintf(&sb, "\n\n%s\n{\"name\": %q, \"arguments\": %s}\n```", toolCallFence, tc.Function.Name, args)
+			}
+			out = append(out, llm.Message{Role: "assistant", Content: strings.TrimSpace(sb.String())})
+		case msg.Role == "tool":
+			result := fmt.Sprintf("Result of %s:\n%s", msg.Name, msg.Content)
+			// Results of one batch are sent together
+			if last := len(out) - 1; last >= 0 && i > 0 && messages[i-1].Role == "tool" {
+				out[last].Content += "\n\n" + result
+			} else {
+				out = append(out, llm.Message{Role: "user", Content: result})
+			}
+		default:
+			out = append(out, llm.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return out
+}
+
+// toolInstructions describes the tools and the tool_call block format
+func toolInstructions(tools []llm.OpenAITool) string {
+	if len(tools) == 0 {
+		return ""
+	}
+	sorted := append([]llm.OpenAITool(nil), tools...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Function.Name < sorted[j].Function.Name })
+
+	var sb strings.Builder
+	sb.WriteString("TOOLS\n\n")
+	sb.WriteString("To use a tool, reply with a tool_call block holding a JSON object with the tool's name and arguments:\n\n")
+	sb.WriteString(toolCallFence + "\n{\"name\": \"tool_name\", \"arguments\": {\"argument\": \"value\"}}\n```\n\n")
+	sb.WriteString("You may write several blocks to call several tools. The results come back in the next message. ")
+	sb.WriteString("When you have the final answer, reply without a tool_call block.\n\n")
+	sb.WriteString("Available tools:\n")
+	for _, tool := range sorted {
+		params, _ := json.Marshal(tool.Function.Parameters)
+		fmt.Fprintf(&sb, "\n## %s\n%s\nParameters: %s\n", tool.Function.Name, tool.Function.Description, params)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
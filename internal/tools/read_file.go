@@ -2,21 +2,27 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 )
 
 // ReadFileTool reads the contents of a file
 type ReadFileTool struct {
 	BaseTool
+	Tracker  *ReadTracker
+	Redactor *Redactor
 }
 
 // NewReadFileTool creates a new read file tool
 func NewReadFileTool() *ReadFileTool {
 	return &ReadFileTool{
+		Tracker:  DefaultReadTracker(),
+		Redactor: DefaultRedactor(),
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "read_file",
-				Description: "Read the contents of a file at the specified path",
+				Description: "Read the contents of a file at the specified path. For large files, read a slice with start_line/end_line (or offset/limit); sliced output is prefixed with line numbers. If both styles are given, start_line/end_line take precedence. Binary files are described instead of returned unless force is true.",
 				Parameters: &JSONSchema{
 					Type: "object",
 					Properties: map[string]*JSONSchema{
@@ -24,6 +30,26 @@ func NewReadFileTool() *ReadFileTool {
 							Type:        "string",
 							Description: "The path to the file to read",
 						},
+						"start_line": {
+							Type:        "integer",
+							Description: "First line to read (1-indexed, inclusive)",
+						},
+						"end_line": {
+							Type:        "integer",
+							Description: "Last line to read (1-indexed, inclusive)",
+						},
+						"offset": {
+							Type:        "integer",
+							Description: "Number of lines to skip before reading (0-indexed). Ignored if start_line or end_line is set",
+						},
+						"limit": {
+							Type:        "integer",
+							Description: "Maximum number of lines to read. Ignored if start_line or end_line is set",
+						},
+						"force": {
+							Type:        "boolean",
+							Description: "If true, return a binary file's raw bytes instead of a summary. Rarely useful",
+						},
 					},
 					Required: []string{"path"},
 				},
@@ -40,6 +66,68 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) ToolRes
 	if err != nil {
 		return ToolResult{Success: false, Error: err.Error()}
 	}
+	if force, _ := args["force"].(bool); !force && looksBinary(content) {
+		return ToolResult{Success: false, Error: describeBinary(path, content)}
+	}
+	if t.Tracker != nil {
+		t.Tracker.Remember(path, content)
+	}
+
+	startLine, hasStart := intArg(args, "start_line")
+	endLine, hasEnd := intArg(args, "end_line")
+	offset, hasOffset := intArg(args, "offset")
+	limit, hasLimit := intArg(args, "limit")
+
+	// No range requested: return the whole file unchanged
+	if !hasStart && !hasEnd && !hasOffset && !hasLimit {
+		return redactResult(t.Redactor, ToolResult{Success: true, Output: string(content)})
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // Trailing newline does not start a new line
+	}
+	total := len(lines)
+
+	// Convert to a 1-indexed inclusive range; start_line/end_line win over offset/limit
+	first, last := 1, total
+	if hasStart || hasEnd {
+		if hasStart {
+			first = startLine
+		}
+		if hasEnd {
+			last = endLine
+		}
+	} else {
+		if hasOffset {
+			first = offset + 1
+		}
+		if hasLimit {
+			last = first + limit - 1
+		}
+	}
 
-	return ToolResult{Success: true, Output: string(content)}
+	if first < 1 {
+		return ToolResult{Success: false, Error: "start_line must be >= 1 and offset must be >= 0"}
+	}
+	if last < first {
+		return ToolResult{Success: false, Error: fmt.Sprintf("invalid range: line %d to %d", first, last)}
+	}
+	if first > total {
+		return ToolResult{Success: false, Error: fmt.Sprintf("line %d is past end of file (%d lines)", first, total)}
+	}
+	last = min(last, total)
+
+	return redactResult(t.Redactor, ToolResult{Success: true, Output: numberLines(lines[first-1:last], first)})
+}
+
+// numberLines prefixes each line with its line number, starting at first
+func numberLines(lines []string, first int) string {
+	width := len(fmt.Sprint(first + len(lines) - 1))
+
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("%*d| %s\n", width, first+i, line))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
 }
@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+// secretPattern is one kind of secret the redactor recognizes. When group
+// is set, only that capture group is replaced, keeping e.g. the key name.
+type secretPattern struct {
+	name  string
+	re    *regexp.Regexp
+	group int
+}
+
+// highConfidencePatterns match token formats that are almost never anything
+// but a credential
+var highConfidencePatterns = []secretPattern{
+	{name: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{name: "anthropic_key", re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{name: "openai_key", re: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`)},
+	{name: "slack_token", re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{name: "google_api_key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "stripe_key", re: regexp.MustCompile(`\b(?:sk|rk)_live_[0-9A-Za-z]{20,}\b`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// aggressivePatterns also catch generic assignments such as password=...,
+// at the cost of occasional false positives
+var aggressivePatterns = []secretPattern{
+	{
+		name:  "secret_assignment",
+		re:    regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?key|auth[_-]?token|token)\b["']?\s*[:=]\s*["']?([^\s"',;]{8,})`),
+		group: 1,
+	},
+	{name: "connection_string", re: regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s:/@]+)@`), group: 1},
+}
+
+// Redactor replaces secrets in text before it is sent to the model
+type Redactor struct {
+	patterns []secretPattern
+}
+
+// NewRedactor creates a redactor for a sensitivity level ("off", "high"
+// or "aggressive") plus custom name -> regex patterns. Invalid custom
+// patterns are skipped. It returns nil when the level is "off".
+func NewRedactor(level string, custom map[string]string) *Redactor {
+	if level == config.RedactOff {
+		return nil
+	}
+
+	r := &Redactor{patterns: append([]secretPattern(nil), highConfidencePatterns...)}
+	if level == config.RedactAggressive {
+		r.patterns = append(r.patterns, aggressivePatterns...)
+	}
+
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if re, err := regexp.Compile(custom[name]); err == nil {
+			r.patterns = append(r.patterns, secretPattern{name: name, re: re})
+		}
+	}
+	return r
+}
+
+var (
+	defaultRedactor     *Redactor
+	defaultRedactorOnce sync.Once
+)
+
+// DefaultRedactor returns the redactor configured by secret_redaction and
+// secret_pattern.* in the config, or nil when redaction is off
+func DefaultRedactor() *Redactor {
+	defaultRedactorOnce.Do(func() {
+		defaultRedactor = NewRedactor(config.GetSecretRedaction(), config.GetSecretPatterns())
+	})
+	return defaultRedactor
+}
+
+// Redact replaces each secret with [REDACTED:type] and returns the kinds of
+// secret found, sorted and without duplicates
+func (r *Redactor) Redact(text string) (string, []string) {
+	if r == nil || text == "" {
+		return text, nil
+	}
+
+	found := make(map[string]bool)
+	for _, p := range r.patterns {
+		text = replaceSecrets(text, p, found)
+	}
+
+	kinds := make([]string, 0, len(found))
+	for kind := range found {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return text, kinds
+}
+
+// replaceSecrets replaces every match of p in text, recording p.name in found
+func replaceSecrets(text string, p secretPattern, found map[string]bool) string {
+	placeholder := "[REDACTED:" + p.name + "]"
+	matches := p.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if p.group > 0 && 2*p.group+1 < len(m) && m[2*p.group] >= 0 {
+			start, end = m[2*p.group], m[2*p.group+1]
+		}
+		if strings.HasPrefix(text[start:end], "[REDACTED:") {
+			continue // Already replaced by an earlier pattern
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(placeholder)
+		last = end
+		found[p.name] = true
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// redactResult redacts a tool result's output and notes what was removed,
+// so both the user and the model can see that the text was altered
+func redactResult(r *Redactor, result ToolResult) ToolResult {
+	output, kinds := r.Redact(result.Output)
+	if len(kinds) == 0 {
+		return result
+	}
+	slog.Info("redacted secrets from tool output", "kinds", kinds)
+	result.Output = output + fmt.Sprintf("\n\n[zcode: redacted secrets (%s); the original text is unchanged on disk. "+
+		"Do not write the placeholders back to a file: edit around them instead]", strings.Join(kinds, ", "))
+	return result
+}
+
+// redactedPlaceholder matches the placeholders Redact puts in place of
+// secrets
+var redactedPlaceholder = regexp.MustCompile(`\[REDACTED:[A-Za-z0-9_.-]+\]`)
+
+// checkRedactedWrite refuses a change that adds [REDACTED:type]
+// placeholders to a file. The model only ever sees secrets redacted, so
+// writing back text it read would replace the real secret on disk with the
+// placeholder. Placeholders already in the file, as in tests of the
+// redactor itself, may stay.
+func checkRedactedWrite(path, before, after string) error {
+	added := redactedPlaceholder.FindAllString(after, -1)
+	if len(added) <= len(redactedPlaceholder.FindAllString(before, -1)) {
+		return nil
+	}
+	slog.Warn("refused to write redaction placeholders", "path", path, "placeholder", added[0])
+	return fmt.Errorf("refusing to write %s: the new content contains %s, which stands for a secret redacted from what you read. "+
+		"Writing it would replace the real secret. Change only the lines around the secret with edit_file or line_edit", path, added[0])
+}
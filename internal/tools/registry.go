@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/simonyos/Z-CODE/internal/llm"
 	"github.com/simonyos/Z-CODE/internal/prompts"
@@ -10,7 +11,8 @@ import (
 
 // Registry manages tool registration and execution
 type Registry struct {
-	tools map[string]Tool
+	tools  map[string]Tool
+	policy Policy
 }
 
 // NewRegistry creates a new tool registry
@@ -18,12 +20,27 @@ func NewRegistry() *Registry {
 	return &Registry{tools: make(map[string]Tool)}
 }
 
-// Register adds a tool to the registry
+// Register adds a tool to the registry. Tools the registry's policy does
+// not allow are ignored.
 func (r *Registry) Register(tool Tool) {
 	def := tool.Definition()
+	if !r.policy.Allows(def.Name) {
+		return
+	}
 	r.tools[def.Name] = tool
 }
 
+// SetPolicy restricts the tools the registry accepts, removing registered
+// tools the policy does not allow
+func (r *Registry) SetPolicy(policy Policy) {
+	r.policy = policy
+	for name := range r.tools {
+		if !policy.Allows(name) {
+			delete(r.tools, name)
+		}
+	}
+}
+
 // Get retrieves a tool by name
 func (r *Registry) Get(name string) (Tool, bool) {
 	t, ok := r.tools[name]
@@ -60,7 +77,6 @@ func (r *Registry) GetOpenAIToolDefinitions() []llm.OpenAITool {
 //
 // Limitations: This function only handles basic JSON Schema features used by
 // the built-in tools. The following features are NOT supported:
-//   - items (for array types)
 //   - additionalProperties
 //   - anyOf, oneOf, allOf
 //   - $ref
@@ -100,6 +116,10 @@ func jsonSchemaToMap(schema *JSONSchema) map[string]interface{} {
 		result["enum"] = schema.Enum
 	}
 
+	if schema.Items != nil {
+		result["items"] = jsonSchemaToMap(schema.Items)
+	}
+
 	return result
 }
 
@@ -114,17 +134,43 @@ func (r *Registry) Execute(ctx context.Context, call ToolCall) ToolResult {
 		return ToolResult{Success: false, Error: err.Error()}
 	}
 
-	return tool.Execute(ctx, call.Arguments)
+	result := tool.Execute(ctx, call.Arguments)
+	if call.Repaired {
+		// Let the model check that the repair kept what it meant
+		if result.Output != "" {
+			result.Output += "\n\n"
+		}
+		result.Output += repairedNote
+	}
+	return result
 }
 
+// repairedNote is added to the result of a call whose arguments had to be
+// repaired
+const repairedNote = "Note: the arguments of this call were not valid JSON and were repaired before running; check that the result is what you intended."
+
 // BuildSystemPrompt generates the system prompt for the agent.
 // Tool definitions are passed separately via the native tool calling API.
-// Uses the new Cline-style prompt system with modular components.
-func (r *Registry) BuildSystemPrompt() string {
-	return prompts.BuildSystemPrompt()
+// Uses the new Cline-style prompt system with modular components. The
+// sections dropped to fit prompt_token_budget are returned with it.
+func (r *Registry) BuildSystemPrompt() (string, []string) {
+	return prompts.BuildSystemPrompt(r.toolInfos())
 }
 
 // BuildSystemPromptWithRules generates the system prompt with custom user rules.
-func (r *Registry) BuildSystemPromptWithRules(customRules string) string {
-	return prompts.BuildSystemPromptWithRules(customRules)
+func (r *Registry) BuildSystemPromptWithRules(customRules string) (string, []string) {
+	return prompts.BuildSystemPromptWithRules(r.toolInfos(), customRules)
+}
+
+// toolInfos describes the registered tools for the prompt, sorted by name
+// so the prompt is stable
+func (r *Registry) toolInfos() []prompts.ToolInfo {
+	defs := r.List()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+
+	infos := make([]prompts.ToolInfo, len(defs))
+	for i, def := range defs {
+		infos[i] = prompts.ToolInfo{Name: def.Name, Description: def.Description}
+	}
+	return infos
 }
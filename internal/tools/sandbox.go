@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/ignore"
+)
+
+// errSandboxTooLarge stops a project copy that exceeds MaxCopyBytes
+var errSandboxTooLarge = errors.New("project is too large to copy into the sandbox")
+
+// SandboxTool runs commands in a throwaway directory outside the project
+type SandboxTool struct {
+	BaseTool
+	ConfirmFn    ConfirmFunc
+	Matcher      *ignore.Matcher
+	Redactor     *Redactor
+	Root         string        // Commands may not reference paths outside it, and copy_project copies it; "" for the working directory
+	Commands     CommandPolicy // Commands allowed and denied, as for run_command
+	Timeout      time.Duration
+	MaxOutput    int   // Bytes of output returned to the model
+	MaxCopyBytes int64 // Largest project copied when copy_project is set
+}
+
+// NewSandboxTool creates a new sandbox tool
+func NewSandboxTool(confirmFn ConfirmFunc) *SandboxTool {
+	t := &SandboxTool{
+		ConfirmFn:    confirmFn,
+		Matcher:      defaultMatcher(),
+		Redactor:     DefaultRedactor(),
+		Root:         config.GetCommandRoot(),
+		Commands:     ConfigCommandPolicy(),
+		Timeout:      config.GetCommandTimeout(),
+		MaxOutput:    100000,
+		MaxCopyBytes: 50 << 20,
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "run_in_sandbox",
+				Description: "Run a shell command in a temporary directory that is deleted afterwards. Use it to try out generated scripts or experiments without touching the project. Files written in the sandbox are lost when the command ends.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"command": {
+							Type:        "string",
+							Description: "The shell command to execute inside the sandbox",
+						},
+						"copy_project": {
+							Type:        "boolean",
+							Description: "Copy the project (minus .zcodeignore'd files) into the sandbox first. Defaults to an empty directory.",
+						},
+						"no_network": {
+							Type:        "boolean",
+							Description: "Run without network access (Linux only)",
+						},
+						"timeout_seconds": {
+							Type:        "integer",
+							Description: "Kill the command after this many seconds (default from config, max 600)",
+						},
+					},
+					Required: []string{"command"},
+				},
+			},
+		},
+	}
+	if t.Root != "" {
+		// copy_project copies the root, so ignore rules come from there
+		if m, err := ignore.NewMatcher(t.Root); err == nil {
+			t.Matcher = m
+		}
+		t.Def.Description += fmt.Sprintf(" Commands may not reference paths outside %s, which copy_project copies.", t.Root)
+	}
+	if len(t.Commands.Allow) > 0 {
+		t.Def.Description += fmt.Sprintf(" Only these commands are allowed: %s.", strings.Join(t.Commands.Allow, ", "))
+	}
+	return t
+}
+
+// Execute runs the command in a fresh temporary directory
+func (t *SandboxTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	command, ok := args["command"].(string)
+	if !ok || command == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'command' parameter"}
+	}
+	copyProject, _ := args["copy_project"].(bool)
+	noNetwork, _ := args["no_network"].(bool)
+
+	// The sandbox only isolates the working directory, so the command
+	// policy and confinement apply as they do to run_command
+	if err := checkCommand(command, t.Commands, t.Root); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	timeout, err := commandTimeout(args, t.Timeout)
+	if err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	var wrapper []string
+	if noNetwork {
+		wrapper, err = networkIsolation()
+		if err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+	}
+
+	// Ask for confirmation if a confirm function is provided
+	if t.ConfirmFn != nil {
+		prompt := fmt.Sprintf("Run in sandbox: %s", command)
+		if !t.ConfirmFn(ConfirmRequest{Tool: t.Def.Name, Prompt: prompt}) {
+			return ToolResult{Success: false, Error: "user denied command execution"}
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "zcode-sandbox-")
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to create sandbox: %v", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	if copyProject {
+		src := t.Root
+		if src == "" {
+			if src, err = os.Getwd(); err != nil {
+				return ToolResult{Success: false, Error: fmt.Sprintf("failed to get working directory: %v", err)}
+			}
+		}
+		if err := t.copyProject(src, dir); err != nil {
+			return ToolResult{Success: false, Error: fmt.Sprintf("failed to copy project: %v", err)}
+		}
+	}
+
+	return redactResult(t.Redactor, runShell(ctx, shellCommand{
+		command:   command,
+		wrapper:   wrapper,
+		dir:       dir,
+		env:       sandboxEnv(dir),
+		timeout:   timeout,
+		maxOutput: t.MaxOutput,
+	}))
+}
+
+// copyProject copies regular files from src, the matcher's root, to dst,
+// skipping anything the matcher ignores and stopping once MaxCopyBytes is
+// exceeded
+func (t *SandboxTool) copyProject(src, dst string) error {
+	var total int64
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if d.Name() == ".git" || (t.Matcher != nil && t.Matcher.ShouldIgnore(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil // Skip symlinks, sockets and devices
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if t.MaxCopyBytes > 0 && total > t.MaxCopyBytes {
+			return fmt.Errorf("%w (limit %d bytes)", errSandboxTooLarge, t.MaxCopyBytes)
+		}
+
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile copies a single file, creating dst with the given permissions
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sandboxEnv returns the current environment with HOME and the temp
+// directory pointed into the sandbox, so tools don't write outside it
+func sandboxEnv(dir string) []string {
+	env := os.Environ()
+	return append(env, "HOME="+dir, "TMPDIR="+dir, "TMP="+dir, "TEMP="+dir, "PWD="+dir)
+}
+
+// networkIsolation returns a wrapper that runs the shell in a new network
+// namespace with only a loopback interface
+func networkIsolation() ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("no_network is not supported on %s", runtime.GOOS)
+	}
+	path, err := exec.LookPath("unshare")
+	if err != nil {
+		return nil, errors.New("no_network requires the unshare command")
+	}
+	return []string{path, "--map-root-user", "--net"}, nil
+}
@@ -7,6 +7,7 @@ type JSONSchema struct {
 	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
 	Required    []string               `json:"required,omitempty"`
 	Enum        []string               `json:"enum,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"` // Element schema for array types
 }
 
 // ToolDefinition is the structured tool definition (like OpenAI)
@@ -23,6 +24,7 @@ type ToolCall struct {
 	ID        string         `json:"id"`
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
+	Repaired  bool           `json:"-"` // The arguments were malformed JSON fixed by RepairJSON
 }
 
 // ToolResult represents the output of a tool execution
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/simonyos/Z-CODE/internal/llm"
+)
+
+// TodoItem is one task in a todo list
+type TodoItem struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// TodoList is the task list the model keeps for a session. It lives in
+// memory and is cleared with the conversation.
+type TodoList struct {
+	mu     sync.Mutex
+	items  []TodoItem
+	nextID int
+}
+
+// NewTodoList creates an empty in-memory todo list
+func NewTodoList() *TodoList {
+	return &TodoList{nextID: 1}
+}
+
+// Add appends tasks and returns the new items
+func (l *TodoList) Add(texts ...string) []TodoItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var added []TodoItem
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		item := TodoItem{ID: l.nextID, Text: text}
+		l.nextID++
+		l.items = append(l.items, item)
+		added = append(added, item)
+	}
+	return added
+}
+
+// Complete marks the task with the given ID as done
+func (l *TodoList) Complete(id int) (TodoItem, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.items {
+		if l.items[i].ID == id {
+			l.items[i].Done = true
+			return l.items[i], nil
+		}
+	}
+	return TodoItem{}, fmt.Errorf("no task with id %d", id)
+}
+
+// Clear removes every task
+func (l *TodoList) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.items = nil
+	l.nextID = 1
+}
+
+// Items returns a copy of the list in the order tasks were added
+func (l *TodoList) Items() []TodoItem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]TodoItem(nil), l.items...)
+}
+
+// Remaining returns the tasks not yet completed
+func (l *TodoList) Remaining() []TodoItem {
+	var remaining []TodoItem
+	for _, item := range l.Items() {
+		if !item.Done {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining
+}
+
+// Reminder returns the "Remaining tasks" note added to each request, or ""
+// when nothing is left to do
+func (l *TodoList) Reminder() string {
+	remaining := l.Remaining()
+	if len(remaining) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Remaining tasks:")
+	for _, item := range remaining {
+		sb.WriteString(fmt.Sprintf("\n%d. %s", item.ID, item.Text))
+	}
+	return sb.String()
+}
+
+// WithReminder returns msgs with the open tasks appended to the last
+// message, so the plan stays in view on every request without being
+// stored in the history. msgs itself is not modified.
+func (l *TodoList) WithReminder(msgs []llm.Message) []llm.Message {
+	if l == nil || len(msgs) == 0 {
+		return msgs
+	}
+	reminder := l.Reminder()
+	if reminder == "" {
+		return msgs
+	}
+
+	out := append([]llm.Message(nil), msgs...)
+	last := &out[len(out)-1]
+	last.Content += "\n\n<todo>\n" + reminder + "\n</todo>"
+	return out
+}
+
+// String formats the full list with a checkbox per task
+func (l *TodoList) String() string {
+	items := l.Items()
+	if len(items) == 0 {
+		return "No tasks."
+	}
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		mark := " "
+		if item.Done {
+			mark = "x"
+		}
+		lines[i] = fmt.Sprintf("[%s] %d. %s", mark, item.ID, item.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TodoTool lets the model maintain a visible plan for multi-step tasks
+type TodoTool struct {
+	BaseTool
+	List *TodoList
+}
+
+// NewTodoTool creates a todo tool backed by list
+func NewTodoTool(list *TodoList) *TodoTool {
+	return &TodoTool{
+		List: list,
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "todo",
+				Description: "Track the subtasks of a multi-step task. Add the steps when you start, complete each one as soon as it is done, and list them to check what is left. Remaining tasks are shown to you with every request.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"action": {
+							Type:        "string",
+							Description: "What to do with the list",
+							Enum:        []string{"add", "complete", "list"},
+						},
+						"items": {
+							Type:        "array",
+							Description: "Tasks to add (for add)",
+							Items:       &JSONSchema{Type: "string"},
+						},
+						"id": {
+							Type:        "integer",
+							Description: "ID of the task to mark done (for complete)",
+						},
+					},
+					Required: []string{"action"},
+				},
+			},
+		},
+	}
+}
+
+// Execute applies the requested action and returns the updated list
+func (t *TodoTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	if t.List == nil {
+		return ToolResult{Success: false, Error: "todo list is unavailable"}
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "add":
+		raw, _ := args["items"].([]any)
+		texts := make([]string, 0, len(raw))
+		for _, item := range raw {
+			if text, ok := item.(string); ok {
+				texts = append(texts, text)
+			}
+		}
+		if added := t.List.Add(texts...); len(added) == 0 {
+			return ToolResult{Success: false, Error: "missing or invalid 'items' parameter: expected a non-empty array of strings"}
+		}
+
+	case "complete":
+		id, ok := intArg(args, "id")
+		if !ok {
+			return ToolResult{Success: false, Error: "missing or invalid 'id' parameter"}
+		}
+		if _, err := t.List.Complete(id); err != nil {
+			return ToolResult{Success: false, Error: err.Error()}
+		}
+
+	case "list":
+
+	default:
+		return ToolResult{Success: false, Error: fmt.Sprintf("unknown action %q: use add, complete or list", action)}
+	}
+
+	return ToolResult{Success: true, Output: t.List.String()}
+}
@@ -3,6 +3,11 @@ package tools
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/simonyos/Z-CODE/internal/ignore"
 )
 
 // Tool is the interface all tools must implement
@@ -39,3 +44,109 @@ func (b *BaseTool) Validate(args map[string]any) error {
 	}
 	return nil
 }
+
+// validatePath checks a path against .zcodeignore rules.
+// A nil matcher allows every path.
+func validatePath(m *ignore.Matcher, path string) error {
+	if m == nil {
+		return nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return &ignore.PathResolutionError{Path: path, Err: err}
+	}
+	return m.ValidatePath(absPath)
+}
+
+// validateTree checks every path under the directory src against the
+// ignore rules, along with where it would land under dst unless dst is
+// "". A nil matcher allows every path.
+func validateTree(m *ignore.Matcher, src, dst string) error {
+	if m == nil {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if err := validatePath(m, path); err != nil {
+			return err
+		}
+		if dst == "" {
+			return nil
+		}
+		return validatePath(m, filepath.Join(dst, rel))
+	})
+}
+
+// defaultMatcher returns the matcher for the working directory, or nil
+// if it cannot be built.
+func defaultMatcher() *ignore.Matcher {
+	m, err := ignore.DefaultMatcher()
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// searchMatcher builds the matcher for a grep or glob search rooted at
+// path, which must be absolute. Inside the working directory the rules
+// are resolved from the working directory, so a search rooted in an
+// ignored directory such as .git or node_modules/pkg is refused rather
+// than walked. A directory outside it is its own root, checked against
+// its parent's rules.
+func searchMatcher(path string, isDir bool) (*ignore.Matcher, error) {
+	base := path
+	if !isDir {
+		base = filepath.Dir(path)
+	}
+	inCwd := false
+	if cwd, err := os.Getwd(); err == nil && withinRoot(path, cwd) {
+		base, inCwd = cwd, true
+	}
+	m, err := ignore.NewMatcher(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load .zcodeignore: %w", err)
+	}
+	check := m
+	if !inCwd && isDir {
+		if check, err = ignore.NewMatcher(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("failed to load .zcodeignore: %w", err)
+		}
+	}
+	if err := check.ValidatePath(path); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// intArg reads an integer argument. JSON numbers decode as float64.
+func intArg(args map[string]any, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// OutputFunc receives incremental output from long-running tools
+type OutputFunc func(chunk string)
+
+type outputFuncKey struct{}
+
+// WithOutputFunc returns a context that streams tool output to fn
+func WithOutputFunc(ctx context.Context, fn OutputFunc) context.Context {
+	return context.WithValue(ctx, outputFuncKey{}, fn)
+}
+
+// OutputFuncFrom returns the output callback stored in ctx, or nil
+func OutputFuncFrom(ctx context.Context) OutputFunc {
+	fn, _ := ctx.Value(outputFuncKey{}).(OutputFunc)
+	return fn
+}
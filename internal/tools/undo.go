@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// UndoTool reverts the most recent file change recorded in the journal
+type UndoTool struct {
+	BaseTool
+	ConfirmFn ConfirmFunc
+	Journal   *Journal
+}
+
+// NewUndoTool creates a new undo tool
+func NewUndoTool(confirmFn ConfirmFunc) *UndoTool {
+	return &UndoTool{
+		ConfirmFn: confirmFn,
+		Journal:   DefaultJournal(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "undo_last_edit",
+				Description: "Revert the most recent change made by write_file, edit_file, move_file or delete_file, restoring the previous version from backup. Call it repeatedly to step further back.",
+				Parameters: &JSONSchema{
+					Type:       "object",
+					Properties: map[string]*JSONSchema{},
+					Required:   []string{},
+				},
+			},
+		},
+	}
+}
+
+// Execute restores the last journaled change
+func (t *UndoTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	if t.Journal == nil {
+		return ToolResult{Success: false, Error: "undo is unavailable: no backup journal"}
+	}
+
+	entries := t.Journal.Entries()
+	if len(entries) == 0 {
+		return ToolResult{Success: false, Error: ErrNothingToUndo.Error()}
+	}
+
+	// Ask for confirmation if a confirm function is provided
+	if t.ConfirmFn != nil {
+		last := entries[len(entries)-1]
+		prompt := fmt.Sprintf("Undo %s on %s", last.Tool, last.Path)
+		if !t.ConfirmFn(ConfirmRequest{Tool: t.Def.Name, Prompt: prompt, Path: last.Path}) {
+			return ToolResult{Success: false, Error: "user denied undo"}
+		}
+	}
+
+	summary, err := t.Journal.Undo()
+	if err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	return ToolResult{Success: true, Output: summary}
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+)
+
+// errPrivateAddress is returned when a fetch resolves to a blocked address
+var errPrivateAddress = errors.New("address is private or loopback; set web_fetch_allow_private to allow internal hosts")
+
+// cgnatRange is the carrier-grade NAT block, which net.IP.IsPrivate does not cover
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebFetchTool fetches a URL and returns its content as text
+type WebFetchTool struct {
+	BaseTool
+	Timeout      time.Duration
+	MaxBytes     int
+	AllowPrivate bool // Allow private, loopback and link-local addresses
+}
+
+// NewWebFetchTool creates a new web fetch tool
+func NewWebFetchTool() *WebFetchTool {
+	return &WebFetchTool{
+		Timeout:      30 * time.Second,
+		MaxBytes:     100000,
+		AllowPrivate: config.GetWebFetchAllowPrivate(),
+		BaseTool: BaseTool{
+			Def: ToolDefinition{
+				Name:        "web_fetch",
+				Description: "Fetch a URL over HTTP(S) and return its content. HTML is converted to readable text; JSON and plain text are returned as-is.",
+				Parameters: &JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"url": {
+							Type:        "string",
+							Description: "The http or https URL to fetch",
+						},
+						"max_bytes": {
+							Type:        "integer",
+							Description: "Maximum number of bytes of content to return (default 100000)",
+						},
+					},
+					Required: []string{"url"},
+				},
+			},
+		},
+	}
+}
+
+// Execute fetches the URL
+func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) ToolResult {
+	rawURL, ok := args["url"].(string)
+	if !ok || rawURL == "" {
+		return ToolResult{Success: false, Error: "missing or invalid 'url' parameter"}
+	}
+
+	maxBytes := t.MaxBytes
+	if v, ok := args["max_bytes"].(float64); ok && int(v) > 0 && int(v) < maxBytes {
+		maxBytes = int(v)
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("invalid url: %v", err)}
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ToolResult{Success: false, Error: "only http and https URLs are supported"}
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to create request: %v", err)}
+	}
+	req.Header.Set("User-Agent", "zcode-web-fetch")
+
+	resp, err := t.client().Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return ToolResult{Success: false, Error: fmt.Sprintf("blocked %s: %v", parsed.Host, errPrivateAddress)}
+		}
+		if fetchCtx.Err() == context.DeadlineExceeded {
+			return ToolResult{Success: false, Error: "request timed out"}
+		}
+		return ToolResult{Success: false, Error: fmt.Sprintf("request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	// Read one byte past the limit to detect truncation
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to read response: %v", err)}
+	}
+	truncated := len(body) > maxBytes
+	if truncated {
+		body = body[:maxBytes]
+	}
+
+	content := string(body)
+	contentType := resp.Header.Get("Content-Type")
+	if strings.Contains(contentType, "html") {
+		content = htmlToText(content)
+	}
+	if truncated {
+		content += fmt.Sprintf("\n\n... (truncated to %d bytes)", maxBytes)
+	}
+
+	if resp.StatusCode >= 400 {
+		return ToolResult{
+			Success: false,
+			Output:  content,
+			Error:   fmt.Sprintf("HTTP %s", resp.Status),
+		}
+	}
+
+	return ToolResult{Success: true, Output: content}
+}
+
+// client builds an HTTP client whose dialer rejects private addresses.
+// Checking at dial time covers redirects and DNS answers alike.
+func (t *WebFetchTool) client() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !t.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: t.Timeout,
+		Transport: &http.Transport{
+			Proxy:               nil, // A proxy would hide the real destination from the dial check
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// isPrivateIP reports whether ip is loopback, private, link-local or unspecified
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() ||
+		cgnatRange.Contains(ip)
+}
+
+var (
+	htmlDropBlocks = regexp.MustCompile(`(?is)<(script|style|noscript|head|svg)\b.*?</(script|style|noscript|head|svg)>`)
+	htmlComments   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlBreaks     = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6]|/pre|/blockquote|/section|/article)\b[^>]*>`)
+	htmlListItems  = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	htmlTags       = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlSpaces     = regexp.MustCompile(`[ \t\f\v]+`)
+	htmlBlankLines = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText strips markup from an HTML document and returns readable text
+func htmlToText(s string) string {
+	s = htmlDropBlocks.ReplaceAllString(s, "")
+	s = htmlComments.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = htmlListItems.ReplaceAllString(s, "\n- ")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = htmlSpaces.ReplaceAllString(s, " ")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	s = strings.Join(lines, "\n")
+	s = htmlBlankLines.ReplaceAllString(s, "\n\n")
+
+	return strings.TrimSpace(s)
+}
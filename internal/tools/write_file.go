@@ -2,23 +2,43 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 )
 
+// ConfirmRequest describes an action that needs the user's approval
+type ConfirmRequest struct {
+	Tool   string // Name of the tool asking, e.g. "write_file"
+	Prompt string // One-line description of the action
+	Path   string // File the action changes, if any
+	Diff   string // Unified diff of the proposed change, if any
+}
+
+// String renders the request as plain text, with the diff below the prompt
+func (r ConfirmRequest) String() string {
+	if r.Diff == "" {
+		return r.Prompt
+	}
+	return r.Prompt + "\n" + r.Diff
+}
+
 // ConfirmFunc is a function that asks for user confirmation
-type ConfirmFunc func(prompt string) bool
+type ConfirmFunc func(req ConfirmRequest) bool
 
 // WriteFileTool writes content to a file
 type WriteFileTool struct {
 	BaseTool
 	ConfirmFn ConfirmFunc
+	Journal   *Journal
 }
 
 // NewWriteFileTool creates a new write file tool
 func NewWriteFileTool(confirmFn ConfirmFunc) *WriteFileTool {
 	return &WriteFileTool{
 		ConfirmFn: confirmFn,
+		Journal:   DefaultJournal(),
 		BaseTool: BaseTool{
 			Def: ToolDefinition{
 				Name:        "write_file",
@@ -47,16 +67,33 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) ToolRe
 	path, _ := args["path"].(string)
 	content, _ := args["content"].(string)
 
+	existing, err := os.ReadFile(path)
+	created := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !created {
+		return ToolResult{Success: false, Error: fmt.Sprintf("failed to read existing file: %v", err)}
+	}
+	if err := checkRedactedWrite(path, string(existing), content); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
 	// Ask for confirmation if a confirm function is provided
 	if t.ConfirmFn != nil {
-		prompt := fmt.Sprintf("Write to file: %s (%d bytes)", path, len(content))
-		if !t.ConfirmFn(prompt) {
+		req := ConfirmRequest{
+			Tool:   t.Def.Name,
+			Prompt: fmt.Sprintf("Write to file: %s (%d bytes)", path, len(content)),
+			Path:   path,
+			Diff:   unifiedDiff(path, string(existing), content, created),
+		}
+		if !t.ConfirmFn(req) {
 			return ToolResult{Success: false, Error: "user denied write permission"}
 		}
 	}
 
-	err := os.WriteFile(path, []byte(content), 0644)
-	if err != nil {
+	if err := recordUndo(t.Journal, t.Def.Name, path); err != nil {
+		return ToolResult{Success: false, Error: err.Error()}
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return ToolResult{Success: false, Error: err.Error()}
 	}
 
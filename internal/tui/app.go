@@ -2,9 +2,13 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
@@ -14,7 +18,10 @@ import (
 	"github.com/simonyos/Z-CODE/internal/agents"
 	"github.com/simonyos/Z-CODE/internal/config"
 	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/logging"
+	"github.com/simonyos/Z-CODE/internal/prompts"
 	"github.com/simonyos/Z-CODE/internal/skills"
+	"github.com/simonyos/Z-CODE/internal/tools"
 	"github.com/simonyos/Z-CODE/internal/tui/components"
 	"github.com/simonyos/Z-CODE/internal/tui/layout"
 	"github.com/simonyos/Z-CODE/internal/tui/theme"
@@ -23,6 +30,10 @@ import (
 
 const version = "0.1.0"
 
+// modelsTimeout bounds how long /models waits for the providers, and
+// /model for the check of the new model
+const modelsTimeout = 15 * time.Second
+
 // Layout constants for consistent height calculations
 const (
 	layoutHeaderHeight = 2 // Header row + separator line
@@ -33,8 +44,9 @@ const (
 
 // Message types for Bubble Tea
 type responseMsg struct {
-	result *agent.ChatResult
-	err    error
+	result  *agent.ChatResult
+	err     error
+	partial string // Streamed before err, kept in the agent's history
 }
 
 // Streaming message types
@@ -49,6 +61,10 @@ type streamToolStartMsg struct {
 	args string
 }
 
+type streamToolOutputMsg struct {
+	text string
+}
+
 type streamToolResultMsg struct {
 	name    string
 	result  string
@@ -59,6 +75,18 @@ type streamDoneMsg struct {
 	finalResponse string
 }
 
+// modelsMsg carries the formatted output of /models
+type modelsMsg struct {
+	content string
+}
+
+// modelSwitchMsg carries a provider for /model, and why it cannot be used
+type modelSwitchMsg struct {
+	provider llm.Provider
+	model    string
+	err      error
+}
+
 // Model is the main TUI model
 type Model struct {
 	agent *agent.Agent
@@ -70,6 +98,8 @@ type Model struct {
 	status      *components.Status
 	help        *components.HelpDialog
 	suggestions *components.Suggestions
+	inputPrompt *components.InputPrompt
+	confirm     *components.ConfirmPrompt
 	spinner     spinner.Model
 
 	// Layout
@@ -89,11 +119,16 @@ type Model struct {
 	height           int
 	ready            bool
 	thinking         bool
+	activity         string    // What the agent is doing, shown beside the spinner
+	toolStarted      time.Time // When the running tool started
 	showHelp         bool
-	streamingContent string                    // Accumulates streaming response
-	eventChan        <-chan agent.StreamEvent  // Channel for streaming events
-	customEventChan  <-chan agents.StreamEvent // Channel for custom agent streaming
-	skillEventChan   <-chan skills.StreamEvent // Channel for skill streaming
+	streamingContent string                       // Accumulates streaming response
+	eventChan        <-chan agent.StreamEvent     // Channel for streaming events
+	customEventChan  <-chan agents.StreamEvent    // Channel for custom agent streaming
+	skillEventChan   <-chan skills.StreamEvent    // Channel for skill streaming
+	workflowEvents   <-chan workflows.StreamEvent // Channel for workflow streaming
+	humanInput       chan string                  // Replies to human workflow steps
+	confirmReply     chan<- bool                  // Answers the tool action being confirmed
 }
 
 // New creates a new TUI model
@@ -125,11 +160,14 @@ func New(ag *agent.Agent, modelName string) Model {
 		status:           status,
 		help:             components.NewHelpDialog(),
 		suggestions:      suggestions,
+		inputPrompt:      components.NewInputPrompt(),
+		confirm:          components.NewConfirmPrompt(),
 		spinner:          sp,
 		agentRegistry:    agentReg,
 		workflowRegistry: workflowReg,
 		skillRegistry:    skillReg,
 		provider:         ag.Provider(),
+		humanInput:       make(chan string),
 	}
 
 	// Set up command provider for dynamic suggestions
@@ -202,13 +240,22 @@ func welcomeMessage() string {
 
 // Init initializes the TUI
 func (m Model) Init() tea.Cmd {
-	return tea.EnterAltScreen
+	return tea.Batch(tea.EnterAltScreen, waitForConfirm())
 }
 
 // Update handles messages
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// Drop events of a turn canceled with Esc, so a late done or error
+	// cannot end the next turn or start a second reader on its channel
+	if event, ok := msg.(agentEventMsg); ok {
+		if event.events != m.eventChan {
+			return m, nil
+		}
+		msg = event.msg
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		// Handle help dialog
@@ -217,6 +264,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// A tool is waiting for approval; other keys are ignored
+		if m.confirmReply != nil {
+			switch msg.String() {
+			case "y", "Y":
+				return m.answerConfirm(true)
+			case "n", "N", "esc":
+				return m.answerConfirm(false)
+			case "ctrl+c":
+				m.confirmReply <- false
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
@@ -230,7 +291,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.messages.Clear()
 			return m, nil
 
+		case "ctrl+o":
+			// Expand or collapse long tool output
+			m.messages.ToggleToolOutput()
+			return m, nil
+
 		case "esc":
+			if m.eventChan != nil && m.agent.Cancel() {
+				m.interruptTurn()
+				return m, nil
+			}
 			if m.showHelp {
 				m.showHelp = false
 			}
@@ -263,6 +333,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "enter":
+			// A paused workflow step takes the editor content as its reply
+			if m.inputPrompt.IsVisible() && strings.TrimSpace(m.editor.Value()) != "" {
+				reply := strings.TrimSpace(m.editor.Value())
+				m.editor.Reset()
+				m.suggestions.Hide()
+				m.inputPrompt.Hide()
+				m.messages.AddMessage(components.Message{
+					Role:    "user",
+					Content: reply,
+				})
+				m.thinking = true
+				m.status.SetThinking(true)
+				return m, tea.Batch(m.spinner.Tick, m.sendHumanInput(reply))
+			}
+
 			// If suggestions visible and selected, use that command
 			if m.suggestions.IsVisible() {
 				selected := m.suggestions.GetSelected()
@@ -289,7 +374,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				})
 				m.thinking = true
 				m.status.SetThinking(true)
-				return m, tea.Batch(m.spinner.Tick, m.sendMessage(userMsg))
+				return m, tea.Batch(m.spinner.Tick, m.sendMessage(m.expandPrevVariables(userMsg)))
 			}
 
 		case "pgup", "pgdown":
@@ -300,6 +385,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
+	case tea.MouseMsg:
+		// Only sent when mouse capture is on; the wheel scrolls the chat
+		if m.ready && msg.Action == tea.MouseActionPress && (msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown) {
+			vp := m.messages.GetViewport()
+			var cmd tea.Cmd
+			*vp, cmd = vp.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -311,6 +405,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.layout = layout.NewSplitPane(msg.Width, msg.Height)
 			m.messages = components.NewMessages(msg.Width, messagesHeight)
 			m.messages.SetWelcome(welcomeMessage())
+			if note, warn := projectContextNote(); warn {
+				m.messages.AddMessage(components.Message{
+					Role:    "system",
+					Content: note,
+				})
+			}
+			if note := warningsNote(); note != "" {
+				m.messages.AddMessage(components.Message{
+					Role:    "system",
+					Content: note,
+				})
+			}
 			m.editor = components.NewEditor(msg.Width, layoutEditorHeight)
 			// Clear any garbage that may have accumulated before init
 			m.editor.Reset()
@@ -333,13 +439,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case responseMsg:
 		m.thinking = false
+		m.activity = ""
 		m.status.SetThinking(false)
 		m.eventChan = nil
 
 		if msg.err != nil {
+			content := llm.ErrorMessage(msg.err)
+			if msg.partial != "" {
+				m.messages.ClearStreaming()
+				m.streamingContent = ""
+				m.messages.AddMessage(components.Message{
+					Role:    "assistant",
+					Content: msg.partial,
+				})
+				content += "\nThe partial response above was kept in the conversation. Use /retry to try again."
+			}
 			m.messages.AddMessage(components.Message{
 				Role:    "error",
-				Content: msg.err.Error(),
+				Content: content,
 			})
 		} else if msg.result != nil {
 			// Add tool executions first
@@ -378,6 +495,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case streamChunkMsg:
 		// Accumulate streaming content and update display
+		m.activity = ""
 		m.streamingContent += msg.text
 		m.messages.UpdateStreaming(m.streamingContent)
 		if m.eventChan != nil {
@@ -385,6 +503,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case streamToolStartMsg:
+		m.activity = toolActivity(msg.name, msg.args)
+		m.toolStarted = time.Now()
+
 		// Clear streaming content (it was a tool call, not final response)
 		m.streamingContent = ""
 		m.messages.ClearStreaming()
@@ -399,19 +520,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, readNextEvent(m.eventChan))
 		}
 
+	case streamToolOutputMsg:
+		// Show live output under the running tool
+		m.messages.AppendLastToolOutput(msg.text)
+		if m.eventChan != nil {
+			cmds = append(cmds, readNextEvent(m.eventChan))
+		}
+
 	case streamToolResultMsg:
+		m.activity = fmt.Sprintf("%s finished in %s", msg.name, time.Since(m.toolStarted).Round(100*time.Millisecond))
+
 		// Update the last tool message with result
 		result := msg.result
 		if msg.isError {
 			result = "Error: " + msg.result
 		}
 		m.messages.UpdateLastToolResult(result)
+		if msg.name == "todo" {
+			m.refreshTasks()
+		}
 		if m.eventChan != nil {
 			cmds = append(cmds, readNextEvent(m.eventChan))
 		}
 
 	case streamDoneMsg:
 		m.thinking = false
+		m.activity = ""
 		m.status.SetThinking(false)
 		m.eventChan = nil
 		m.messages.ClearStreaming()
@@ -448,25 +582,98 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Continue reading skill events after unknown event type
 		cmds = append(cmds, readNextSkillEvent(msg.events))
 
-	// Workflow result handler
+	// Workflow event handlers
+	case workflowEventChanMsg:
+		m.workflowEvents = msg.events
+		cmds = append(cmds, readNextWorkflowEvent(m.workflowEvents))
+
+	case workflowContinueMsg:
+		cmds = append(cmds, readNextWorkflowEvent(msg.events))
+
+	case workflowStepMsg:
+		// Show live progress of each step and loop iteration
+		args := workflowStepArgs(msg.event)
+		if msg.event.Type == "step_start" {
+			m.activity = "Step " + args
+			m.messages.AddMessage(components.Message{
+				Role:     "tool",
+				ToolName: "step",
+				ToolArgs: args,
+				Content:  "Running...",
+			})
+		} else {
+			m.messages.UpdateToolResult("step", args, workflowStepSummary(msg.event.StepResult))
+		}
+		if m.workflowEvents != nil {
+			cmds = append(cmds, readNextWorkflowEvent(m.workflowEvents))
+		}
+
+	case confirmMsg:
+		// The tool waits until the user answers
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: "Approval needed: " + msg.req.String(),
+		})
+		m.confirm.Show(msg.req.Prompt, msg.req.Diff)
+		m.confirmReply = msg.reply
+
+	case workflowHumanInputMsg:
+		// The workflow is paused until the user replies
+		m.thinking = false
+		m.status.SetThinking(false)
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: fmt.Sprintf("Step %s is waiting for your reply:\n\n%s", msg.stepName, msg.prompt),
+		})
+		m.inputPrompt.Show(msg.stepName, msg.prompt)
+
+	case modelsMsg:
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: msg.content,
+		})
+
+	case modelSwitchMsg:
+		m.switchModel(msg)
+
 	case workflowResultMsg:
 		m.thinking = false
+		m.activity = ""
 		m.status.SetThinking(false)
+		m.workflowEvents = nil
+		m.inputPrompt.Hide()
 
 		if msg.err != nil {
 			m.messages.AddMessage(components.Message{
 				Role:    "error",
-				Content: "Workflow error: " + msg.err.Error(),
+				Content: "Workflow error: " + llm.ErrorMessage(msg.err),
 			})
 		} else if msg.result != nil {
 			var sb strings.Builder
 			sb.WriteString(fmt.Sprintf("Workflow completed: %s\n", msg.result.WorkflowName))
 			sb.WriteString(fmt.Sprintf("Success: %v\n", msg.result.Success))
 			sb.WriteString(fmt.Sprintf("Steps executed: %d\n", len(msg.result.StepResults)))
+			for _, step := range msg.result.StepResults {
+				if step.Attempts > 1 {
+					sb.WriteString(fmt.Sprintf("  %s: %d attempts\n", step.StepName, step.Attempts))
+				}
+			}
+			if len(msg.result.FailedSteps) > 0 {
+				sb.WriteString(fmt.Sprintf("Failed steps: %s\n", strings.Join(msg.result.FailedSteps, ", ")))
+			}
 			if msg.result.FinalOutput != "" {
 				sb.WriteString("\nFinal output:\n")
 				sb.WriteString(msg.result.FinalOutput)
 			}
+			if msg.result.Success && len(msg.result.Variables) > 0 {
+				names := make([]string, 0, len(msg.result.Variables))
+				for name := range msg.result.Variables {
+					names = append(names, "{prev."+name+"}")
+				}
+				sort.Strings(names)
+				sb.WriteString("\n\nAvailable to your next message or workflow: ")
+				sb.WriteString(strings.Join(names, ", "))
+			}
 			m.messages.AddMessage(components.Message{
 				Role:    "assistant",
 				Content: sb.String(),
@@ -505,11 +712,38 @@ func (m *Model) sendMessage(content string) tea.Cmd {
 	}
 }
 
+// interruptTurn stops showing the canceled turn and re-enables input. The
+// rest of its events are drained so the agent's goroutine can finish.
+func (m *Model) interruptTurn() {
+	go func(events <-chan agent.StreamEvent) {
+		for range events {
+		}
+	}(m.eventChan)
+
+	m.eventChan = nil
+	m.thinking = false
+	m.activity = ""
+	m.status.SetThinking(false)
+	m.streamingContent = ""
+	m.messages.ClearStreaming()
+	m.messages.AddMessage(components.Message{
+		Role:    "system",
+		Content: "Interrupted",
+	})
+}
+
 // streamEventChanMsg carries the event channel
 type streamEventChanMsg struct {
 	events <-chan agent.StreamEvent
 }
 
+// agentEventMsg wraps a message read from an agent event channel, so that
+// messages of a canceled turn can be recognized
+type agentEventMsg struct {
+	events <-chan agent.StreamEvent
+	msg    tea.Msg
+}
+
 // streamContinueMsg signals to continue reading events for unhandled event types
 type streamContinueMsg struct {
 	events <-chan agent.StreamEvent
@@ -518,36 +752,43 @@ type streamContinueMsg struct {
 // readNextEvent reads the next event from the channel
 func readNextEvent(events <-chan agent.StreamEvent) tea.Cmd {
 	return func() tea.Msg {
-		event, ok := <-events
-		if !ok {
-			// Channel closed
-			return streamDoneMsg{}
-		}
+		return agentEventMsg{events: events, msg: nextEventMsg(events)}
+	}
+}
 
-		switch event.Type {
-		case "start":
-			return streamStartMsg{}
-		case "chunk":
-			return streamChunkMsg{text: event.Text}
-		case "tool_start":
-			return streamToolStartMsg{name: event.ToolName, args: event.ToolArgs}
-		case "tool_result":
-			return streamToolResultMsg{
-				name:    event.ToolName,
-				result:  event.ToolResult,
-				isError: event.ToolError,
-			}
-		case "done":
-			return streamDoneMsg{finalResponse: event.FinalResponse}
-		case "error":
-			return responseMsg{err: event.Error}
-		case "tool_batch_start", "tool_batch_end":
-			// Skip batch markers, continue reading next event
-			return streamContinueMsg{events: events}
-		default:
-			// Unknown event type, continue reading
-			return streamContinueMsg{events: events}
+// nextEventMsg waits for the next event and converts it to a message
+func nextEventMsg(events <-chan agent.StreamEvent) tea.Msg {
+	event, ok := <-events
+	if !ok {
+		// Channel closed
+		return streamDoneMsg{}
+	}
+
+	switch event.Type {
+	case "start":
+		return streamStartMsg{}
+	case "chunk":
+		return streamChunkMsg{text: event.Text}
+	case "tool_start":
+		return streamToolStartMsg{name: event.ToolName, args: event.ToolArgs}
+	case "tool_output":
+		return streamToolOutputMsg{text: event.Text}
+	case "tool_result":
+		return streamToolResultMsg{
+			name:    event.ToolName,
+			result:  event.ToolResult,
+			isError: event.ToolError,
 		}
+	case "done":
+		return streamDoneMsg{finalResponse: event.FinalResponse}
+	case "error":
+		return responseMsg{err: event.Error, partial: event.Partial}
+	case "tool_batch_start", "tool_batch_end":
+		// Skip batch markers, continue reading next event
+		return streamContinueMsg{events: events}
+	default:
+		// Unknown event type, continue reading
+		return streamContinueMsg{events: events}
 	}
 }
 
@@ -681,26 +922,80 @@ func (m Model) handleCommand(input string) (tea.Model, tea.Cmd) {
 	case "/reset":
 		m.messages.Clear()
 		m.agent.Reset()
+		m.refreshTasks()
+		content := "Conversation reset."
+		if note, _ := projectContextNote(); note != "" {
+			content += "\n" + note
+		}
+		if note := warningsNote(); note != "" {
+			content += "\n" + note
+		}
 		m.messages.AddMessage(components.Message{
 			Role:    "system",
-			Content: "Conversation reset.",
+			Content: content,
 		})
 		return m, nil
 
 	case "/tools":
+		return m.listTools()
+
+	case "/retry":
+		if m.thinking {
+			m.messages.AddMessage(components.Message{
+				Role:    "error",
+				Content: "Cannot retry while a response is in progress.",
+			})
+			return m, nil
+		}
+		prompt, err := m.agent.Retry()
+		if err != nil {
+			m.messages.AddMessage(components.Message{
+				Role:    "error",
+				Content: fmt.Sprintf("Retry failed: %v", err),
+			})
+			return m, nil
+		}
 		m.messages.AddMessage(components.Message{
-			Role: "system",
-			Content: `Available tools:
-  read_file   - Read file contents
-  write_file  - Create or modify files
-  edit_file   - Edit files with find/replace
-  list_dir    - List directory contents
-  run_command - Execute shell commands
-  glob        - Find files by pattern
-  grep        - Search file contents`,
+			Role:    "system",
+			Content: "Retrying the last message...",
+		})
+		m.thinking = true
+		m.status.SetThinking(true)
+		return m, tea.Batch(m.spinner.Tick, m.sendMessage(prompt))
+
+	case "/edit":
+		if m.thinking {
+			m.messages.AddMessage(components.Message{
+				Role:    "error",
+				Content: "Cannot edit while a response is in progress.",
+			})
+			return m, nil
+		}
+		prompt, err := m.agent.RewindTo(m.agent.Turns() - 1)
+		if err != nil {
+			m.messages.AddMessage(components.Message{
+				Role:    "error",
+				Content: fmt.Sprintf("Nothing to edit: %v", err),
+			})
+			return m, nil
+		}
+		m.editor.SetValue(prompt)
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: "Your last message is back in the editor; it and its response were removed from the conversation.",
 		})
 		return m, nil
 
+	case "/models":
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: "Fetching models...",
+		})
+		return m, m.listModels(strings.Join(parts[1:], " "))
+
+	case "/model":
+		return m.handleModelCommand(parts[1:])
+
 	case "/agents":
 		return m.listAgents()
 
@@ -710,17 +1005,50 @@ func (m Model) handleCommand(input string) (tea.Model, tea.Cmd) {
 	case "/workflows":
 		return m.listWorkflows()
 
+	case "/todo":
+		content := "No task list for this agent."
+		if todos := m.agent.Todos(); todos != nil {
+			content = "Tasks:\n" + todos.String()
+		}
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: content,
+		})
+		return m, nil
+
+	case "/undo":
+		summary, err := undoLastEdit()
+		if err != nil {
+			m.messages.AddMessage(components.Message{
+				Role:    "error",
+				Content: fmt.Sprintf("Undo failed: %v", err),
+			})
+			return m, nil
+		}
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: summary,
+		})
+		return m, nil
+
 	case "/quit", "/exit", "/q":
 		return m, tea.Quit
 
 	case "/config":
 		// Handle /config command
-		if len(parts) == 1 {
+		if len(parts) == 1 || parts[1] == "--show-secrets" {
 			// Show current config
 			keys := config.ListKeys()
+			if len(parts) > 1 {
+				keys = config.ListKeysWithSecrets()
+			}
 			var sb strings.Builder
 			sb.WriteString("Configuration:\n")
-			sb.WriteString(fmt.Sprintf("  Config file: %s\n\n", config.ConfigPath()))
+			sb.WriteString(fmt.Sprintf("  Config file: %s\n", config.ConfigPath()))
+			if path := config.ProjectConfigPath(); path != "" {
+				sb.WriteString(fmt.Sprintf("  Project config: %s\n", path))
+			}
+			sb.WriteString("\n")
 
 			if len(keys) == 0 {
 				sb.WriteString("  No keys configured.\n")
@@ -732,6 +1060,8 @@ func (m Model) handleCommand(input string) (tea.Model, tea.Cmd) {
 			sb.WriteString("\nUsage:\n")
 			sb.WriteString("  /config set <key> <value>  - Set a config value\n")
 			sb.WriteString("  /config delete <key>       - Delete a config value\n")
+			sb.WriteString("  /config --show-secrets     - Show API keys in full\n")
+			sb.WriteString("  Add --project after set or delete to use .zcode/config.yaml\n")
 			sb.WriteString("\nKeys: openai, anthropic, provider, model")
 
 			m.messages.AddMessage(components.Message{
@@ -742,26 +1072,35 @@ func (m Model) handleCommand(input string) (tea.Model, tea.Cmd) {
 		}
 
 		subCmd := strings.ToLower(parts[1])
+		set, del := config.Set, config.Delete
+		if len(parts) > 2 && parts[2] == "--project" {
+			set, del = config.SetProject, config.DeleteProject
+			parts = append(parts[:2], parts[3:]...)
+		}
 		switch subCmd {
 		case "set":
 			if len(parts) < 4 {
 				m.messages.AddMessage(components.Message{
 					Role:    "error",
-					Content: "Usage: /config set <key> <value>",
+					Content: "Usage: /config set [--project] <key> <value>",
 				})
 				return m, nil
 			}
 			key := parts[2]
 			value := strings.Join(parts[3:], " ")
-			if err := config.Set(key, value); err != nil {
+			if err := set(key, value); err != nil {
 				m.messages.AddMessage(components.Message{
 					Role:    "error",
 					Content: fmt.Sprintf("Failed to set config: %v", err),
 				})
 			} else {
+				content := fmt.Sprintf("Set %s successfully.", key)
+				if note := warningsNote(); note != "" {
+					content += "\n" + note
+				}
 				m.messages.AddMessage(components.Message{
 					Role:    "system",
-					Content: fmt.Sprintf("Set %s successfully.", key),
+					Content: content,
 				})
 			}
 			return m, nil
@@ -770,12 +1109,12 @@ func (m Model) handleCommand(input string) (tea.Model, tea.Cmd) {
 			if len(parts) < 3 {
 				m.messages.AddMessage(components.Message{
 					Role:    "error",
-					Content: "Usage: /config delete <key>",
+					Content: "Usage: /config delete [--project] <key>",
 				})
 				return m, nil
 			}
 			key := parts[2]
-			if err := config.Delete(key); err != nil {
+			if err := del(key); err != nil {
 				m.messages.AddMessage(components.Message{
 					Role:    "error",
 					Content: fmt.Sprintf("Failed to delete config: %v", err),
@@ -838,6 +1177,37 @@ func (m Model) listAgents() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// listTools displays the tools the agent can call, as registered after
+// the tool policy is applied
+func (m Model) listTools() (tea.Model, tea.Cmd) {
+	defs := m.agent.Tools()
+	if len(defs) == 0 {
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: "No tools available. Check tools.disabled and tools.readonly with 'zcode config'.",
+		})
+		return m, nil
+	}
+
+	width := 0
+	for _, def := range defs {
+		width = max(width, len(def.Name))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Available tools:\n")
+	for _, def := range defs {
+		description, _, _ := strings.Cut(def.Description, "\n")
+		sb.WriteString(fmt.Sprintf("  %-*s - %s\n", width, def.Name, description))
+	}
+
+	m.messages.AddMessage(components.Message{
+		Role:    "system",
+		Content: strings.TrimSuffix(sb.String(), "\n"),
+	})
+	return m, nil
+}
+
 // listWorkflows displays available workflows
 func (m Model) listWorkflows() (tea.Model, tea.Cmd) {
 	workflowList := m.workflowRegistry.List()
@@ -1041,12 +1411,242 @@ func (m Model) executeWorkflow(workflowName string, prompt string) (tea.Model, t
 	return m, tea.Batch(m.spinner.Tick, m.executeWorkflowAsync(wf, prompt))
 }
 
+// listModels asks each provider for its models in the background,
+// marking the one in use
+func (m Model) listModels(filter string) tea.Cmd {
+	provider, model := llm.Describe(m.agent.Provider())
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), modelsTimeout)
+		defer cancel()
+		return modelsMsg{content: llm.FormatModels(llm.ListProviderModels(ctx), provider, model, filter)}
+	}
+}
+
+// handleModelCommand shows the current model, or starts switching to the
+// named one. The conversation is kept.
+func (m Model) handleModelCommand(args []string) (tea.Model, tea.Cmd) {
+	providerName, current := llm.Describe(m.agent.Provider())
+	if len(args) == 0 {
+		m.messages.AddMessage(components.Message{
+			Role:    "system",
+			Content: fmt.Sprintf("Current model: %s (%s)\nUsage: /model <name>. Use /models to see what is available.", current, providerName),
+		})
+		return m, nil
+	}
+	if m.thinking {
+		m.messages.AddMessage(components.Message{
+			Role:    "error",
+			Content: "Cannot switch models while a response is in progress.",
+		})
+		return m, nil
+	}
+
+	model := args[0]
+	provider, err := llm.NewProvider(providerName, model)
+	if err != nil {
+		m.messages.AddMessage(components.Message{
+			Role:    "error",
+			Content: fmt.Sprintf("Model not changed: %v", err),
+		})
+		return m, nil
+	}
+
+	m.messages.AddMessage(components.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("Checking %s...", model),
+	})
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), modelsTimeout)
+		defer cancel()
+		return modelSwitchMsg{provider: provider, model: model, err: provider.Validate(ctx)}
+	}
+}
+
+// switchModel moves the chat, custom agents, skills and workflows to the
+// provider from /model once it has been validated
+func (m *Model) switchModel(msg modelSwitchMsg) {
+	err := msg.err
+	if err == nil && m.thinking {
+		err = errors.New("a response started while the model was being checked")
+	}
+	if err == nil {
+		err = m.agent.SetProvider(msg.provider)
+	}
+	if err != nil {
+		content := fmt.Sprintf("Model not changed: %s", llm.ErrorMessage(err))
+		if errors.Is(err, llm.ErrUnknownModel) {
+			content += "\nUse /models to see what is available."
+		}
+		m.messages.AddMessage(components.Message{
+			Role:    "error",
+			Content: content,
+		})
+		return
+	}
+
+	m.provider = msg.provider
+	if m.agentExecutor != nil {
+		m.agentExecutor.SetProvider(msg.provider)
+	}
+	m.skillExecutor = nil // Recreated with m.provider on next use
+	if m.workflowEngine != nil {
+		m.workflowEngine.SetProvider(msg.provider)
+	}
+	m.status.SetModel(msg.model)
+
+	m.messages.AddMessage(components.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("Switched to %s. The conversation is kept.", msg.model),
+	})
+}
+
+// warningsNote lists the warnings held back from stderr while the TUI
+// owns the terminal, such as agents or workflows that failed to load
+func warningsNote() string {
+	warnings := logging.TakeWarnings()
+	for i, warning := range warnings {
+		warnings[i] = "Warning: " + warning
+	}
+	return strings.Join(warnings, "\n")
+}
+
+// projectContextNote describes the project's ZCODE.md, if any, and
+// reports whether loading it produced warnings
+func projectContextNote() (string, bool) {
+	cwd, _ := os.Getwd()
+	project, err := prompts.LoadProjectContext(cwd)
+	if err != nil {
+		return "Warning: cannot read project context: " + err.Error(), true
+	}
+	if project == nil {
+		return "", false
+	}
+
+	lines := []string{"Project context: " + project.Path}
+	for _, warning := range project.Warnings {
+		lines = append(lines, "Warning: "+warning)
+	}
+	return strings.Join(lines, "\n"), len(project.Warnings) > 0
+}
+
+// refreshTasks shows the agent's todo progress in the status bar
+func (m *Model) refreshTasks() {
+	todos := m.agent.Todos()
+	if todos == nil {
+		return
+	}
+	items := todos.Items()
+	done, next := 0, ""
+	for _, item := range items {
+		if item.Done {
+			done++
+		} else if next == "" {
+			next = item.Text
+		}
+	}
+	m.status.SetTasks(done, len(items), next)
+}
+
+// expandPrevVariables substitutes {prev.*} placeholders in a chat message
+// with outputs of the last successful workflow run
+func (m *Model) expandPrevVariables(text string) string {
+	if m.workflowEngine == nil {
+		return text
+	}
+	prev := m.workflowEngine.PreviousVariables()
+	if prev == nil {
+		return text
+	}
+	return workflows.ExpandVariables(text, map[string]any{"prev": prev})
+}
+
 // executeWorkflowAsync executes a workflow asynchronously
 func (m *Model) executeWorkflowAsync(wf *workflows.WorkflowDefinition, prompt string) tea.Cmd {
 	return func() tea.Msg {
 		ctx := context.Background()
-		result, err := m.workflowEngine.Execute(ctx, wf.Name, prompt)
-		return workflowResultMsg{result: result, err: err}
+		m.workflowEngine.SetHumanInput(m.humanInput)
+		events := m.workflowEngine.ExecuteStream(ctx, wf.Name, prompt)
+		return workflowEventChanMsg{events: events}
+	}
+}
+
+// sendHumanInput passes a reply to the waiting human step and resumes
+// reading workflow events
+func (m *Model) sendHumanInput(reply string) tea.Cmd {
+	input, events := m.humanInput, m.workflowEvents
+	return func() tea.Msg {
+		input <- reply
+		return workflowContinueMsg{events: events}
+	}
+}
+
+// workflowEventChanMsg carries the workflow event channel
+type workflowEventChanMsg struct {
+	events <-chan workflows.StreamEvent
+}
+
+// workflowContinueMsg signals to continue reading workflow events
+type workflowContinueMsg struct {
+	events <-chan workflows.StreamEvent
+}
+
+// workflowStepMsg carries a step_start or step_done event
+type workflowStepMsg struct {
+	event workflows.StreamEvent
+}
+
+// workflowStepArgs describes the step an event belongs to
+func workflowStepArgs(event workflows.StreamEvent) string {
+	args := fmt.Sprintf("%s/%s → %s", event.WorkflowName, event.StepName, event.AgentName)
+	if event.Loop > 1 {
+		args += fmt.Sprintf(" (loop %d)", event.Loop)
+	}
+	return args
+}
+
+// workflowStepSummary summarizes a finished step for its progress line
+func workflowStepSummary(result *workflows.StepResult) string {
+	if result == nil {
+		return "Done"
+	}
+	summary := "Done"
+	if !result.Success {
+		summary = "Error: " + result.Error
+	}
+	if result.Attempts > 1 {
+		summary += fmt.Sprintf(" (%d attempts)", result.Attempts)
+	}
+	return summary
+}
+
+// workflowHumanInputMsg signals that a human step is waiting for a reply
+type workflowHumanInputMsg struct {
+	stepName string
+	prompt   string
+}
+
+// readNextWorkflowEvent reads the next event from a workflow channel
+func readNextWorkflowEvent(events <-chan workflows.StreamEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			// Channel closed without a result
+			return workflowResultMsg{}
+		}
+
+		switch event.Type {
+		case "step_start", "step_done":
+			return workflowStepMsg{event: event}
+		case "human_input_required":
+			return workflowHumanInputMsg{stepName: event.StepName, prompt: event.Prompt}
+		case "workflow_done":
+			return workflowResultMsg{result: event.WorkflowResult}
+		case "error":
+			return workflowResultMsg{result: event.WorkflowResult, err: event.Error}
+		default:
+			// Other events, continue reading
+			return workflowContinueMsg{events: events}
+		}
 	}
 }
 
@@ -1074,10 +1674,14 @@ func (m Model) View() string {
 	messagesView := m.messages.View()
 	if m.thinking {
 		// Add thinking indicator at bottom of messages
-		thinkingStyle := lipgloss.NewStyle().Foreground(t.Primary)
+		activity := m.activity
+		if activity == "" {
+			activity = "Thinking..."
+		}
+		thinkingStyle := lipgloss.NewStyle().Foreground(t.Primary).MaxWidth(m.width)
 		messagesView = lipgloss.NewStyle().
 			Height(messagesHeight).
-			Render(messagesView + "\n" + thinkingStyle.Render(m.spinner.View()+" Thinking..."))
+			Render(messagesView + "\n" + thinkingStyle.Render(m.spinner.View()+" "+activity))
 	} else {
 		messagesView = lipgloss.NewStyle().
 			Height(messagesHeight).
@@ -1091,6 +1695,18 @@ func (m Model) View() string {
 		suggestions = m.suggestions.View()
 	}
 
+	// Prompt for a paused workflow step (shown above editor)
+	if m.inputPrompt.IsVisible() {
+		m.inputPrompt.SetWidth(m.width)
+		suggestions = m.inputPrompt.View()
+	}
+
+	// A tool action waiting for approval takes precedence
+	if m.confirm.IsVisible() {
+		m.confirm.SetWidth(m.width)
+		suggestions = m.confirm.View()
+	}
+
 	// Editor (fixed height)
 	editor := m.editor.View()
 
@@ -1132,9 +1748,71 @@ func (m Model) View() string {
 		Render(view)
 }
 
-// ConfirmAction creates a confirmation function for tools
-func ConfirmAction(prompt string) bool {
-	// In TUI mode, we auto-approve for now
-	// TODO: Implement proper confirmation dialog
-	return true
+// toolActivity describes a running tool for the spinner line, e.g.
+// "Running: npm test" or "Editing main.go"
+func toolActivity(name, args string) string {
+	args = strings.Join(strings.Fields(args), " ")
+	switch name {
+	case "run_command", "run_in_sandbox":
+		return "Running: " + args
+	case "write_file", "edit_file", "line_edit":
+		return "Editing " + filepath.Base(args)
+	case "read_file":
+		return "Reading " + filepath.Base(args)
+	case "list_dir":
+		return "Listing " + args
+	case "glob", "grep":
+		return "Searching for " + args
+	case "web_fetch":
+		return "Fetching " + args
+	case "delete_file":
+		return "Deleting " + args
+	case "move_file":
+		return "Moving " + args
+	default:
+		return "Calling " + name + "..."
+	}
+}
+
+// undoLastEdit reverts the most recent file change made by any tool
+func undoLastEdit() (string, error) {
+	journal := tools.DefaultJournal()
+	if journal == nil {
+		return "", fmt.Errorf("no backup journal available")
+	}
+	return journal.Undo()
+}
+
+// confirmMsg asks the user to approve a tool action
+type confirmMsg struct {
+	req   tools.ConfirmRequest
+	reply chan<- bool
+}
+
+// confirmRequests carries confirmations from the goroutines running
+// tools to the TUI
+var confirmRequests = make(chan confirmMsg)
+
+// ConfirmAction asks the user to approve a tool action, showing the diff
+// of the change if there is one, and blocks until they answer
+func ConfirmAction(req tools.ConfirmRequest) bool {
+	reply := make(chan bool, 1)
+	confirmRequests <- confirmMsg{req: req, reply: reply}
+	return <-reply
+}
+
+// waitForConfirm waits for the next confirmation request
+func waitForConfirm() tea.Cmd {
+	return func() tea.Msg {
+		return <-confirmRequests
+	}
+}
+
+// answerConfirm replies to the pending confirmation and waits for the
+// next one
+func (m Model) answerConfirm(approved bool) (tea.Model, tea.Cmd) {
+	m.confirmReply <- approved
+	m.confirmReply = nil
+	m.confirm.Hide()
+	return m, waitForConfirm()
 }
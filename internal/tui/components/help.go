@@ -57,7 +57,9 @@ func (h *HelpDialog) View() string {
 		{"Ctrl+C", "Quit Z-Code"},
 		{"Ctrl+L", "Clear chat"},
 		{"Esc", "Cancel/Close"},
+		{"y/n", "Approve or deny a tool action"},
 		{"PgUp/PgDn", "Scroll messages"},
+		{"Ctrl+O", "Show or collapse long tool output"},
 	}
 
 	var keyContent string
@@ -80,6 +82,12 @@ func (h *HelpDialog) View() string {
 		{"/clear", "Clear chat history"},
 		{"/reset", "Reset conversation context"},
 		{"/tools", "List available tools"},
+		{"/undo", "Revert the last file change"},
+		{"/todo", "Show the agent's task list"},
+		{"/retry", "Regenerate the last response"},
+		{"/edit", "Revise and resend your last message"},
+		{"/models", "List the models each provider offers"},
+		{"/model", "Switch to another model"},
 		{"/config", "View or set configuration"},
 		{"/quit", "Exit Z-Code"},
 	}
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/viewport"
@@ -15,8 +16,20 @@ type Message struct {
 	Content  string
 	ToolName string
 	ToolArgs string
+	Output   string // Live output while a tool is running
 }
 
+// runningOutputLines is how many trailing lines of live tool output are shown
+const runningOutputLines = 6
+
+// collapsedToolLines is how many wrapped lines of a finished tool's output
+// are shown until tool output is expanded
+const collapsedToolLines = 12
+
+// tabWidth is how many spaces a tab in tool output takes, since tabs would
+// otherwise be measured as one cell and overflow the layout
+const tabWidth = 4
+
 // Messages is the scrollable message list component
 type Messages struct {
 	viewport         viewport.Model
@@ -27,6 +40,7 @@ type Messages struct {
 	ready            bool
 	welcome          string
 	streamingContent string // Content being streamed
+	expandToolOutput bool   // Show finished tool output in full
 }
 
 // NewMessages creates a new messages component
@@ -113,6 +127,39 @@ func (m *Messages) UpdateLastToolResult(result string) {
 	m.updateContent()
 }
 
+// UpdateToolResult updates the result of the last tool message with the
+// given name and arguments, for tools that may overlap such as nested
+// workflow steps
+func (m *Messages) UpdateToolResult(name, args, result string) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "tool" && m.messages[i].ToolName == name && m.messages[i].ToolArgs == args {
+			m.messages[i].Content = result
+			break
+		}
+	}
+	m.updateContent()
+}
+
+// ToggleToolOutput switches between collapsed and full tool output and
+// reports whether output is now shown in full. Message contents are kept
+// whole either way.
+func (m *Messages) ToggleToolOutput() bool {
+	m.expandToolOutput = !m.expandToolOutput
+	m.updateContent()
+	return m.expandToolOutput
+}
+
+// AppendLastToolOutput adds live output to the last tool message
+func (m *Messages) AppendLastToolOutput(chunk string) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "tool" {
+			m.messages[i].Output += chunk
+			break
+		}
+	}
+	m.updateContent()
+}
+
 // updateContent rebuilds the viewport content
 func (m *Messages) updateContent() {
 	if !m.ready {
@@ -258,33 +305,55 @@ func (m *Messages) updateContent() {
 
 			sb.WriteString("  " + iconStyle.Render(statusIcon) + " " + toolNameStyle.Render(msg.ToolName))
 
-			// Command/args inline
+			// Command/args inline, cut to the line
 			if msg.ToolArgs != "" {
 				argsStyle := lipgloss.NewStyle().
-					Foreground(t.TextMuted)
-				sb.WriteString(argsStyle.Render(" → " + msg.ToolArgs))
+					Foreground(t.TextMuted).
+					MaxWidth(max(contentWidth-lipgloss.Width(msg.ToolName)-4, 10))
+				args := strings.Join(strings.Fields(msg.ToolArgs), " ")
+				sb.WriteString(argsStyle.Render(" → " + args))
 			}
 			sb.WriteString("\n")
 
-			// Result (if not running and has content)
-			if !isRunning && msg.Content != "" {
-				result := msg.Content
-				maxResultLen := 300
-				if len(result) > maxResultLen {
-					result = result[:maxResultLen] + "\n    ⋯ (truncated)"
+			// Live output tail while the tool is still running
+			if isRunning && msg.Output != "" {
+				lines := strings.Split(strings.TrimRight(msg.Output, "\n"), "\n")
+				if len(lines) > runningOutputLines {
+					lines = lines[len(lines)-runningOutputLines:]
 				}
 
+				outputStyle := lipgloss.NewStyle().
+					Foreground(t.TextMuted).
+					PaddingLeft(4).
+					Width(contentWidth - 6)
+				boxStyle := lipgloss.NewStyle().
+					Foreground(t.Border).
+					PaddingLeft(4)
+				sb.WriteString(boxStyle.Render("│") + "\n")
+				sb.WriteString(outputStyle.Render(expandTabs(strings.Join(lines, "\n"))) + "\n")
+			}
+
+			// Result (if not running and has content), wrapped to the
+			// viewport and collapsed when long
+			if !isRunning && msg.Content != "" {
 				resultStyle := lipgloss.NewStyle().
 					Foreground(t.TextMuted).
 					PaddingLeft(4).
 					Width(contentWidth - 6)
+				lines := strings.Split(resultStyle.Render(expandTabs(msg.Content)), "\n")
+				if hidden := len(lines) - collapsedToolLines; hidden > 0 && !m.expandToolOutput {
+					moreStyle := lipgloss.NewStyle().
+						Foreground(t.Accent).
+						PaddingLeft(4)
+					lines = append(lines[:collapsedToolLines], moreStyle.Render(fmt.Sprintf("⋯ show more (%d lines) - ctrl+o", hidden)))
+				}
 
 				// Add a subtle box for output
 				boxStyle := lipgloss.NewStyle().
 					Foreground(t.Border).
 					PaddingLeft(4)
 				sb.WriteString(boxStyle.Render("│") + "\n")
-				sb.WriteString(resultStyle.Render(result) + "\n")
+				sb.WriteString(strings.Join(lines, "\n") + "\n")
 			}
 			sb.WriteString("\n")
 
@@ -294,7 +363,8 @@ func (m *Messages) updateContent() {
 				Foreground(t.Info)
 			sysStyle := lipgloss.NewStyle().
 				Foreground(t.TextMuted).
-				Italic(true)
+				Italic(true).
+				Width(contentWidth - 2)
 			sb.WriteString(iconStyle.Render("ℹ") + " " + sysStyle.Render(msg.Content) + "\n\n")
 
 		case "error":
@@ -303,7 +373,8 @@ func (m *Messages) updateContent() {
 				Foreground(t.Error).
 				Bold(true)
 			errStyle := lipgloss.NewStyle().
-				Foreground(t.Error)
+				Foreground(t.Error).
+				Width(contentWidth - 2)
 			sb.WriteString(iconStyle.Render("✗") + " " + errStyle.Render(msg.Content) + "\n\n")
 		}
 	}
@@ -343,6 +414,11 @@ func (m *Messages) updateContent() {
 	m.viewport.GotoBottom()
 }
 
+// expandTabs replaces tabs with spaces so their width is measured correctly
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", strings.Repeat(" ", tabWidth))
+}
+
 // View renders the messages
 func (m *Messages) View() string {
 	if !m.ready {
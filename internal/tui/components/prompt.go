@@ -0,0 +1,178 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/simonyos/Z-CODE/internal/tui/theme"
+)
+
+// maxPromptLines caps how much of the question the prompt box shows; the
+// full text is in the chat
+const maxPromptLines = 4
+
+// InputPrompt asks the operator to answer a paused workflow step
+type InputPrompt struct {
+	visible  bool
+	stepName string
+	question string
+	width    int
+}
+
+// NewInputPrompt creates a new input prompt component
+func NewInputPrompt() *InputPrompt {
+	return &InputPrompt{}
+}
+
+// SetWidth sets the component width
+func (p *InputPrompt) SetWidth(width int) {
+	p.width = width
+}
+
+// Show displays the question asked by a workflow step
+func (p *InputPrompt) Show(stepName, question string) {
+	p.visible = true
+	p.stepName = stepName
+	p.question = question
+}
+
+// Hide hides the prompt
+func (p *InputPrompt) Hide() {
+	p.visible = false
+}
+
+// IsVisible returns whether the prompt is waiting for an answer
+func (p *InputPrompt) IsVisible() bool {
+	return p.visible
+}
+
+// View renders the prompt
+func (p *InputPrompt) View() string {
+	if !p.visible {
+		return ""
+	}
+
+	t := theme.Current
+
+	var sb strings.Builder
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(t.Warning).
+		Bold(true)
+	sb.WriteString(headerStyle.Render("Workflow step '"+p.stepName+"' needs your input") + "\n")
+
+	lines := strings.Split(strings.TrimSpace(p.question), "\n")
+	if len(lines) > maxPromptLines {
+		lines = append(lines[:maxPromptLines], "…")
+	}
+	sb.WriteString(lipgloss.NewStyle().Foreground(t.Text).Render(strings.Join(lines, "\n")) + "\n")
+
+	footerStyle := lipgloss.NewStyle().
+		Foreground(t.TextMuted).
+		Italic(true)
+	sb.WriteString(footerStyle.Render("Type approve, reject, or an answer • Enter to send"))
+
+	container := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Warning).
+		Background(t.Background).
+		Padding(0, 1).
+		Width(p.width - 2)
+
+	return container.Render(sb.String())
+}
+
+// maxConfirmDiffLines caps how much of a diff the confirmation box shows;
+// the full diff is in the chat
+const maxConfirmDiffLines = 10
+
+// ConfirmPrompt asks the user to approve a tool action
+type ConfirmPrompt struct {
+	visible bool
+	prompt  string
+	diff    string
+	width   int
+}
+
+// NewConfirmPrompt creates a new confirmation prompt component
+func NewConfirmPrompt() *ConfirmPrompt {
+	return &ConfirmPrompt{}
+}
+
+// SetWidth sets the component width
+func (p *ConfirmPrompt) SetWidth(width int) {
+	p.width = width
+}
+
+// Show displays an action and the diff of the change it makes, if any
+func (p *ConfirmPrompt) Show(prompt, diff string) {
+	p.visible = true
+	p.prompt = prompt
+	p.diff = diff
+}
+
+// Hide hides the prompt
+func (p *ConfirmPrompt) Hide() {
+	p.visible = false
+}
+
+// IsVisible returns whether the prompt is waiting for an answer
+func (p *ConfirmPrompt) IsVisible() bool {
+	return p.visible
+}
+
+// View renders the prompt
+func (p *ConfirmPrompt) View() string {
+	if !p.visible {
+		return ""
+	}
+
+	t := theme.Current
+
+	var sb strings.Builder
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(t.Warning).
+		Bold(true)
+	sb.WriteString(headerStyle.Render("Approve this action?") + "\n")
+	sb.WriteString(lipgloss.NewStyle().Foreground(t.Text).Render(strings.TrimSpace(p.prompt)) + "\n")
+
+	if p.diff != "" {
+		lines := strings.Split(strings.TrimRight(p.diff, "\n"), "\n")
+		hidden := len(lines) - maxConfirmDiffLines
+		if hidden > 0 {
+			lines = lines[:maxConfirmDiffLines]
+		}
+		for _, line := range lines {
+			color := t.TextMuted
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			case strings.HasPrefix(line, "+"):
+				color = t.Success
+			case strings.HasPrefix(line, "-"):
+				color = t.Error
+			case strings.HasPrefix(line, "@@"):
+				color = t.Info
+			}
+			sb.WriteString(lipgloss.NewStyle().Foreground(color).Render(expandTabs(line)) + "\n")
+		}
+		if hidden > 0 {
+			sb.WriteString(lipgloss.NewStyle().Foreground(t.TextMuted).Render(fmt.Sprintf("… %d more lines in the chat", hidden)) + "\n")
+		}
+	}
+
+	footerStyle := lipgloss.NewStyle().
+		Foreground(t.TextMuted).
+		Italic(true)
+	sb.WriteString(footerStyle.Render("y to approve • n or Esc to deny"))
+
+	container := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Warning).
+		Background(t.Background).
+		Padding(0, 1).
+		Width(p.width - 2)
+
+	return container.Render(sb.String())
+}
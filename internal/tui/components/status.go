@@ -1,6 +1,7 @@
 package components
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -14,6 +15,11 @@ type Status struct {
 	Thinking   bool
 	Message    string
 	TokenCount int
+
+	// Task progress from the agent's todo list
+	TasksDone  int
+	TasksTotal int
+	NextTask   string
 }
 
 // NewStatus creates a new status bar
@@ -44,6 +50,37 @@ func (s *Status) SetModel(model string) {
 	s.Model = model
 }
 
+// SetTasks sets the todo list progress; a total of zero hides it
+func (s *Status) SetTasks(done, total int, next string) {
+	s.TasksDone = done
+	s.TasksTotal = total
+	s.NextTask = next
+}
+
+// maxNextTaskWidth caps how much of the next task is shown
+const maxNextTaskWidth = 40
+
+// tasksView renders the task progress segment, or "" when there are no tasks
+func (s *Status) tasksView() string {
+	if s.TasksTotal == 0 {
+		return ""
+	}
+
+	t := theme.Current
+	text := fmt.Sprintf("☑ %d/%d", s.TasksDone, s.TasksTotal)
+	if s.NextTask != "" {
+		next := s.NextTask
+		if runes := []rune(next); len(runes) > maxNextTaskWidth {
+			next = string(runes[:maxNextTaskWidth-1]) + "…"
+		}
+		text += " " + next
+	}
+	return lipgloss.NewStyle().
+		Foreground(t.TextMuted).
+		Padding(0, 1).
+		Render(text)
+}
+
 // View renders the status bar
 func (s *Status) View() string {
 	t := theme.Current
@@ -70,6 +107,9 @@ func (s *Status) View() string {
 		{"Ctrl+L", "clear"},
 		{"Ctrl+C", "quit"},
 	}
+	if s.Thinking {
+		hints[0].key, hints[0].desc = "Esc", "cancel"
+	}
 
 	var hintParts []string
 	for _, h := range hints {
@@ -94,6 +134,9 @@ func (s *Status) View() string {
 			Bold(true)
 		rightContent = modelStyle.Render("⚡ " + s.Model)
 	}
+	if tasks := s.tasksView(); tasks != "" {
+		rightContent = tasks + rightContent
+	}
 
 	// Calculate spacing
 	leftWidth := lipgloss.Width(hintBar)
@@ -21,6 +21,12 @@ var BuiltinCommands = []Command{
 	{Name: "/clear", Description: "Clear chat history"},
 	{Name: "/reset", Description: "Reset conversation and context"},
 	{Name: "/tools", Description: "List available tools"},
+	{Name: "/undo", Description: "Revert the last file change"},
+	{Name: "/todo", Description: "Show the agent's task list"},
+	{Name: "/retry", Description: "Regenerate the last response"},
+	{Name: "/edit", Description: "Revise your last message"},
+	{Name: "/models", Description: "List available models"},
+	{Name: "/model", Description: "Switch to another model"},
 	{Name: "/config", Description: "Show or set configuration"},
 	{Name: "/agents", Description: "List custom agents"},
 	{Name: "/skills", Description: "List skills"},
@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/simonyos/Z-CODE/internal/config"
+	"github.com/simonyos/Z-CODE/internal/llm"
+	"github.com/simonyos/Z-CODE/internal/tui/theme"
+)
+
+// pickerModelsTimeout bounds fetching a provider's models in the picker
+const pickerModelsTimeout = 10 * time.Second
+
+// ModelChoice is the provider and model picked in the model picker
+type ModelChoice struct {
+	Provider string
+	Model    string
+}
+
+// pickerItem is an entry in one of the picker's lists
+type pickerItem struct {
+	value  string
+	detail string
+}
+
+func (i pickerItem) Title() string       { return i.value }
+func (i pickerItem) Description() string { return i.detail }
+func (i pickerItem) FilterValue() string { return i.value }
+
+// modelsLoadedMsg carries the models of the provider picked
+type modelsLoadedMsg struct {
+	models llm.ProviderModels
+}
+
+// pickerStep is the list the picker is showing
+type pickerStep int
+
+const (
+	pickProvider pickerStep = iota
+	loadingModels
+	pickModel
+)
+
+// pickerModel is a two-step list: first a provider, then one of its models
+type pickerModel struct {
+	step      pickerStep
+	providers list.Model
+	models    list.Model
+	provider  string
+	choice    ModelChoice
+	done      bool
+	width     int
+	height    int
+}
+
+// AvailableProviders returns the providers that can be used with the API
+// keys found in config and the environment. LiteLLM is always offered, as
+// a local proxy may not need a key.
+func AvailableProviders() []string {
+	var available []string
+	if config.GetOpenAIKey() != "" {
+		available = append(available, "openai")
+	}
+	if config.GetOpenRouterKey() != "" {
+		available = append(available, "openrouter")
+	}
+	return append(available, "litellm")
+}
+
+// providerDetail describes where a provider's credentials come from
+func providerDetail(name string) string {
+	switch name {
+	case "openai", "openrouter":
+		return "API key found"
+	case "litellm":
+		if config.GetLiteLLMKey() != "" {
+			return "API key found, proxy at " + config.GetLiteLLMBaseURL()
+		}
+		return "proxy at " + config.GetLiteLLMBaseURL()
+	}
+	return ""
+}
+
+// newPickerList creates a list styled like the rest of the TUI
+func newPickerList(title string, items []list.Item) list.Model {
+	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
+	l.Title = title
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(theme.Current.TextInverse).
+		Background(theme.Current.Primary).
+		Padding(0, 1)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+func newPickerModel() pickerModel {
+	var items []list.Item
+	for _, name := range AvailableProviders() {
+		items = append(items, pickerItem{value: name, detail: providerDetail(name)})
+	}
+	providers := newPickerList("Choose a provider", items)
+	providers.SetFilteringEnabled(false)
+	providers.SetStatusBarItemName("provider", "providers")
+	return pickerModel{providers: providers, models: newPickerList("", nil)}
+}
+
+// loadModels fetches the models of a provider, falling back to the
+// curated list when it cannot be asked
+func loadModels(name string) tea.Cmd {
+	return func() tea.Msg {
+		provider, err := llm.NewProvider(name, "")
+		if err != nil {
+			return modelsLoadedMsg{models: llm.ProviderModels{Provider: name, Err: err}}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pickerModelsTimeout)
+		defer cancel()
+		return modelsLoadedMsg{models: llm.AvailableModels(ctx, provider)}
+	}
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.providers.SetSize(msg.Width, msg.Height-1)
+		m.models.SetSize(msg.Width, msg.Height-1)
+		return m, nil
+
+	case modelsLoadedMsg:
+		m.step = pickModel
+		models := msg.models.Models
+		if msg.models.Err != nil || len(models) == 0 {
+			models = llm.KnownModels[m.provider]
+		}
+		items := make([]list.Item, len(models))
+		for i, id := range models {
+			detail := ""
+			if id == llm.DefaultModels[m.provider] {
+				detail = "default"
+			}
+			items[i] = pickerItem{value: id, detail: detail}
+		}
+		m.models = newPickerList("Choose a model for "+m.provider, items)
+		m.models.SetStatusBarItemName("model", "models")
+		m.models.SetSize(m.width, m.height-1)
+		if msg.models.Err != nil {
+			return m, m.models.NewStatusMessage("Could not list models: " + llm.ErrorMessage(msg.models.Err))
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.step {
+		case pickProvider:
+			switch msg.String() {
+			case "esc", "q":
+				return m, tea.Quit
+			case "enter":
+				if item, ok := m.providers.SelectedItem().(pickerItem); ok {
+					m.provider = item.value
+					m.step = loadingModels
+					return m, loadModels(item.value)
+				}
+			}
+		case loadingModels:
+			return m, nil
+		case pickModel:
+			if m.models.SettingFilter() {
+				break
+			}
+			switch msg.String() {
+			case "esc":
+				if m.models.IsFiltered() {
+					break
+				}
+				m.step = pickProvider
+				return m, nil
+			case "enter":
+				if item, ok := m.models.SelectedItem().(pickerItem); ok {
+					m.choice = ModelChoice{Provider: m.provider, Model: item.value}
+					m.done = true
+					return m, tea.Quit
+				}
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	switch m.step {
+	case pickProvider:
+		m.providers, cmd = m.providers.Update(msg)
+	case pickModel:
+		m.models, cmd = m.models.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m pickerModel) View() string {
+	muted := lipgloss.NewStyle().Foreground(theme.Current.TextMuted)
+	switch m.step {
+	case loadingModels:
+		return fmt.Sprintf("\n  Fetching models for %s...\n", m.provider)
+	case pickModel:
+		return m.models.View() + "\n" + muted.Render("  enter: select • /: filter • esc: back")
+	default:
+		return m.providers.View() + "\n" + muted.Render("  enter: select • esc: cancel")
+	}
+}
+
+// RunModelPicker asks the user to pick a provider and model and saves the
+// choice to the config. ok is false when the user cancels.
+func RunModelPicker() (choice ModelChoice, ok bool, err error) {
+	final, err := tea.NewProgram(newPickerModel(), tea.WithAltScreen()).Run()
+	if err != nil {
+		return ModelChoice{}, false, err
+	}
+	m := final.(pickerModel)
+	if !m.done {
+		return ModelChoice{}, false, nil
+	}
+
+	if err := config.Set("default_provider", m.choice.Provider); err != nil {
+		return m.choice, true, err
+	}
+	if err := config.Set("default_model", m.choice.Model); err != nil {
+		return m.choice, true, err
+	}
+	return m.choice, true, nil
+}
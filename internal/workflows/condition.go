@@ -0,0 +1,172 @@
+package workflows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// conditionOperators are the comparison operators, longest first so ">="
+// is not read as ">"
+var conditionOperators = []string{
+	"==", "!=", ">=", "<=", ">", "<",
+	" contains ", " startswith ", " endswith ",
+}
+
+// evaluateCondition evaluates a condition expression.
+// Supports comparisons ("a == b", "a != b", "a > b", "a >= b", "a < b",
+// "a <= b", "a contains b", "a startswith b", "a endswith b"), existence
+// checks ("key" or "step.success") and their combination with && and ||,
+// where && binds tighter. Values are compared as numbers when both sides
+// parse as numbers, otherwise as strings.
+func (e *Engine) evaluateCondition(condition string, wfCtx *Context) (bool, error) {
+	condition = strings.TrimSpace(condition)
+	if condition == "" {
+		return false, ErrInvalidCondition
+	}
+
+	for _, clause := range splitOutsideQuotes(condition, "||") {
+		all := true
+		for _, term := range splitOutsideQuotes(clause, "&&") {
+			met, err := e.evaluateComparison(term, wfCtx)
+			if err != nil {
+				return false, err
+			}
+			if !met {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// evaluateComparison evaluates a single comparison or existence check
+func (e *Engine) evaluateComparison(expr string, wfCtx *Context) (bool, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return false, fmt.Errorf("%w: empty term", ErrInvalidCondition)
+	}
+
+	// Handle "true" and "false" literals
+	if expr == "true" {
+		return true, nil
+	}
+	if expr == "false" {
+		return false, nil
+	}
+
+	pos, op := findOperator(expr)
+	if pos < 0 {
+		// Treat as existence check
+		value := e.resolveValue(expr, wfCtx)
+		return value != nil && value != "" && value != false, nil
+	}
+
+	left := strings.TrimSpace(expr[:pos])
+	right := strings.TrimSpace(expr[pos+len(op):])
+	if left == "" || right == "" {
+		return false, fmt.Errorf("%w: %q needs a value on both sides", ErrInvalidCondition, strings.TrimSpace(op))
+	}
+
+	leftStr := fmt.Sprintf("%v", e.resolveValue(left, wfCtx))
+	rightStr := fmt.Sprintf("%v", e.resolveValue(right, wfCtx))
+
+	switch strings.TrimSpace(op) {
+	case "contains":
+		return strings.Contains(leftStr, rightStr), nil
+	case "startswith":
+		return strings.HasPrefix(leftStr, rightStr), nil
+	case "endswith":
+		return strings.HasSuffix(leftStr, rightStr), nil
+	}
+
+	cmp := compareValues(leftStr, rightStr)
+	switch op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	}
+
+	return false, ErrInvalidCondition
+}
+
+// compareValues compares numerically when both sides parse as numbers,
+// falling back to string comparison
+func compareValues(left, right string) int {
+	l, lerr := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	r, rerr := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	if lerr == nil && rerr == nil {
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(left, right)
+}
+
+// findOperator returns the position and text of the first comparison
+// operator outside quotes, or -1 if there is none
+func findOperator(expr string) (int, string) {
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+			continue
+		}
+		for _, op := range conditionOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				return i, op
+			}
+		}
+	}
+	return -1, ""
+}
+
+// splitOutsideQuotes splits s on sep, ignoring separators inside quotes
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -1,5 +1,14 @@
 package workflows
 
+import "time"
+
+// Step types
+const (
+	StepTypeAgent    = "agent"
+	StepTypeHuman    = "human"
+	StepTypeWorkflow = "workflow"
+)
+
 // WorkflowDefinition represents a multi-step workflow loaded from YAML
 type WorkflowDefinition struct {
 	// Name is the unique identifier for the workflow
@@ -23,9 +32,20 @@ type WorkflowStep struct {
 	// Name identifies this step (for referencing in conditions)
 	Name string `yaml:"name"`
 
+	// Type selects what the step runs: "agent" (the default), "human",
+	// which pauses the workflow until the operator replies, or "workflow",
+	// which runs another workflow
+	Type string `yaml:"type"`
+
 	// Agent is the name of the agent to execute
+	// The special name HUMAN makes this a human step
 	Agent string `yaml:"agent"`
 
+	// Workflow is the name of the workflow a "workflow" step runs. The
+	// step's resolved prompt is its user_input and its final output is
+	// the step's output.
+	Workflow string `yaml:"workflow"`
+
 	// Input is the context key to read input from
 	// The value will be prepended to the user prompt
 	Input string `yaml:"input"`
@@ -54,8 +74,21 @@ type WorkflowStep struct {
 	OnSuccess string `yaml:"on_success"`
 
 	// OnFailure is the step name to jump to on failure
-	// Empty means abort the workflow
+	// Empty means abort the workflow, unless ContinueOnError is set
 	OnFailure string `yaml:"on_failure"`
+
+	// ContinueOnError lets the workflow go on to the next step when this
+	// step fails and has no on_failure route. The failure is recorded in
+	// the step's result and in WorkflowResult.FailedSteps.
+	ContinueOnError bool `yaml:"continue_on_error"`
+
+	// Timeout bounds a single attempt of the step, e.g. "5m"
+	// Zero means only the workflow's context applies
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Retries is how many more times a failed attempt is tried, with
+	// exponential backoff, before on_failure applies
+	Retries int `yaml:"retries"`
 }
 
 // StepResult contains the outcome of executing a workflow step
@@ -66,6 +99,7 @@ type StepResult struct {
 	Output    string
 	Error     string
 	LoopCount int
+	Attempts  int // Attempts made in the last loop iteration, including retries
 }
 
 // WorkflowResult contains the final outcome of a workflow
@@ -73,8 +107,23 @@ type WorkflowResult struct {
 	WorkflowName string
 	Success      bool
 	StepResults  []StepResult
-	FinalOutput  string
 	Error        string
+
+	// FinalOutput is the output of the last step that ran. If a step failed
+	// and was skipped past with continue_on_error, it is the output of the
+	// last successful step instead.
+	FinalOutput string
+
+	// FailedSteps names the steps that failed, in the order they ran,
+	// including those routed by on_failure or skipped past with
+	// continue_on_error. A successful run with failed steps is a partial
+	// success.
+	FailedSteps []string
+
+	// Variables holds the run's named outputs, step results, user_input and
+	// final_output. After a successful run they are available to the next
+	// workflow or chat message as {prev.<name>}.
+	Variables map[string]any
 }
 
 // Validate checks if the workflow definition is valid
@@ -86,7 +135,17 @@ func (d *WorkflowDefinition) Validate() error {
 		return ErrNoSteps
 	}
 	for i, step := range d.Steps {
-		if step.Agent == "" {
+		switch step.Type {
+		case "", StepTypeAgent, StepTypeHuman:
+		case StepTypeWorkflow:
+			if step.Workflow == "" {
+				return &StepError{Index: i, Err: ErrMissingWorkflow}
+			}
+			continue
+		default:
+			return &StepError{Index: i, Err: ErrUnknownStepType}
+		}
+		if step.Agent == "" && !step.IsHuman() {
 			return &StepError{Index: i, Err: ErrMissingAgent}
 		}
 	}
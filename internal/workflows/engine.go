@@ -2,9 +2,13 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/simonyos/Z-CODE/internal/agents"
 	"github.com/simonyos/Z-CODE/internal/llm"
@@ -16,8 +20,37 @@ type Engine struct {
 	agentRegistry    *agents.Registry
 	workflowRegistry *Registry
 	executor         *agents.Executor
+
+	mu         sync.Mutex
+	last       *WorkflowResult // Most recent successful run, exposed as {prev.*}
+	humanInput <-chan string   // Replies for human steps; stdin when nil
 }
 
+// prevVariable is the name completed-run variables are exposed under
+const prevVariable = "prev"
+
+// Backoff between retries of a failed step: retryBaseDelay doubles after
+// each attempt, up to retryMaxDelay
+var (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// maxWorkflowDepth limits how deeply workflow steps may nest sub-workflows
+const maxWorkflowDepth = 5
+
+// workflowDepthKey is the context key holding the sub-workflow depth
+type workflowDepthKey struct{}
+
+// workflowDepth returns how many sub-workflows deep ctx is
+func workflowDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(workflowDepthKey{}).(int)
+	return depth
+}
+
+// templatePattern matches {key} and {key.field} placeholders
+var templatePattern = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*(?:\.[a-zA-Z_][a-zA-Z0-9_]*)?)\}`)
+
 // NewEngine creates a new workflow engine
 func NewEngine(
 	agentReg *agents.Registry,
@@ -32,15 +65,51 @@ func NewEngine(
 	}
 }
 
+// SetProvider switches the provider used by later runs. Results of earlier
+// runs stay available as {prev.*}.
+func (e *Engine) SetProvider(provider llm.Provider) {
+	e.executor.SetProvider(provider)
+}
+
 // Execute runs a workflow by name
 func (e *Engine) Execute(ctx context.Context, workflowName string, initialPrompt string) (*WorkflowResult, error) {
+	return e.ExecuteWithEvents(ctx, workflowName, initialPrompt, nil)
+}
+
+// ExecuteWithEvents runs a workflow by name, passing step_start, step_done
+// and human_input_required events to onEvent, which may be nil. Events from
+// sub-workflows carry the sub-workflow's name.
+func (e *Engine) ExecuteWithEvents(ctx context.Context, workflowName string, initialPrompt string, onEvent func(StreamEvent)) (*WorkflowResult, error) {
 	workflow, ok := e.workflowRegistry.Get(workflowName)
 	if !ok {
 		return nil, ErrWorkflowNotFound
 	}
 
+	emit := func(event StreamEvent) {
+		if onEvent == nil {
+			return
+		}
+		if event.WorkflowName == "" {
+			event.WorkflowName = workflowName
+		}
+		onEvent(event)
+	}
+	depth := workflowDepth(ctx)
+	if depth > maxWorkflowDepth {
+		return nil, ErrWorkflowDepth
+	}
+
+	// Let the prompt and steps reference the previous run's outputs
+	prev := e.PreviousVariables()
+	if prev != nil {
+		initialPrompt = ExpandVariables(initialPrompt, map[string]any{prevVariable: prev})
+	}
+
 	wfCtx := NewContext()
 	wfCtx.Set("user_input", initialPrompt)
+	if prev != nil {
+		wfCtx.Set(prevVariable, prev)
+	}
 
 	result := &WorkflowResult{
 		WorkflowName: workflowName,
@@ -49,6 +118,7 @@ func (e *Engine) Execute(ctx context.Context, workflowName string, initialPrompt
 
 	// Execute steps in order
 	stepIndex := 0
+	continuedPastFailure := false
 	for stepIndex < len(workflow.Steps) {
 		select {
 		case <-ctx.Done():
@@ -75,20 +145,37 @@ func (e *Engine) Execute(ctx context.Context, workflowName string, initialPrompt
 		}
 
 		// Execute the step (with looping support)
-		stepResult, err := e.executeStepWithLooping(ctx, &step, wfCtx, initialPrompt)
+		stepResult, err := e.executeStepWithLooping(ctx, &step, wfCtx, initialPrompt, emit)
 		if err != nil {
-			result.Success = false
-			result.Error = err.Error()
 			result.StepResults = append(result.StepResults, *stepResult)
+			label := step.Name
+			if label == "" {
+				label = fmt.Sprintf("#%d", stepIndex+1)
+			}
+			result.FailedSteps = append(result.FailedSteps, label)
 
 			// Handle failure routing
 			if step.OnFailure != "" {
 				nextIdx := e.findStepIndex(workflow, step.OnFailure)
 				if nextIdx >= 0 {
+					result.Success = false
+					result.Error = err.Error()
 					stepIndex = nextIdx
 					continue
 				}
 			}
+
+			// Non-critical steps fail without stopping the workflow; later
+			// conditions can check {step.success}
+			if step.ContinueOnError && ctx.Err() == nil {
+				wfCtx.SetResult(step.Name, *stepResult)
+				continuedPastFailure = true
+				stepIndex++
+				continue
+			}
+
+			result.Success = false
+			result.Error = err.Error()
 			return result, err
 		}
 
@@ -116,16 +203,86 @@ func (e *Engine) Execute(ctx context.Context, workflowName string, initialPrompt
 	if len(result.StepResults) > 0 {
 		result.FinalOutput = result.StepResults[len(result.StepResults)-1].Output
 	}
+	// A step skipped past with continue_on_error does not replace the
+	// output of the last step that worked
+	if continuedPastFailure {
+		result.FinalOutput = ""
+		for i := len(result.StepResults) - 1; i >= 0; i-- {
+			if result.StepResults[i].Success {
+				result.FinalOutput = result.StepResults[i].Output
+				break
+			}
+		}
+	}
+
+	result.Variables = wfCtx.ToMap()
+	delete(result.Variables, prevVariable) // Only one run back, so chains don't nest
+	result.Variables["final_output"] = result.FinalOutput
+	result.Variables["workflow"] = workflowName
+
+	// Sub-workflows report to their parent step, not to {prev.*}
+	if depth == 0 {
+		e.mu.Lock()
+		e.last = result
+		e.mu.Unlock()
+	}
 
 	return result, nil
 }
 
+// LastResult returns the most recent successful workflow run, or nil
+func (e *Engine) LastResult() *WorkflowResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.last
+}
+
+// PreviousVariables returns the variables of the most recent successful
+// run, or nil if no workflow has completed in this session
+func (e *Engine) PreviousVariables() map[string]any {
+	last := e.LastResult()
+	if last == nil {
+		return nil
+	}
+	return last.Variables
+}
+
+// ExpandVariables replaces {key} and {key.field} placeholders in text with
+// values from vars. Nested values must be map[string]any. Unknown
+// placeholders are left as-is.
+func ExpandVariables(text string, vars map[string]any) string {
+	return templatePattern.ReplaceAllStringFunc(text, func(match string) string {
+		key := match[1 : len(match)-1] // Remove { and }
+
+		// Handle nested keys like "step_name.output"
+		parts := strings.SplitN(key, ".", 2)
+		value, ok := vars[parts[0]]
+		if !ok {
+			return match // Keep original if not found
+		}
+
+		if len(parts) == 1 {
+			return fmt.Sprintf("%v", value)
+		}
+
+		// Handle nested access
+		if nested, ok := value.(map[string]any); ok {
+			if nestedVal, ok := nested[parts[1]]; ok {
+				return fmt.Sprintf("%v", nestedVal)
+			}
+		}
+
+		return match // Keep original if nested key not found
+	})
+}
+
 // executeStepWithLooping executes a step, handling loop_until conditions
 func (e *Engine) executeStepWithLooping(
 	ctx context.Context,
 	step *WorkflowStep,
 	wfCtx *Context,
 	initialPrompt string,
+	emit func(StreamEvent),
 ) (*StepResult, error) {
 	maxLoops := step.MaxLoops
 	if maxLoops <= 0 {
@@ -138,10 +295,18 @@ func (e *Engine) executeStepWithLooping(
 	var lastResult *StepResult
 
 	for loopCount := 1; loopCount <= maxLoops; loopCount++ {
-		result, err := e.executeStep(ctx, step, wfCtx, initialPrompt)
+		emit(StreamEvent{Type: "step_start", StepName: step.Name, AgentName: stepTarget(step), Loop: loopCount})
+
+		start := time.Now()
+		result, err := e.executeStepWithRetries(ctx, step, wfCtx, initialPrompt, emit)
 		result.LoopCount = loopCount
+		slog.Info("workflow step", "step", step.Name, "agent", stepTarget(step), "loop", loopCount,
+			"attempts", result.Attempts, "latency", time.Since(start), "error", err)
 		lastResult = result
 
+		done := *result
+		emit(StreamEvent{Type: "step_done", StepName: step.Name, AgentName: stepTarget(step), Loop: loopCount, StepResult: &done, Error: err})
+
 		if err != nil {
 			return result, err
 		}
@@ -171,13 +336,85 @@ func (e *Engine) executeStepWithLooping(
 	return lastResult, ErrMaxLoopsExceeded
 }
 
+// executeStepWithRetries runs a step, retrying failed attempts up to
+// step.Retries times with exponential backoff
+func (e *Engine) executeStepWithRetries(
+	ctx context.Context,
+	step *WorkflowStep,
+	wfCtx *Context,
+	initialPrompt string,
+	emit func(StreamEvent),
+) (*StepResult, error) {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		result, err := e.executeStepAttempt(ctx, step, wfCtx, initialPrompt, emit)
+		result.Attempts = attempt
+		if err == nil || attempt > step.Retries || !isRetryable(ctx, err) {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// executeStepAttempt runs a step once, bounded by step.Timeout if set
+func (e *Engine) executeStepAttempt(
+	ctx context.Context,
+	step *WorkflowStep,
+	wfCtx *Context,
+	initialPrompt string,
+	emit func(StreamEvent),
+) (*StepResult, error) {
+	if step.Timeout <= 0 {
+		return e.executeStep(ctx, step, wfCtx, initialPrompt, emit)
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, step.Timeout)
+	defer cancel()
+
+	result, err := e.executeStep(stepCtx, step, wfCtx, initialPrompt, emit)
+	if err != nil && ctx.Err() == nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s", ErrStepTimeout, step.Timeout)
+		result.Success = false
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+// isRetryable reports whether a failed attempt may succeed if tried again.
+// Cancellation, missing agents and operator decisions are final.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrAgentNotFound) &&
+		!errors.Is(err, ErrWorkflowNotFound) &&
+		!errors.Is(err, ErrWorkflowDepth) &&
+		!errors.Is(err, ErrHumanRejected) &&
+		!errors.Is(err, ErrNoHumanInput) &&
+		!errors.Is(err, ErrWorkflowAborted)
+}
+
 // executeStep executes a single workflow step
 func (e *Engine) executeStep(
 	ctx context.Context,
 	step *WorkflowStep,
 	wfCtx *Context,
 	initialPrompt string,
+	emit func(StreamEvent),
 ) (*StepResult, error) {
+	if step.Type == StepTypeWorkflow {
+		return e.executeSubWorkflow(ctx, step, wfCtx, initialPrompt, emit)
+	}
+	if step.IsHuman() {
+		return e.executeHumanStep(ctx, step, wfCtx, initialPrompt, emit)
+	}
+
 	result := &StepResult{
 		StepName: step.Name,
 		Agent:    step.Agent,
@@ -214,6 +451,35 @@ func (e *Engine) executeStep(
 	return result, nil
 }
 
+// executeSubWorkflow runs the workflow a step refers to, with the step's
+// resolved prompt as its input. Its final output becomes the step's output.
+func (e *Engine) executeSubWorkflow(
+	ctx context.Context,
+	step *WorkflowStep,
+	wfCtx *Context,
+	initialPrompt string,
+	emit func(StreamEvent),
+) (*StepResult, error) {
+	result := &StepResult{
+		StepName: step.Name,
+		Agent:    step.Agent,
+	}
+
+	prompt := e.buildPrompt(step, wfCtx, initialPrompt)
+	subCtx := context.WithValue(ctx, workflowDepthKey{}, workflowDepth(ctx)+1)
+
+	subResult, err := e.ExecuteWithEvents(subCtx, step.Workflow, prompt, emit)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("workflow %s: %v", step.Workflow, err)
+		return result, err
+	}
+
+	result.Success = true
+	result.Output = subResult.FinalOutput
+	return result, nil
+}
+
 // buildPrompt constructs the prompt for a step
 func (e *Engine) buildPrompt(step *WorkflowStep, wfCtx *Context, initialPrompt string) string {
 	var prompt string
@@ -245,75 +511,7 @@ func (e *Engine) substituteTemplates(template string, wfCtx *Context, initialPro
 	result = strings.ReplaceAll(result, "{user_input}", initialPrompt)
 
 	// Replace {key} and {key.field} patterns
-	contextMap := wfCtx.ToMap()
-	pattern := regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*(?:\.[a-zA-Z_][a-zA-Z0-9_]*)?)\}`)
-
-	result = pattern.ReplaceAllStringFunc(result, func(match string) string {
-		key := match[1 : len(match)-1] // Remove { and }
-
-		// Handle nested keys like "step_name.output"
-		parts := strings.SplitN(key, ".", 2)
-		value, ok := contextMap[parts[0]]
-		if !ok {
-			return match // Keep original if not found
-		}
-
-		if len(parts) == 1 {
-			return fmt.Sprintf("%v", value)
-		}
-
-		// Handle nested access
-		if nested, ok := value.(map[string]any); ok {
-			if nestedVal, ok := nested[parts[1]]; ok {
-				return fmt.Sprintf("%v", nestedVal)
-			}
-		}
-
-		return match // Keep original if nested key not found
-	})
-
-	return result
-}
-
-// evaluateCondition evaluates a simple condition expression
-// Supports: "key == value", "key != value", "key.field == value"
-func (e *Engine) evaluateCondition(condition string, wfCtx *Context) (bool, error) {
-	condition = strings.TrimSpace(condition)
-
-	// Handle "true" and "false" literals
-	if condition == "true" {
-		return true, nil
-	}
-	if condition == "false" {
-		return false, nil
-	}
-
-	// Parse comparison operators
-	var left, right, op string
-
-	if strings.Contains(condition, "==") {
-		parts := strings.SplitN(condition, "==", 2)
-		left, right, op = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), "=="
-	} else if strings.Contains(condition, "!=") {
-		parts := strings.SplitN(condition, "!=", 2)
-		left, right, op = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), "!="
-	} else {
-		// Treat as existence check
-		value := e.resolveValue(condition, wfCtx)
-		return value != nil && value != "" && value != false, nil
-	}
-
-	leftVal := e.resolveValue(left, wfCtx)
-	rightVal := e.resolveValue(right, wfCtx)
-
-	switch op {
-	case "==":
-		return fmt.Sprintf("%v", leftVal) == fmt.Sprintf("%v", rightVal), nil
-	case "!=":
-		return fmt.Sprintf("%v", leftVal) != fmt.Sprintf("%v", rightVal), nil
-	}
-
-	return false, ErrInvalidCondition
+	return ExpandVariables(result, wfCtx.ToMap())
 }
 
 // resolveValue resolves a value from the context or returns the literal
@@ -357,6 +555,18 @@ func (e *Engine) resolveValue(expr string, wfCtx *Context) any {
 	return nil
 }
 
+// stepTarget names what a step runs: its agent, HUMAN, or its sub-workflow
+func stepTarget(step *WorkflowStep) string {
+	switch stepType(step) {
+	case StepTypeHuman:
+		return HumanAgent
+	case StepTypeWorkflow:
+		return step.Workflow
+	default:
+		return step.Agent
+	}
+}
+
 // findStepIndex returns the index of a step by name, or -1 if not found
 func (e *Engine) findStepIndex(workflow *WorkflowDefinition, stepName string) int {
 	for i, step := range workflow.Steps {
@@ -369,13 +579,15 @@ func (e *Engine) findStepIndex(workflow *WorkflowDefinition, stepName string) in
 
 // StreamEvent represents events during workflow streaming execution
 type StreamEvent struct {
-	Type          string // "workflow_start", "step_start", "step_done", "workflow_done", "error"
-	WorkflowName  string
-	StepName      string
-	AgentName     string
-	StepResult    *StepResult
+	Type           string // "workflow_start", "step_start", "step_done", "human_input_required", "workflow_done", "error"
+	WorkflowName   string
+	StepName       string
+	AgentName      string
+	Prompt         string // Question shown to the operator for human_input_required
+	Loop           int    // Loop iteration of step events, from 1
+	StepResult     *StepResult
 	WorkflowResult *WorkflowResult
-	Error         error
+	Error          error
 }
 
 // ExecuteStream runs a workflow with streaming events
@@ -387,13 +599,20 @@ func (e *Engine) ExecuteStream(ctx context.Context, workflowName string, initial
 
 		events <- StreamEvent{Type: "workflow_start", WorkflowName: workflowName}
 
-		result, err := e.Execute(ctx, workflowName, initialPrompt)
+		emit := func(event StreamEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		}
+
+		result, err := e.ExecuteWithEvents(ctx, workflowName, initialPrompt, emit)
 		if err != nil {
-			events <- StreamEvent{Type: "error", Error: err, WorkflowResult: result}
+			events <- StreamEvent{Type: "error", WorkflowName: workflowName, Error: err, WorkflowResult: result}
 			return
 		}
 
-		events <- StreamEvent{Type: "workflow_done", WorkflowResult: result}
+		events <- StreamEvent{Type: "workflow_done", WorkflowName: workflowName, WorkflowResult: result}
 	}()
 
 	return events
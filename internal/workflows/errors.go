@@ -27,6 +27,27 @@ var (
 	// ErrInvalidCondition is returned when a condition expression is invalid
 	ErrInvalidCondition = errors.New("invalid condition expression")
 
+	// ErrUnknownStepType is returned when a step has an unsupported type
+	ErrUnknownStepType = errors.New("unknown step type: use 'agent', 'human' or 'workflow'")
+
+	// ErrMissingWorkflow is returned when a workflow step names no workflow
+	ErrMissingWorkflow = errors.New("step of type 'workflow' missing required 'workflow' field")
+
+	// ErrWorkflowCycle is returned when workflows invoke each other in a loop
+	ErrWorkflowCycle = errors.New("workflow invokes itself")
+
+	// ErrWorkflowDepth is returned when sub-workflows nest too deeply
+	ErrWorkflowDepth = errors.New("sub-workflows nested too deeply")
+
+	// ErrHumanRejected is returned when the operator rejects a human step
+	ErrHumanRejected = errors.New("rejected by operator")
+
+	// ErrNoHumanInput is returned when a human step cannot get a reply
+	ErrNoHumanInput = errors.New("no input available for human step")
+
+	// ErrStepTimeout is returned when a step attempt exceeds its timeout
+	ErrStepTimeout = errors.New("step timed out")
+
 	// ErrWorkflowAborted is returned when a workflow is cancelled
 	ErrWorkflowAborted = errors.New("workflow aborted")
 )
@@ -0,0 +1,132 @@
+package workflows
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// HumanAgent is the agent name that marks a human step, as an alternative
+// to type: human
+const HumanAgent = "HUMAN"
+
+// rejectWords are replies that reject a human step. Any other reply,
+// including free text, approves it.
+var rejectWords = map[string]bool{
+	"reject": true, "rejected": true, "no": true, "n": true, "deny": true, "denied": true,
+}
+
+// IsHuman reports whether the step waits for the operator instead of
+// running an agent
+func (s *WorkflowStep) IsHuman() bool {
+	return s.Type == StepTypeHuman || s.Agent == HumanAgent
+}
+
+// isRejection reports whether a reply starts with a rejecting word, e.g.
+// "reject: the tests are missing"
+func isRejection(reply string) bool {
+	fields := strings.Fields(reply)
+	if len(fields) == 0 {
+		return false
+	}
+	word := strings.ToLower(strings.TrimRight(fields[0], ".,:;!"))
+	return rejectWords[word]
+}
+
+// LineInput returns a channel that yields each line read from r. It is
+// closed at EOF. Use it to answer human steps from a terminal.
+func LineInput(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+var (
+	stdinInput     <-chan string
+	stdinInputOnce sync.Once
+)
+
+// defaultHumanInput reads replies from stdin, for headless runs that did
+// not call SetHumanInput
+func defaultHumanInput() <-chan string {
+	stdinInputOnce.Do(func() {
+		stdinInput = LineInput(os.Stdin)
+	})
+	return stdinInput
+}
+
+// SetHumanInput sets the channel human steps read replies from. Without
+// one, the engine prompts on stderr and reads stdin.
+func (e *Engine) SetHumanInput(input <-chan string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.humanInput = input
+}
+
+// executeHumanStep emits a human_input_required event and waits for the
+// operator's reply, which becomes the step's output. A rejecting reply
+// fails the step so on_failure can route it.
+func (e *Engine) executeHumanStep(
+	ctx context.Context,
+	step *WorkflowStep,
+	wfCtx *Context,
+	initialPrompt string,
+	emit func(StreamEvent),
+) (*StepResult, error) {
+	result := &StepResult{
+		StepName: step.Name,
+		Agent:    HumanAgent,
+	}
+
+	prompt := e.buildPrompt(step, wfCtx, initialPrompt)
+
+	e.mu.Lock()
+	input := e.humanInput
+	e.mu.Unlock()
+	if input == nil {
+		input = defaultHumanInput()
+		fmt.Fprintf(os.Stderr, "\n[%s] %s\nReply approve, reject, or an answer: ", step.Name, prompt)
+	}
+
+	emit(StreamEvent{Type: "human_input_required", StepName: step.Name, AgentName: HumanAgent, Prompt: prompt})
+
+	var reply string
+	select {
+	case <-ctx.Done():
+		result.Success = false
+		result.Error = ErrWorkflowAborted.Error()
+		return result, ErrWorkflowAborted
+	case line, ok := <-input:
+		if !ok {
+			result.Success = false
+			result.Error = ErrNoHumanInput.Error()
+			return result, ErrNoHumanInput
+		}
+		reply = strings.TrimSpace(line)
+	}
+
+	// Keep the reply even when rejected, so an on_failure step can read it
+	result.Output = reply
+	if step.Output != "" {
+		wfCtx.Set(step.Output, reply)
+	}
+
+	if isRejection(reply) {
+		result.Success = false
+		result.Error = ErrHumanRejected.Error()
+		return result, ErrHumanRejected
+	}
+
+	result.Success = true
+	return result, nil
+}
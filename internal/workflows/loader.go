@@ -8,6 +8,8 @@ import (
 	"sync"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/simonyos/Z-CODE/internal/logging"
 )
 
 // Loader handles discovery and parsing of workflow definitions from YAML files
@@ -64,7 +66,7 @@ func (l *Loader) LoadAll() ([]*WorkflowDefinition, error) {
 			workflow, err := l.LoadFromFile(filePath)
 			if err != nil {
 				// Log but don't fail on individual file errors
-				fmt.Fprintf(os.Stderr, "Warning: failed to load workflow from %s: %v\n", filePath, err)
+				logging.Warnf("failed to load workflow from %s: %v", filePath, err)
 				continue
 			}
 
@@ -83,13 +85,22 @@ func (l *Loader) LoadFromFile(filePath string) (*WorkflowDefinition, error) {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 
+	workflow, err := ParseWorkflowYAML(content)
+	if err != nil {
+		return nil, err
+	}
+
+	workflow.FilePath = filePath
+	return workflow, nil
+}
+
+// ParseWorkflowYAML parses and validates YAML content into a WorkflowDefinition
+func ParseWorkflowYAML(content []byte) (*WorkflowDefinition, error) {
 	var workflow WorkflowDefinition
 	if err := yaml.Unmarshal(content, &workflow); err != nil {
 		return nil, fmt.Errorf("error parsing YAML: %w", err)
 	}
 
-	workflow.FilePath = filePath
-
 	if err := workflow.Validate(); err != nil {
 		return nil, err
 	}
@@ -157,6 +168,44 @@ func (r *Registry) Refresh() error {
 		r.workflows[workflow.Name] = workflow
 	}
 
+	// Drop workflows whose sub-workflows lead back to themselves
+	var cyclic []string
+	for name := range r.workflows {
+		if cycle := findCycle(r.workflows, name, nil); cycle != nil {
+			logging.Warnf("skipping workflow %s: %v (%s)", name, ErrWorkflowCycle, strings.Join(cycle, " -> "))
+			cyclic = append(cyclic, name)
+		}
+	}
+	for _, name := range cyclic {
+		delete(r.workflows, name)
+	}
+
+	return nil
+}
+
+// findCycle follows the sub-workflow steps of name and returns the chain of
+// names that loops back onto chain, or nil if there is no loop
+func findCycle(workflows map[string]*WorkflowDefinition, name string, chain []string) []string {
+	for i, seen := range chain {
+		if seen == name {
+			return append(chain[i:], name)
+		}
+	}
+
+	workflow, ok := workflows[name]
+	if !ok {
+		return nil
+	}
+
+	chain = append(chain[:len(chain):len(chain)], name)
+	for _, step := range workflow.Steps {
+		if step.Type != StepTypeWorkflow {
+			continue
+		}
+		if cycle := findCycle(workflows, step.Workflow, chain); cycle != nil {
+			return cycle
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,196 @@
+package workflows
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Condition outcomes in a plan
+const (
+	PlanConditionNone    = "" // The step has no condition
+	PlanConditionTrue    = "true"
+	PlanConditionFalse   = "false"
+	PlanConditionRuntime = "runtime" // Depends on outputs of earlier steps
+)
+
+// identifierPattern matches the names a condition may reference
+var identifierPattern = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// WorkflowPlan describes what a workflow would do, without calling the LLM
+type WorkflowPlan struct {
+	WorkflowName string
+	Description  string
+	Steps        []PlannedStep
+
+	// Problems lists missing agents, workflows and step references
+	Problems []string
+}
+
+// PlannedStep is one step of a plan
+type PlannedStep struct {
+	Name      string
+	Type      string
+	Agent     string
+	Workflow  string
+	Prompt    string // Resolved as far as the initial context allows
+	Condition string
+	LoopUntil string
+	OnSuccess string
+	OnFailure string
+	Timeout   time.Duration
+	Retries   int
+
+	ContinueOnError bool
+
+	// ConditionResult is one of the PlanCondition* values
+	ConditionResult string
+}
+
+// Plan walks a workflow's steps in order and resolves their prompts and
+// conditions against the initial context. Placeholders for outputs of
+// earlier steps are left as-is. Routing via on_success and on_failure is
+// listed but not followed.
+func (e *Engine) Plan(workflowName string, initialPrompt string) (*WorkflowPlan, error) {
+	workflow, ok := e.workflowRegistry.Get(workflowName)
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+
+	prev := e.PreviousVariables()
+	if prev != nil {
+		initialPrompt = ExpandVariables(initialPrompt, map[string]any{prevVariable: prev})
+	}
+
+	wfCtx := NewContext()
+	wfCtx.Set("user_input", initialPrompt)
+	if prev != nil {
+		wfCtx.Set(prevVariable, prev)
+	}
+
+	plan := &WorkflowPlan{
+		WorkflowName: workflow.Name,
+		Description:  workflow.Description,
+	}
+
+	// Names only known once a step has run
+	produced := make(map[string]bool)
+	for _, step := range workflow.Steps {
+		if step.Name != "" {
+			produced[step.Name] = true
+		}
+		if step.Output != "" {
+			produced[step.Output] = true
+		}
+	}
+
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		planned := PlannedStep{
+			Name:      step.Name,
+			Type:      stepType(step),
+			Agent:     step.Agent,
+			Workflow:  step.Workflow,
+			Prompt:    e.buildPrompt(step, wfCtx, initialPrompt),
+			Condition: step.Condition,
+			LoopUntil: step.LoopUntil,
+			OnSuccess: step.OnSuccess,
+			OnFailure: step.OnFailure,
+			Timeout:   step.Timeout,
+			Retries:   step.Retries,
+
+			ContinueOnError: step.ContinueOnError,
+		}
+
+		label := step.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+
+		if step.Condition != "" {
+			result, err := e.planCondition(step.Condition, wfCtx, produced)
+			planned.ConditionResult = result
+			if err != nil {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("step %s: %v: %s", label, ErrInvalidCondition, step.Condition))
+			}
+		}
+		if step.LoopUntil != "" {
+			if _, err := e.evaluateCondition(step.LoopUntil, wfCtx); err != nil {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("step %s: %v: %s", label, ErrInvalidCondition, step.LoopUntil))
+			}
+		}
+
+		switch planned.Type {
+		case StepTypeAgent:
+			if _, ok := e.agentRegistry.Get(step.Agent); !ok {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("step %s: %v: %s", label, ErrAgentNotFound, step.Agent))
+			}
+		case StepTypeWorkflow:
+			if _, ok := e.workflowRegistry.Get(step.Workflow); !ok {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("step %s: %v: %s", label, ErrWorkflowNotFound, step.Workflow))
+			}
+		}
+
+		for _, target := range []string{step.OnSuccess, step.OnFailure} {
+			if target != "" && e.findStepIndex(workflow, target) < 0 {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("step %s: %v: %s", label, ErrStepNotFound, target))
+			}
+		}
+
+		plan.Steps = append(plan.Steps, planned)
+	}
+
+	return plan, nil
+}
+
+// planCondition evaluates a condition against the initial context, or
+// reports PlanConditionRuntime when it reads a step's output
+func (e *Engine) planCondition(condition string, wfCtx *Context, produced map[string]bool) (string, error) {
+	met, err := e.evaluateCondition(condition, wfCtx)
+	if err != nil {
+		return PlanConditionNone, err
+	}
+
+	for _, name := range identifierPattern.FindAllString(stripQuoted(condition), -1) {
+		if produced[name] {
+			return PlanConditionRuntime, nil
+		}
+	}
+
+	if met {
+		return PlanConditionTrue, nil
+	}
+	return PlanConditionFalse, nil
+}
+
+// stripQuoted removes quoted literals so their words are not mistaken for
+// variable names
+func stripQuoted(s string) string {
+	var out []rune
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		default:
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
+
+// stepType returns the effective type of a step
+func stepType(step *WorkflowStep) string {
+	switch {
+	case step.Type == StepTypeWorkflow:
+		return StepTypeWorkflow
+	case step.IsHuman():
+		return StepTypeHuman
+	default:
+		return StepTypeAgent
+	}
+}
@@ -0,0 +1,605 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonyos/Z-CODE/internal/agents"
+	"github.com/simonyos/Z-CODE/internal/llm"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	wfCtx := NewContext()
+	wfCtx.Set("review", "Found 2 errors in parser.go")
+	wfCtx.Set("status", "ok")
+	wfCtx.Set("score", "7.5")
+	wfCtx.Set("count", 12)
+	wfCtx.SetResult("test", StepResult{
+		StepName:  "test",
+		Success:   false,
+		Output:    "FAIL: TestParse",
+		LoopCount: 3,
+	})
+	wfCtx.SetResult("lint", StepResult{StepName: "lint", Success: true, Output: "no issues"})
+
+	e := &Engine{}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		// Literals and existence
+		{"true", true},
+		{"false", false},
+		{"status", true},
+		{"missing", true}, // Unknown names resolve to themselves
+		{"lint.success", true},
+		{"test.success", false},
+
+		// Equality, unchanged from before
+		{"status == ok", true},
+		{"status == 'ok'", true},
+		{"status != ok", false},
+		{"test.success == false", true},
+		{"lint.output == \"no issues\"", true},
+
+		// String operators
+		{"review contains error", true},
+		{"review contains 'warning'", false},
+		{"test.output contains \"FAIL\"", true},
+		{"test.output startswith FAIL", true},
+		{"test.output startswith Test", false},
+		{"lint.output endswith issues", true},
+		{"lint.output endswith 'no'", false},
+
+		// Numeric comparisons
+		{"score > 7", true},
+		{"score >= 7.5", true},
+		{"score < 7.5", false},
+		{"score <= 7.5", true},
+		{"count > 9", true}, // 12 > 9 numerically, though "12" < "9" as strings
+		{"count == 12.0", true},
+		{"test.loop_count >= 3", true},
+		{"test.loop_count < 3", false},
+
+		// String fallback for non-numbers
+		{"status < pending", true},
+		{"status > pending", false},
+
+		// Boolean combinations; && binds tighter than ||
+		{"lint.success && test.output contains FAIL", true},
+		{"lint.success && test.success", false},
+		{"test.success || lint.success", true},
+		{"test.success || score < 5", false},
+		{"test.success && score > 5 || status == ok", true},
+		{"status == ok && score > 5 && review contains errors", true},
+
+		// Operators inside quotes are literal text
+		{"review contains 'a && b'", false},
+		{"lint.output != 'x || y'", true},
+		{"review contains \"2 errors\"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got, err := e.evaluateCondition(tt.condition, wfCtx)
+			if err != nil {
+				t.Fatalf("evaluateCondition(%q) error = %v", tt.condition, err)
+			}
+			if got != tt.want {
+				t.Errorf("evaluateCondition(%q) = %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition_Invalid(t *testing.T) {
+	e := &Engine{}
+	wfCtx := NewContext()
+
+	for _, condition := range []string{"", "status ==", "> 3", "lint.success && ", "|| true"} {
+		if _, err := e.evaluateCondition(condition, wfCtx); !errors.Is(err, ErrInvalidCondition) {
+			t.Errorf("evaluateCondition(%q) error = %v, want ErrInvalidCondition", condition, err)
+		}
+	}
+}
+
+// funcProvider is a tool provider whose replies come from a function
+type funcProvider func(ctx context.Context, messages []llm.Message) (*llm.ToolCallResponse, error)
+
+func (f funcProvider) Generate(ctx context.Context, messages []llm.Message) (string, error) {
+	resp, err := f(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (f funcProvider) GenerateStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	resp, err := f(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan llm.StreamChunk, 1)
+	ch <- llm.StreamChunk{Text: resp.Content, Done: true}
+	close(ch)
+	return ch, nil
+}
+
+func (f funcProvider) Validate(ctx context.Context) error {
+	return nil
+}
+
+func (f funcProvider) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (*llm.ToolCallResponse, error) {
+	return f(ctx, messages)
+}
+
+func (f funcProvider) GenerateStreamWithTools(ctx context.Context, messages []llm.Message, tools []llm.OpenAITool) (<-chan llm.ToolStreamChunk, error) {
+	resp, err := f(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan llm.ToolStreamChunk, 1)
+	ch <- llm.ToolStreamChunk{Text: resp.Content, ToolCalls: resp.ToolCalls, Done: true}
+	close(ch)
+	return ch, nil
+}
+
+// newTestEngine creates an engine whose registry holds the given workflow
+// YAML documents, with no agents and no provider
+func newTestEngine(t *testing.T, docs ...string) *Engine {
+	t.Helper()
+	dir := t.TempDir()
+	for i, doc := range docs {
+		path := filepath.Join(dir, "workflow"+string(rune('a'+i))+".yaml")
+		if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reg := NewRegistryWithPaths([]string{dir})
+	if err := reg.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	return NewEngine(agents.NewRegistryWithPaths(nil), reg, nil, nil)
+}
+
+func TestHumanStep(t *testing.T) {
+	e := newTestEngine(t, `
+name: gate
+steps:
+  - name: approve
+    type: human
+    output: decision
+    prompt: "Ship {user_input}?"
+    on_failure: revise
+  - name: done
+    agent: HUMAN
+    prompt: "Shipped after {decision}"
+  - name: revise
+    agent: HUMAN
+    output: feedback
+    condition: "decision startswith reject"
+    prompt: "What should change? ({decision})"
+`, `
+name: ask
+steps:
+  - name: question
+    type: human
+`)
+
+	t.Run("approve", func(t *testing.T) {
+		input := make(chan string, 2)
+		input <- "approve"
+		input <- "thanks"
+		e.SetHumanInput(input)
+
+		var prompts []string
+		for event := range e.ExecuteStream(context.Background(), "gate", "v1.2") {
+			switch event.Type {
+			case "human_input_required":
+				prompts = append(prompts, event.Prompt)
+			case "error":
+				t.Fatalf("workflow failed: %v", event.Error)
+			case "workflow_done":
+				if got := event.WorkflowResult.Variables["decision"]; got != "approve" {
+					t.Errorf("decision = %v, want approve", got)
+				}
+			}
+		}
+
+		want := []string{"Ship v1.2?", "Shipped after approve"}
+		if len(prompts) != len(want) {
+			t.Fatalf("prompts = %q, want %q", prompts, want)
+		}
+		for i := range want {
+			if prompts[i] != want[i] {
+				t.Errorf("prompt %d = %q, want %q", i, prompts[i], want[i])
+			}
+		}
+	})
+
+	t.Run("reject routes to on_failure", func(t *testing.T) {
+		input := make(chan string, 2)
+		input <- "reject: missing changelog"
+		input <- "add the changelog"
+		e.SetHumanInput(input)
+
+		result, err := e.Execute(context.Background(), "gate", "v1.2")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if len(result.StepResults) != 2 {
+			t.Fatalf("ran %d steps, want 2", len(result.StepResults))
+		}
+		if first := result.StepResults[0]; first.Success || first.Error != ErrHumanRejected.Error() {
+			t.Errorf("approve step = %+v, want a rejection", first)
+		}
+		if got := result.Variables["decision"]; got != "reject: missing changelog" {
+			t.Errorf("decision = %v", got)
+		}
+		if got := result.Variables["feedback"]; got != "add the changelog" {
+			t.Errorf("feedback = %v", got)
+		}
+	})
+
+	t.Run("closed input", func(t *testing.T) {
+		input := make(chan string)
+		close(input)
+		e.SetHumanInput(input)
+
+		if _, err := e.Execute(context.Background(), "ask", "anything?"); !errors.Is(err, ErrNoHumanInput) {
+			t.Errorf("Execute() error = %v, want ErrNoHumanInput", err)
+		}
+	})
+}
+
+func TestStepRetriesAndTimeout(t *testing.T) {
+	base := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	defer func() { retryBaseDelay = base }()
+
+	e := newTestEngine(t, `
+name: flaky
+steps:
+  - name: work
+    agent: worker
+    retries: 2
+`, `
+name: slow
+steps:
+  - name: work
+    agent: sleeper
+    timeout: 20ms
+    retries: 1
+`, `
+name: missing
+steps:
+  - name: work
+    agent: nobody
+    retries: 3
+`)
+
+	calls := 0
+	e.executor = agents.NewExecutor(funcProvider(func(ctx context.Context, messages []llm.Message) (*llm.ToolCallResponse, error) {
+		if strings.HasPrefix(messages[0].Content, "sleeper") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		calls++
+		if calls < 3 {
+			return nil, errors.New("502 bad gateway")
+		}
+		return &llm.ToolCallResponse{Content: "done", Done: true}, nil
+	}), nil)
+	e.agentRegistry.Register(&agents.AgentDefinition{Name: "worker", SystemPrompt: "worker", Tools: []string{"read_file"}})
+	e.agentRegistry.Register(&agents.AgentDefinition{Name: "sleeper", SystemPrompt: "sleeper", Tools: []string{"read_file"}})
+
+	t.Run("retries until success", func(t *testing.T) {
+		result, err := e.Execute(context.Background(), "flaky", "go")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if got := result.StepResults[0]; !got.Success || got.Attempts != 3 || got.Output != "done" {
+			t.Errorf("step result = %+v, want success on attempt 3", got)
+		}
+	})
+
+	t.Run("timeout per attempt", func(t *testing.T) {
+		result, err := e.Execute(context.Background(), "slow", "go")
+		if !errors.Is(err, ErrStepTimeout) {
+			t.Fatalf("Execute() error = %v, want ErrStepTimeout", err)
+		}
+		if got := result.StepResults[0]; got.Success || got.Attempts != 2 {
+			t.Errorf("step result = %+v, want 2 failed attempts", got)
+		}
+	})
+
+	t.Run("missing agent is not retried", func(t *testing.T) {
+		result, err := e.Execute(context.Background(), "missing", "go")
+		if !errors.Is(err, ErrAgentNotFound) {
+			t.Fatalf("Execute() error = %v, want ErrAgentNotFound", err)
+		}
+		if got := result.StepResults[0].Attempts; got != 1 {
+			t.Errorf("attempts = %d, want 1", got)
+		}
+	})
+}
+
+func TestSubWorkflow(t *testing.T) {
+	e := newTestEngine(t, `
+name: parent
+steps:
+  - name: check
+    type: workflow
+    workflow: child
+    prompt: "Review {user_input}"
+    output: verdict
+  - name: report
+    agent: HUMAN
+    prompt: "Verdict: {verdict}"
+`, `
+name: child
+steps:
+  - name: ask
+    type: human
+`, `
+name: ping
+steps:
+  - name: call
+    type: workflow
+    workflow: pong
+`, `
+name: pong
+steps:
+  - name: call
+    type: workflow
+    workflow: ping
+`, `
+name: caller
+steps:
+  - name: call
+    type: workflow
+    workflow: ping
+`)
+
+	input := make(chan string, 2)
+	input <- "looks good"
+	input <- "ok"
+	e.SetHumanInput(input)
+
+	var prompts []string
+	var result *WorkflowResult
+	for event := range e.ExecuteStream(context.Background(), "parent", "main.go") {
+		switch event.Type {
+		case "human_input_required":
+			prompts = append(prompts, event.Prompt)
+		case "error":
+			t.Fatalf("workflow failed: %v", event.Error)
+		case "workflow_done":
+			result = event.WorkflowResult
+		}
+	}
+
+	if len(prompts) != 2 || prompts[0] != "Review main.go" || prompts[1] != "Verdict: looks good" {
+		t.Errorf("prompts = %q", prompts)
+	}
+	if result == nil || result.Variables["verdict"] != "looks good" {
+		t.Fatalf("result = %+v, want verdict from the sub-workflow", result)
+	}
+	if last := e.LastResult(); last == nil || last.WorkflowName != "parent" {
+		t.Errorf("LastResult() = %+v, want the parent run", last)
+	}
+
+	// Workflows in or leading into a cycle are not loaded
+	for _, name := range []string{"ping", "pong", "caller"} {
+		if _, ok := e.workflowRegistry.Get(name); ok {
+			t.Errorf("workflow %s was loaded despite a cycle", name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	e := newTestEngine(t, `
+name: release
+steps:
+  - name: review
+    agent: reviewer
+    output: review_results
+    prompt: "Review {user_input}"
+  - name: fix
+    agent: fixer
+    condition: "review_results contains error"
+    prompt: "Fix: {review_results}"
+    on_failure: rollback
+  - name: docs
+    agent: reviewer
+    condition: "user_input contains 'docs'"
+  - name: approve
+    type: human
+    condition: "user_input contains api"
+`)
+	e.agentRegistry.Register(&agents.AgentDefinition{Name: "reviewer"})
+
+	plan, err := e.Plan("release", "the api package")
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	want := []struct {
+		typ, prompt, condition string
+	}{
+		{StepTypeAgent, "Review the api package", PlanConditionNone},
+		{StepTypeAgent, "Fix: {review_results}", PlanConditionRuntime},
+		{StepTypeAgent, "the api package", PlanConditionFalse},
+		{StepTypeHuman, "the api package", PlanConditionTrue},
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("planned %d steps, want %d", len(plan.Steps), len(want))
+	}
+	for i, w := range want {
+		got := plan.Steps[i]
+		if got.Type != w.typ || got.Prompt != w.prompt || got.ConditionResult != w.condition {
+			t.Errorf("step %d = {%s %q %q}, want {%s %q %q}", i, got.Type, got.Prompt, got.ConditionResult, w.typ, w.prompt, w.condition)
+		}
+	}
+
+	wantProblems := []string{
+		"step fix: agent not found: fixer",
+		"step fix: step not found: rollback",
+	}
+	if strings.Join(plan.Problems, "\n") != strings.Join(wantProblems, "\n") {
+		t.Errorf("problems = %q, want %q", plan.Problems, wantProblems)
+	}
+
+	if _, err := e.Plan("missing", ""); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("Plan(missing) error = %v, want ErrWorkflowNotFound", err)
+	}
+}
+
+func TestExecuteWithEvents(t *testing.T) {
+	e := newTestEngine(t, `
+name: outer
+steps:
+  - name: ask
+    type: human
+    output: answer
+    loop_until: "answer == done"
+    max_loops: 3
+  - name: nested
+    type: workflow
+    workflow: inner
+`, `
+name: inner
+steps:
+  - name: confirm
+    agent: HUMAN
+`)
+
+	input := make(chan string, 3)
+	input <- "again"
+	input <- "done"
+	input <- "yes"
+	e.SetHumanInput(input)
+
+	var got []string
+	_, err := e.ExecuteWithEvents(context.Background(), "outer", "", func(event StreamEvent) {
+		entry := fmt.Sprintf("%s %s/%s#%d", event.Type, event.WorkflowName, event.StepName, event.Loop)
+		if event.Type == "step_done" {
+			entry += fmt.Sprintf(" %q", event.StepResult.Output)
+		}
+		got = append(got, entry)
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithEvents() error = %v", err)
+	}
+
+	want := []string{
+		`step_start outer/ask#1`,
+		`human_input_required outer/ask#0`,
+		`step_done outer/ask#1 "again"`,
+		`step_start outer/ask#2`,
+		`human_input_required outer/ask#0`,
+		`step_done outer/ask#2 "done"`,
+		`step_start outer/nested#1`,
+		`step_start inner/confirm#1`,
+		`human_input_required inner/confirm#0`,
+		`step_done inner/confirm#1 "yes"`,
+		`step_done outer/nested#1 "yes"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	e := newTestEngine(t, `
+name: lenient
+steps:
+  - name: lint
+    type: human
+    continue_on_error: true
+  - name: ship
+    type: human
+`, `
+name: strict
+steps:
+  - name: lint
+    type: human
+  - name: ship
+    type: human
+`, `
+name: trailing
+steps:
+  - name: build
+    type: human
+  - name: lint
+    type: human
+    continue_on_error: true
+`)
+
+	t.Run("failed step is recorded and skipped past", func(t *testing.T) {
+		input := make(chan string, 2)
+		input <- "no"
+		input <- "shipped"
+		e.SetHumanInput(input)
+
+		result, err := e.Execute(context.Background(), "lenient", "")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !result.Success || result.Error != "" {
+			t.Errorf("result = success %v, error %q, want success", result.Success, result.Error)
+		}
+		if len(result.StepResults) != 2 || result.StepResults[0].Success {
+			t.Errorf("step results = %+v, want lint failed then ship", result.StepResults)
+		}
+		if len(result.FailedSteps) != 1 || result.FailedSteps[0] != "lint" {
+			t.Errorf("FailedSteps = %v, want [lint]", result.FailedSteps)
+		}
+		if result.FinalOutput != "shipped" {
+			t.Errorf("FinalOutput = %q, want %q", result.FinalOutput, "shipped")
+		}
+	})
+
+	t.Run("final output skips a failed last step", func(t *testing.T) {
+		input := make(chan string, 2)
+		input <- "built"
+		input <- "no"
+		e.SetHumanInput(input)
+
+		result, err := e.Execute(context.Background(), "trailing", "")
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if len(result.FailedSteps) != 1 || result.FailedSteps[0] != "lint" {
+			t.Errorf("FailedSteps = %v, want [lint]", result.FailedSteps)
+		}
+		if result.FinalOutput != "built" {
+			t.Errorf("FinalOutput = %q, want the build step's %q", result.FinalOutput, "built")
+		}
+	})
+
+	t.Run("without it the workflow aborts", func(t *testing.T) {
+		input := make(chan string, 2)
+		input <- "no"
+		input <- "shipped"
+		e.SetHumanInput(input)
+
+		result, err := e.Execute(context.Background(), "strict", "")
+		if err == nil {
+			t.Fatal("Execute() error = nil, want the lint failure")
+		}
+		if result.Success || len(result.StepResults) != 1 {
+			t.Errorf("result = %+v, want abort after lint", result)
+		}
+		if len(result.FailedSteps) != 1 || result.FailedSteps[0] != "lint" {
+			t.Errorf("FailedSteps = %v, want [lint]", result.FailedSteps)
+		}
+	})
+}